@@ -64,7 +64,8 @@ Examples:
   # Send a message to multiple channels
   #network,#security Network maintenance scheduled at 02:00 UTC.
 
-Channels used must be listed in the configuration file under irc.channels.
+Channels used must be listed in the configuration file under irc.channels
+(or the channels of the network selected with --network).
 
 Type /quit to exit the interactive prompt.
 `,
@@ -76,6 +77,12 @@ Type /quit to exit the interactive prompt.
 		if err := viper.Unmarshal(&cfg); err != nil {
 			return fmt.Errorf("unmarshal config: %w", err)
 		}
+		// Talk on the primary network unless --network picks another one
+		nc, err := pickNetwork(&cfg, clientNetwork)
+		if err != nil {
+			return err
+		}
+		cfg.IRC = nc
 		if cfg.IRC.Server == "" || cfg.IRC.Nick == "" {
 			return fmt.Errorf("config missing irc.server or irc.nick")
 		}
@@ -92,9 +99,15 @@ Type /quit to exit the interactive prompt.
 		} else {
 			fmt.Fprintln(os.Stderr, "TLS: disabled")
 		}
+		fmt.Fprintf(os.Stderr, "SASL: %s (required=%v)\n", saslSummary(cfg.IRC), cfg.IRC.SASLRequired)
 		fmt.Fprintf(os.Stderr, "Nick: %s, Realname: %s\n", cfg.IRC.Nick, cfg.IRC.Realname)
 		fmt.Fprintf(os.Stderr, "Channels: %s\n", strings.Join(cfg.IRC.Channels, ", "))
 
+		// The interactive client has always said "bye" when leaving
+		if cfg.IRC.QuitMessage == "" {
+			cfg.IRC.QuitMessage = "bye"
+		}
+
 		// Build IRC client with handlers and options
 		cli, err := irc.New(cfg.IRC, irc.Handlers{
 			Connected: func() {
@@ -125,8 +138,7 @@ Type /quit to exit the interactive prompt.
 				printPrompt(os.Stdout, cfg.IRC.Nick)
 			},
 		}, irc.Options{
-			DisableFlood: false,     // send without client throttling
-			Logger:       os.Stderr, // verbose logs
+			Logger: os.Stderr, // verbose logs
 		})
 		if err != nil {
 			return err
@@ -189,8 +201,11 @@ Type /quit to exit the interactive prompt.
 		}
 
 		fmt.Fprintln(os.Stderr, "Quitting...")
-		cli.Quit("bye")
-		time.Sleep(300 * time.Millisecond)
+		sctx, scancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer scancel()
+		if err := cli.Shutdown(sctx); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
 		return nil
 	},
 }
@@ -202,6 +217,27 @@ func init() {
 	// Here you will define your flags and configuration settings.
 
 	// You can define flags and configuration settings specific to this command here.
+	clientCmd.Flags().StringVar(&clientNetwork, "network", "", "network to connect to (a networks[].name; default the irc section)")
+}
+
+// clientNetwork is the --network flag of the client command.
+var clientNetwork string
+
+// pickNetwork returns the settings of the named network, or of the primary
+// network when name is empty.
+func pickNetwork(cfg *config.Config, name string) (config.IRCConfig, error) {
+	nets := cfg.IRCNetworks()
+	if name == "" {
+		return nets[0], nil
+	}
+	var names []string
+	for _, n := range nets {
+		if strings.EqualFold(n.Name, name) {
+			return n, nil
+		}
+		names = append(names, n.Name)
+	}
+	return config.IRCConfig{}, fmt.Errorf("unknown network %q (configured: %s)", name, strings.Join(names, ", "))
 }
 
 // ensureChanPrefix ensures that the channel name starts with '#' or '&'
@@ -213,6 +249,27 @@ func ensureChanPrefix(ch string) string {
 	return "#" + ch
 }
 
+// saslSummary describes the configured SASL mechanism, e.g. "PLAIN (login=ircbot)"
+func saslSummary(c config.IRCConfig) string {
+	switch {
+	case c.SASLExternal:
+		return "EXTERNAL"
+	case c.SASLLogin != "":
+		return fmt.Sprintf("PLAIN (login=%s)", c.SASLLogin)
+	default:
+		return "disabled"
+	}
+}
+
+// queueSummary describes the send queue settings, e.g. "size=1000 overflow=collapse rate=2/s burst=5"
+func queueSummary(q config.QueueConfig) string {
+	s := fmt.Sprintf("size=%d overflow=%s rate=%g/s burst=%d", q.Size, q.Overflow, q.Rate, q.Burst)
+	if q.ChannelRate > 0 {
+		s += fmt.Sprintf(" channel_rate=%g/s channel_burst=%d", q.ChannelRate, q.ChannelBurst)
+	}
+	return s + " (0/empty=defaults)"
+}
+
 // printPrompt writes the interactive prompt, e.g. "[ircbot] "
 func printPrompt(out io.Writer, nick string) {
 	fmt.Fprintf(out, "[%s] ", nick)
@@ -0,0 +1,93 @@
+/*
+MIT License
+
+Copyright (c) 2025 Mikael Schultz <mikael@conf-t.se>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	appcfg "github.com/bitcanon/ircpush/pkg/config"
+)
+
+// configCmd groups config file tools
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Config file tools",
+}
+
+var configValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Check the config file and exit non-zero on problems",
+	Long: `Check the config file (with environment overrides applied) for problems
+that serve would otherwise ignore or only notice later: highlight rules with
+a bad regex, an unknown kind or color, or groups missing from the pattern,
+invalid channel globs, unknown keys, and a missing irc.server or irc.nick.
+
+Each problem is printed with its YAML path, e.g.
+  highlight.rules[5].pattern: "missing closing )"`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cf := viper.ConfigFileUsed()
+		if cf == "" {
+			return fmt.Errorf("no config file found (use --config)")
+		}
+		var cfg appcfg.Config
+		if err := viper.Unmarshal(&cfg); err != nil {
+			return fmt.Errorf("unmarshal config: %w", err)
+		}
+		if err := validateConfig(&cfg); err != nil {
+			var errs appcfg.Errors
+			if !errors.As(err, &errs) {
+				return err
+			}
+			fmt.Fprintln(os.Stderr, errs)
+			return fmt.Errorf("%s: %d problem(s) found", cf, len(errs))
+		}
+		fmt.Printf("%s: OK\n", cf)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(configCmd)
+	configCmd.AddCommand(configValidateCmd)
+}
+
+// validateConfig checks cfg, and the keys of the config file in use if any.
+func validateConfig(cfg *appcfg.Config) error {
+	var doc []byte
+	if cf := viper.ConfigFileUsed(); cf != "" {
+		b, err := os.ReadFile(cf)
+		if err != nil {
+			return err
+		}
+		doc = b
+	}
+	return appcfg.Validate(cfg, doc)
+}
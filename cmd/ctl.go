@@ -0,0 +1,345 @@
+/*
+MIT License
+
+Copyright (c) 2025 Mikael Schultz <mikael@conf-t.se>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+
+	appcfg "github.com/bitcanon/ircpush/pkg/config"
+	"github.com/bitcanon/ircpush/pkg/control"
+	"github.com/bitcanon/ircpush/pkg/health"
+	"github.com/bitcanon/ircpush/pkg/metrics"
+	"github.com/bitcanon/ircpush/pkg/pipeline"
+)
+
+var (
+	ctlSocket string
+	ctlJSON   bool
+)
+
+// ctlCmd represents the ctl command
+var ctlCmd = &cobra.Command{
+	Use:   "ctl",
+	Short: "Control a running ircpush serve",
+	Long: `Control a running ircpush serve through its local control socket.
+
+The socket is set with control.socket in the config file (default
+/run/ircpush/ircpush.sock) and is only accessible to its owner and the
+control.group (the ircpush group in the sample systemd unit).`,
+	Example: `  ircpush ctl status
+  ircpush ctl mute '#network' 30
+  ircpush ctl send '#server' maintenance starts in 10 minutes`,
+}
+
+// ctlVerbs are the ctl subcommands; each maps 1:1 to a control socket command.
+var ctlVerbs = []struct {
+	use   string
+	short string
+	args  cobra.PositionalArgs
+}{
+	{"status", "Show connection, channels, queue and counters", cobra.NoArgs},
+	{"reload", "Re-read the config file (like SIGHUP)", cobra.NoArgs},
+	{"join <[network/]#channel> [key]", "Join a channel until the next restart", cobra.RangeArgs(1, 2)},
+	{"part <[network/]#channel>", "Leave a channel until the next restart", cobra.ExactArgs(1)},
+	{"send <#channel[,#channel]> <message...>", "Send a message (highlighted, bypassing acl, routes and mutes)", cobra.MinimumNArgs(2)},
+	{"mute <#channel> [minutes]", "Drop messages for a channel for N minutes (default 60)", cobra.RangeArgs(1, 2)},
+	{"unmute <#channel>", "Lift a mute early", cobra.ExactArgs(1)},
+	{"highlight", "Dump the active highlight rules", cobra.NoArgs},
+}
+
+func init() {
+	rootCmd.AddCommand(ctlCmd)
+	ctlCmd.PersistentFlags().StringVar(&ctlSocket, "socket", "", "control socket (default: control.socket, then "+control.DefaultSocket+")")
+	ctlCmd.PersistentFlags().BoolVar(&ctlJSON, "json", false, "print the raw JSON response")
+
+	for _, v := range ctlVerbs {
+		name, _, _ := strings.Cut(v.use, " ")
+		ctlCmd.AddCommand(&cobra.Command{
+			Use:          v.use,
+			Short:        v.short,
+			Args:         v.args,
+			SilenceUsage: true,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				return runCtl(name, args)
+			},
+		})
+	}
+}
+
+// runCtl sends one command to the control socket and prints the result.
+func runCtl(verb string, args []string) error {
+	path := ctlSocket
+	if path == "" {
+		path = viper.GetString("control.socket")
+	}
+	if path == "" {
+		path = control.DefaultSocket
+	}
+	data, err := control.Call(path, control.Request{Cmd: verb, Args: args}, 10*time.Second)
+	if err != nil {
+		return fmt.Errorf("%s: %w", verb, err)
+	}
+	if ctlJSON {
+		fmt.Println(string(data))
+		return nil
+	}
+	if verb == "status" {
+		var st ctlStatus
+		if err := json.Unmarshal(data, &st); err != nil {
+			return err
+		}
+		printStatus(os.Stdout, st)
+		return nil
+	}
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		fmt.Println(string(data))
+		return nil
+	}
+	fmt.Println(strings.TrimRight(text, "\n"))
+	return nil
+}
+
+// ctlStatus is the result of the status command.
+type ctlStatus struct {
+	Version       string               `json:"version"`
+	UptimeSeconds float64              `json:"uptime_seconds"`
+	IRC           health.Report        `json:"irc"`
+	Queue         ctlQueue             `json:"queue"`
+	Mutes         map[string]time.Time `json:"mutes"`
+	SpoolBytes    int64                `json:"spool_pending_bytes"`
+	Counters      map[string]float64   `json:"counters"`
+}
+
+type ctlQueue struct {
+	Depth      int    `json:"depth"`
+	Capacity   int    `json:"capacity"`
+	Sent       uint64 `json:"sent"`
+	Dropped    uint64 `json:"dropped"`
+	Suppressed uint64 `json:"suppressed"`
+}
+
+// controlDeps is what the control commands of ircpush serve operate on.
+type controlDeps struct {
+	nets   []*ircNetwork // the primary network first
+	pipe   *pipeline.Pipeline
+	health *health.Checker
+	reload func() error
+	rules  func() []appcfg.HighlightRule
+	start  time.Time
+}
+
+// registerControl wires the ctl verbs to the running components.
+func registerControl(srv *control.Server, d controlDeps) {
+	srv.Handle("status", func([]string) (any, error) {
+		st := ctlStatus{
+			Version:       appVersion(),
+			UptimeSeconds: time.Since(d.start).Round(time.Second).Seconds(),
+			IRC:           d.health.Report(),
+			Mutes:         d.pipe.Mutes(),
+			Counters: map[string]float64{
+				"lines_received": metrics.LinesReceived.Sum(),
+				"lines_dropped":  metrics.LinesDropped.Sum(),
+				"messages_sent":  metrics.IRCMessagesSent.Sum(),
+				"reconnects":     metrics.IRCReconnects.Sum(),
+			},
+		}
+		// Queues and spools of all networks add up
+		for _, n := range d.nets {
+			q := n.cli.QueueStats()
+			st.Queue.Depth += q.Depth
+			st.Queue.Capacity += q.Capacity
+			st.Queue.Sent += q.Sent
+			st.Queue.Dropped += q.Dropped
+			st.Queue.Suppressed += q.Suppressed
+			if n.spool != nil {
+				st.SpoolBytes += n.spool.Pending()
+			}
+		}
+		return st, nil
+	})
+	srv.Handle("reload", func([]string) (any, error) {
+		if err := d.reload(); err != nil {
+			return nil, err
+		}
+		return "config reloaded", nil
+	})
+	srv.Handle("join", func(args []string) (any, error) {
+		if len(args) < 1 || len(args) > 2 {
+			return nil, fmt.Errorf("usage: join <[network/]#channel> [key]")
+		}
+		n, ch := findNetwork(d.nets, args[0])
+		key := ""
+		if len(args) == 2 {
+			key = args[1]
+		}
+		n.cli.Join(ch, key)
+		d.pipe.SetChannels(networkChannels(d.nets, d.pipe))
+		return "joining " + d.pipe.Qualify(n.name, ch), nil
+	})
+	srv.Handle("part", func(args []string) (any, error) {
+		if len(args) != 1 {
+			return nil, fmt.Errorf("usage: part <[network/]#channel>")
+		}
+		n, ch := findNetwork(d.nets, args[0])
+		name := d.pipe.Qualify(n.name, ch)
+		if !n.cli.Part(ch, "ircpush ctl part") {
+			return nil, fmt.Errorf("%s is not in the channel list", name)
+		}
+		d.pipe.SetChannels(networkChannels(d.nets, d.pipe))
+		return "left " + name, nil
+	})
+	srv.Handle("send", func(args []string) (any, error) {
+		if len(args) < 2 {
+			return nil, fmt.Errorf("usage: send <#channel[,#channel]> <message>")
+		}
+		var chans []string
+		for _, ch := range strings.Split(args[0], ",") {
+			if ch = pipeline.ChannelName(ch); ch != "" {
+				chans = append(chans, ch)
+			}
+		}
+		msg := strings.TrimSpace(strings.Join(args[1:], " "))
+		if msg == "" {
+			return nil, fmt.Errorf("empty message")
+		}
+		if err := d.pipe.Send(chans, msg); err != nil {
+			return nil, err
+		}
+		return "sent to " + strings.Join(chans, ", "), nil
+	})
+	srv.Handle("mute", func(args []string) (any, error) {
+		if len(args) < 1 || len(args) > 2 {
+			return nil, fmt.Errorf("usage: mute <#channel> [minutes]")
+		}
+		mins := 60
+		if len(args) == 2 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("invalid minutes %q", args[1])
+			}
+			mins = n
+		}
+		ch := pipeline.ChannelName(args[0])
+		d.pipe.Mute(ch, time.Duration(mins)*time.Minute)
+		return fmt.Sprintf("%s muted for %d min (until %s)", ch, mins, time.Now().Add(time.Duration(mins)*time.Minute).Format("15:04")), nil
+	})
+	srv.Handle("unmute", func(args []string) (any, error) {
+		if len(args) != 1 {
+			return nil, fmt.Errorf("usage: unmute <#channel>")
+		}
+		ch := pipeline.ChannelName(args[0])
+		if !d.pipe.Unmute(ch) {
+			return nil, fmt.Errorf("%s is not muted", ch)
+		}
+		return ch + " unmuted", nil
+	})
+	srv.Handle("highlight", func([]string) (any, error) {
+		out, err := yaml.Marshal(map[string]any{"rules": d.rules()})
+		if err != nil {
+			return nil, err
+		}
+		return string(out), nil
+	})
+}
+
+// printNetwork renders the connection and channel lines of one network;
+// prefix qualifies its channel names the way mutes are keyed.
+func printNetwork(w io.Writer, r health.Report, prefix string, mutes map[string]time.Time) {
+	label := "irc:"
+	if prefix != "" {
+		label = "irc " + r.Network + ":"
+	}
+	nick := r.Nick
+	if r.WantNick != "" {
+		nick += " (reclaiming " + r.WantNick + ")"
+	}
+	if r.LagSeconds != nil {
+		nick += fmt.Sprintf(", lag %s", time.Duration(*r.LagSeconds*float64(time.Second)).Round(time.Millisecond))
+	}
+	fmt.Fprintf(w, "%-10s %s, %s as %s (%s in this state)\n", label, r.Status, r.Server, nick, secondsText(r.StateSeconds))
+	var chans []string
+	for _, ch := range r.Channels {
+		state := "joined"
+		if !ch.Joined {
+			state = "not joined"
+			if ch.Error != "" {
+				state += ": " + ch.Error
+			}
+			if ch.RetryInSeconds != nil {
+				state += ", retry in " + secondsText(*ch.RetryInSeconds)
+			}
+		}
+		if until, ok := mutes[strings.ToLower(prefix+ch.Name)]; ok {
+			state += ", muted until " + until.Local().Format("15:04")
+		}
+		chans = append(chans, fmt.Sprintf("%s (%s)", ch.Name, state))
+	}
+	fmt.Fprintf(w, "%-10s %s\n", "channels:", strings.Join(chans, ", "))
+}
+
+// printStatus renders the status command for humans.
+func printStatus(w io.Writer, st ctlStatus) {
+	r := st.IRC
+	fmt.Fprintf(w, "version:   %s (up %s)\n", st.Version, time.Duration(st.UptimeSeconds)*time.Second)
+	printNetwork(w, r, "", st.Mutes)
+	for _, n := range r.Networks {
+		printNetwork(w, n, n.Network+"/", st.Mutes)
+	}
+	if r.LastSendSeconds != nil {
+		fmt.Fprintf(w, "last send: %s ago\n", secondsText(*r.LastSendSeconds))
+	} else {
+		fmt.Fprintln(w, "last send: never")
+	}
+	q := st.Queue
+	fmt.Fprintf(w, "queue:     %d/%d queued, %d sent, %d dropped, %d suppressed\n", q.Depth, q.Capacity, q.Sent, q.Dropped, q.Suppressed)
+	if st.SpoolBytes > 0 {
+		fmt.Fprintf(w, "spool:     %d bytes pending\n", st.SpoolBytes)
+	}
+	keys := make([]string, 0, len(st.Counters))
+	for k := range st.Counters {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var counters []string
+	for _, k := range keys {
+		counters = append(counters, fmt.Sprintf("%s=%g", k, st.Counters[k]))
+	}
+	fmt.Fprintf(w, "counters:  %s\n", strings.Join(counters, " "))
+}
+
+func secondsText(s float64) string {
+	return time.Duration(s * float64(time.Second)).Round(time.Second).String()
+}
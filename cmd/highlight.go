@@ -0,0 +1,170 @@
+/*
+MIT License
+
+Copyright (c) 2025 Mikael Schultz <mikael@conf-t.se>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	appcfg "github.com/bitcanon/ircpush/pkg/config"
+	"github.com/bitcanon/ircpush/pkg/highlight"
+	"github.com/bitcanon/ircpush/pkg/ircfmt"
+	"github.com/bitcanon/ircpush/pkg/redact"
+)
+
+var (
+	hlChannel string
+	hlExplain bool
+)
+
+// highlightCmd groups highlighting tools
+var highlightCmd = &cobra.Command{
+	Use:   "highlight",
+	Short: "Highlighting rule tools",
+}
+
+var highlightTestCmd = &cobra.Command{
+	Use:   "test [file|-]",
+	Short: "Preview the highlight rules on sample lines in the terminal",
+	Long: `Run lines from a file (or stdin) through the highlight rules of the
+config file and print them with the IRC formatting rendered as ANSI colors.
+The redaction rules are applied first, as they are when serving.
+
+With --explain, each line is followed by the rules that matched it, the
+spans they style, matches lost to rules with higher priority, and the rules
+skipped because of their channel filters.`,
+	Example: `  ircpush highlight test --channel '#network' samples.log
+  echo 'fw1: DROP in:wan0 out:lan1 proto tcp 10.0.0.1:443' | ircpush highlight test --explain`,
+	Args:         cobra.MaximumNArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var cfg appcfg.Config
+		if err := viper.Unmarshal(&cfg); err != nil {
+			return fmt.Errorf("unmarshal config: %w", err)
+		}
+		// Rules with problems are skipped by the highlighter; say which
+		if err := appcfg.Validate(&cfg, nil); err != nil {
+			for _, line := range strings.Split(err.Error(), "\n") {
+				if strings.HasPrefix(line, "highlight.") {
+					fmt.Fprintf(os.Stderr, "warning: %s (rule skipped)\n", line)
+				}
+			}
+		}
+
+		in := io.Reader(os.Stdin)
+		if len(args) == 1 && args[0] != "-" {
+			f, err := os.Open(args[0])
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			in = f
+		}
+
+		if hlExplain && hlChannel == "" {
+			fmt.Fprintln(os.Stderr, "note: no --channel given, rules with channel filters are skipped")
+		}
+
+		rd, err := redact.New(cfg.Redact)
+		if err != nil {
+			return err
+		}
+		hl := highlight.New(cfg.Highlight)
+		sc := bufio.NewScanner(in)
+		sc.Buffer(make([]byte, 64*1024), 1024*1024)
+		for sc.Scan() {
+			line := rd.Apply(hlChannel, sc.Text())
+			fmt.Println(ircfmt.ANSI(hl.ApplyFor(hlChannel, line)))
+			if hlExplain {
+				explainLine(os.Stdout, cfg.Highlight.Rules, hl.Explain(hlChannel, line), line)
+			}
+		}
+		return sc.Err()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(highlightCmd)
+	highlightCmd.AddCommand(highlightTestCmd)
+	highlightTestCmd.Flags().StringVar(&hlChannel, "channel", "", "target channel for channel-specific rules (empty: only rules without channel filters)")
+	highlightTestCmd.Flags().BoolVar(&hlExplain, "explain", false, "show which rules matched which spans and which were skipped")
+}
+
+// explainLine prints the Explain result for line, one rule per row.
+func explainLine(w io.Writer, rules []appcfg.HighlightRule, matches []highlight.Match, line string) {
+	if len(matches) == 0 {
+		fmt.Fprintln(w, "  (no rule matched)")
+		return
+	}
+	for _, m := range matches {
+		r := rules[m.Rule]
+		kind := r.Kind
+		if kind == "" {
+			kind = "word"
+		}
+		what := spanList(line, m.Spans, m.WholeLine)
+		switch {
+		case m.Skipped:
+			fmt.Fprintf(w, "  rule %d (%s %q): skipped by channel filters %s, would style %s\n",
+				m.Rule, kind, r.Pattern, channelFilters(r), what)
+		case len(m.Spans) == 0:
+			fmt.Fprintf(w, "  rule %d (%s %q): lost to higher priority rules at %s\n",
+				m.Rule, kind, r.Pattern, spanList(line, m.Dropped, m.WholeLine))
+		case len(m.Dropped) > 0:
+			fmt.Fprintf(w, "  rule %d (%s %q): %s (lost to higher priority rules at %s)\n",
+				m.Rule, kind, r.Pattern, what, spanList(line, m.Dropped, m.WholeLine))
+		default:
+			fmt.Fprintf(w, "  rule %d (%s %q): %s\n", m.Rule, kind, r.Pattern, what)
+		}
+	}
+}
+
+// spanList formats spans of line as "start-end \"text\"" items.
+func spanList(line string, spans [][2]int, wholeLine bool) string {
+	if wholeLine {
+		return "whole line"
+	}
+	var items []string
+	for _, sp := range spans {
+		items = append(items, fmt.Sprintf("%d-%d %q", sp[0], sp[1], line[sp[0]:sp[1]]))
+	}
+	return strings.Join(items, ", ")
+}
+
+func channelFilters(r appcfg.HighlightRule) string {
+	var parts []string
+	if len(r.Channels) > 0 {
+		parts = append(parts, "channels="+strings.Join(r.Channels, ","))
+	}
+	if len(r.ExcludeChannels) > 0 {
+		parts = append(parts, "exclude_channels="+strings.Join(r.ExcludeChannels, ","))
+	}
+	return "(" + strings.Join(parts, " ") + ")"
+}
@@ -0,0 +1,176 @@
+/*
+MIT License
+
+Copyright (c) 2025 Mikael Schultz <mikael@conf-t.se>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package cmd
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	appcfg "github.com/bitcanon/ircpush/pkg/config"
+	amin "github.com/bitcanon/ircpush/pkg/inputs/alertmanager"
+	httpin "github.com/bitcanon/ircpush/pkg/inputs/http"
+	sysin "github.com/bitcanon/ircpush/pkg/inputs/syslog"
+	tcpin "github.com/bitcanon/ircpush/pkg/inputs/tcp"
+	"github.com/bitcanon/ircpush/pkg/pipeline"
+)
+
+// defaultShutdownTimeout is used when shutdown_timeout is 0.
+const defaultShutdownTimeout = 10 * time.Second
+
+// shutdownTimeout returns how long stopping may take (shutdown_timeout).
+func shutdownTimeout(cfg appcfg.Config) time.Duration {
+	if cfg.ShutdownTimeout > 0 {
+		return cfg.ShutdownTimeout
+	}
+	return defaultShutdownTimeout
+}
+
+// inputServer is an input that can be shut down gracefully.
+type inputServer interface {
+	Shutdown(ctx context.Context) error
+}
+
+// shutdownInputs shuts down inputs concurrently, letting their open
+// connections finish until ctx is done.
+func shutdownInputs(ctx context.Context, inputs ...inputServer) {
+	var wg sync.WaitGroup
+	for _, s := range inputs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = s.Shutdown(ctx)
+		}()
+	}
+	wg.Wait()
+}
+
+// drain shuts down an input removed by a reload, letting its open
+// connections finish for up to grace.
+func drain(s inputServer, grace time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), grace)
+	defer cancel()
+	_ = s.Shutdown(ctx)
+}
+
+// startTCP builds the tcp input from its config section and starts it in
+// place of prev (nil at startup). The new listener binds before prev is
+// stopped, so on error prev keeps running. An empty listen address drains
+// prev for up to grace and returns nil.
+func startTCP(ctx context.Context, c appcfg.TCPConfig, pipe *pipeline.Pipeline, logger *log.Logger, prev *tcpin.Server, grace time.Duration) (*tcpin.Server, error) {
+	if c.Listen == "" {
+		if prev != nil {
+			drain(prev, grace)
+		}
+		return nil, nil
+	}
+	s := &tcpin.Server{
+		ListenAddr:   c.Listen,
+		Pipeline:     pipe,
+		MaxLineBytes: c.MaxLineBytes,
+		Logger:       logger,
+
+		TLSCert:              c.TLSCert,
+		TLSKey:               c.TLSKey,
+		TLSClientCA:          c.TLSClientCA,
+		TLSRequireClientCert: c.TLSRequireClientCert,
+
+		Replaces: prev,
+	}
+	if err := s.Start(ctx); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// startHTTP is startTCP for the http input.
+func startHTTP(ctx context.Context, c appcfg.HTTPConfig, pipe *pipeline.Pipeline, logger *log.Logger, prev *httpin.Server, grace time.Duration) (*httpin.Server, error) {
+	if c.Listen == "" {
+		if prev != nil {
+			drain(prev, grace)
+		}
+		return nil, nil
+	}
+	s := &httpin.Server{
+		ListenAddr:   c.Listen,
+		Pipeline:     pipe,
+		MaxBodyBytes: c.MaxBodyBytes,
+		Logger:       logger,
+		Replaces:     prev,
+	}
+	if err := s.Start(ctx); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// startAlertmanager is startTCP for the alertmanager input.
+func startAlertmanager(ctx context.Context, c appcfg.AlertmanagerConfig, pipe *pipeline.Pipeline, logger *log.Logger, prev *amin.Server, grace time.Duration) (*amin.Server, error) {
+	if c.Listen == "" {
+		if prev != nil {
+			drain(prev, grace)
+		}
+		return nil, nil
+	}
+	renderer, err := amin.NewRenderer(c)
+	if err != nil {
+		return nil, err
+	}
+	s := &amin.Server{
+		ListenAddr:   c.Listen,
+		Path:         c.Path,
+		Pipeline:     pipe,
+		Renderer:     renderer,
+		MaxBodyBytes: c.MaxBodyBytes,
+		Logger:       logger,
+		Replaces:     prev,
+	}
+	if err := s.Start(ctx); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// startSyslog is startTCP for the syslog input.
+func startSyslog(ctx context.Context, c appcfg.SyslogConfig, pipe *pipeline.Pipeline, logger *log.Logger, prev *sysin.Server, grace time.Duration) (*sysin.Server, error) {
+	if c.Listen == "" {
+		if prev != nil {
+			drain(prev, grace)
+		}
+		return nil, nil
+	}
+	s := &sysin.Server{
+		ListenAddr: c.Listen,
+		Pipeline:   pipe,
+		Channels:   c.Channels,
+		Template:   c.Template,
+		Logger:     logger,
+		Replaces:   prev,
+	}
+	if err := s.Start(ctx); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
@@ -0,0 +1,231 @@
+/*
+MIT License
+
+Copyright (c) 2025 Mikael Schultz <mikael@conf-t.se>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	appcfg "github.com/bitcanon/ircpush/pkg/config"
+	"github.com/bitcanon/ircpush/pkg/irc"
+	"github.com/bitcanon/ircpush/pkg/pipeline"
+	"github.com/bitcanon/ircpush/pkg/spool"
+)
+
+// ircNetwork is one IRC network of serve: its client and optional spool.
+type ircNetwork struct {
+	name  string
+	cfg   appcfg.IRCConfig // settings in use, updated on reload
+	cli   *irc.Client
+	spool *spool.Spool // nil when disabled
+}
+
+// out returns where messages for the network go: the spool when enabled,
+// so nothing is lost while disconnected, else the client.
+func (n *ircNetwork) out() pipeline.Sender {
+	if n.spool != nil {
+		return n.spool
+	}
+	return n.cli
+}
+
+// startNetworks creates the client (and spool) of every IRC network and
+// connects them. It waits for the primary network; the others connect in
+// the background and keep retrying on their own, so a network that is down
+// does not hold up the rest.
+func startNetworks(cfg *appcfg.Config, slog *log.Logger) ([]*ircNetwork, error) {
+	confs := cfg.IRCNetworks()
+	var nets []*ircNetwork
+	closeAll := func() {
+		for _, n := range nets {
+			n.close()
+		}
+	}
+	for i, nc := range confs {
+		n := &ircNetwork{name: nc.Name, cfg: nc}
+		tag, prefix, logw := "", "", io.Writer(os.Stderr)
+		if len(confs) > 1 {
+			tag = "[" + nc.Name + "] "
+			logw = &prefixWriter{w: os.Stderr, prefix: tag}
+		}
+		if i > 0 {
+			prefix = nc.Name + "/"
+		}
+		// The spool (if enabled) is replayed once the client is registered
+		// and has joined all channels again
+		cli, err := irc.New(nc, irc.Handlers{
+			Connected: func() { fmt.Fprintf(os.Stderr, "%sirc: connected, joining channels...\n", tag) },
+			Ready: func() {
+				if n.spool != nil {
+					n.spool.Replay()
+				}
+			},
+			Welcome: func(raw string) { fmt.Fprintf(os.Stderr, "%s<- %s\n", tag, raw) },
+			Disconnected: func() {
+				fmt.Fprintf(os.Stderr, "%sirc: disconnected (will auto-reconnect)\n", tag)
+			},
+			Error: func(text string) {
+				fmt.Fprintf(os.Stderr, "%sirc error: %s\n", tag, text)
+			},
+		}, irc.Options{
+			Logger:        logw,
+			ChannelPrefix: prefix,
+		})
+		if err != nil {
+			closeAll()
+			return nil, fmt.Errorf("%s%w", tag, err)
+		}
+		n.cli = cli
+		nets = append(nets, n)
+
+		// Other networks spool to a subdirectory named after the network
+		if cfg.Spool.Dir != "" {
+			dir := cfg.Spool.Dir
+			if i > 0 {
+				dir = filepath.Join(dir, nc.Name)
+			}
+			n.spool, err = spool.Open(dir, cli, spool.Options{
+				MaxBytes: cfg.Spool.MaxBytes,
+				MaxAge:   cfg.Spool.MaxAge,
+				Logger:   log.New(logw, "", 0),
+			})
+			if err != nil {
+				closeAll()
+				return nil, err
+			}
+		}
+	}
+
+	// Start IRC connections; wait (with timeout) for the primary one only
+	for _, n := range nets[1:] {
+		n.cli.Run()
+	}
+	ictx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	if err := nets[0].cli.Start(ictx); err != nil {
+		closeAll()
+		return nil, fmt.Errorf("irc connect: %w", err)
+	}
+	fmt.Fprintln(os.Stderr, "irc: ready")
+	return nets, nil
+}
+
+func (n *ircNetwork) close() {
+	n.cli.Close()
+	if n.spool != nil {
+		_ = n.spool.Close()
+	}
+}
+
+// shutdownNetworks leaves all networks in parallel: each sends its queued
+// messages and quits, until ctx is done. Messages left behind are reported.
+func shutdownNetworks(ctx context.Context, nets []*ircNetwork) {
+	var wg sync.WaitGroup
+	for _, n := range nets {
+		wg.Add(1)
+		go func(n *ircNetwork) {
+			defer wg.Done()
+			err := n.cli.Shutdown(ctx)
+			if err == nil {
+				return
+			}
+			tag := ""
+			if len(nets) > 1 {
+				tag = "[" + n.name + "] "
+			}
+			for _, line := range strings.Split(err.Error(), "\n") {
+				fmt.Fprintf(os.Stderr, "%s%s\n", tag, line)
+			}
+		}(n)
+	}
+	wg.Wait()
+}
+
+// networkChannels returns the channels of all networks as the pipeline
+// names them ("network/#channel" on other than the primary network).
+func networkChannels(nets []*ircNetwork, pipe *pipeline.Pipeline) []string {
+	var out []string
+	for _, n := range nets {
+		for _, ch := range n.cli.Channels() {
+			out = append(out, pipe.Qualify(n.name, ch))
+		}
+	}
+	return out
+}
+
+// networkNames returns the names of nets, the primary network first.
+func networkNames(nets []*ircNetwork) []string {
+	out := make([]string, len(nets))
+	for i, n := range nets {
+		out[i] = n.name
+	}
+	return out
+}
+
+// findNetwork returns the network of a "#channel" or "network/#channel"
+// target and the channel name on it. A target whose prefix is not a network
+// is a channel on the primary network.
+func findNetwork(nets []*ircNetwork, target string) (*ircNetwork, string) {
+	net, ch := appcfg.SplitChannel(target, networkNames(nets))
+	for _, n := range nets {
+		if net != "" && strings.EqualFold(n.name, net) {
+			return n, ch
+		}
+	}
+	return nets[0], ensureChanPrefix(ch)
+}
+
+// sameNetworks reports whether a and b name the same networks in the same order.
+func sameNetworks(a []*ircNetwork, b []appcfg.IRCConfig) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !strings.EqualFold(a[i].name, b[i].Name) {
+			return false
+		}
+	}
+	return true
+}
+
+// prefixWriter prefixes each write (one log line) with the network name.
+type prefixWriter struct {
+	w      io.Writer
+	prefix string
+}
+
+func (p *prefixWriter) Write(b []byte) (int, error) {
+	// One write per line, so lines of different networks don't interleave
+	if _, err := p.w.Write(append([]byte(p.prefix), b...)); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
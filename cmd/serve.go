@@ -29,14 +29,21 @@ import (
 	"log"
 	"os"
 	"os/signal"
+	"reflect"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
+	"github.com/bitcanon/ircpush/pkg/acl"
 	appcfg "github.com/bitcanon/ircpush/pkg/config"
+	"github.com/bitcanon/ircpush/pkg/control"
+	"github.com/bitcanon/ircpush/pkg/dedup"
+	"github.com/bitcanon/ircpush/pkg/health"
 	"github.com/bitcanon/ircpush/pkg/highlight"
-	tcpin "github.com/bitcanon/ircpush/pkg/inputs/tcp"
-	"github.com/bitcanon/ircpush/pkg/irc"
+	"github.com/bitcanon/ircpush/pkg/metrics"
+	"github.com/bitcanon/ircpush/pkg/pipeline"
+	"github.com/bitcanon/ircpush/pkg/redact"
 	"github.com/fsnotify/fsnotify"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -44,14 +51,15 @@ import (
 
 var serveCmd = &cobra.Command{
 	Use:   "serve",
-	Short: "Run TCP listener and forward incoming messages to IRC",
-	Long: `Run TCP listener and forward incoming messages to IRC.
+	Short: "Run input listeners and forward incoming messages to IRC",
+	Long: `Run input listeners and forward incoming messages to IRC.
 
 The serve command starts the main ircpush service, which listens for incoming
-text messages via a TCP listener and forwards them to configured IRC channels
+text messages via a TCP listener (and optionally an HTTP webhook, an Alertmanager
+webhook receiver and UDP syslog) and forwards them to configured IRC channels
 after applying powerful regex syntax highlighting rules.
 
-Message -> TCP listener -> Highlighting -> IRC channels
+Message -> TCP / HTTP / Alertmanager / UDP syslog -> Highlighting -> IRC channels
 
 The command loads its configuration from the config file (default: ./config.yaml)
 and supports hot-reloading of the highlighting rules when the config file changes
@@ -61,96 +69,351 @@ and supports hot-reloading of the highlighting rules when the config file change
 		if cf := viper.ConfigFileUsed(); cf != "" {
 			fmt.Fprintf(os.Stderr, "Config file: %s\n", cf)
 		}
+		start := time.Now()
 		var cfg appcfg.Config
 		if err := viper.Unmarshal(&cfg); err != nil {
 			return fmt.Errorf("unmarshal config: %w", err)
 		}
+		if err := validateConfig(&cfg); err != nil {
+			return fmt.Errorf("invalid config:\n%w", err)
+		}
 		if cfg.TCP.MaxLineBytes == 0 {
 			cfg.TCP.MaxLineBytes = 64 * 1024
 		}
 		// Print effective settings to catch env overrides
-		fmt.Fprintf(os.Stderr, "IRC server: %s\n", cfg.IRC.Server)
-		fmt.Fprintf(os.Stderr, "TLS: %v (skip_verify=%v)\n", cfg.IRC.TLS, cfg.IRC.TLSSkipVerify)
-		fmt.Fprintf(os.Stderr, "Nick: %s, Channels: %s\n", cfg.IRC.Nick, strings.Join(cfg.IRC.Channels, ", "))
-		fmt.Fprintf(os.Stderr, "TCP listen: %s\n", cfg.TCP.Listen)
-		fmt.Fprintf(os.Stderr, "IRC msg policy: max_len=%d split_long=%v\n", cfg.IRC.MaxMessageLen, cfg.IRC.SplitLong)
+		networks := cfg.IRCNetworks()
+		for _, nc := range networks {
+			tag := ""
+			if len(networks) > 1 {
+				tag = "[" + nc.Name + "] "
+			}
+			fmt.Fprintf(os.Stderr, "%sIRC server: %s\n", tag, nc.Server)
+			fmt.Fprintf(os.Stderr, "%sTLS: %v (skip_verify=%v)\n", tag, nc.TLS, nc.TLSSkipVerify)
+			fmt.Fprintf(os.Stderr, "%sSASL: %s (required=%v)\n", tag, saslSummary(nc), nc.SASLRequired)
+			fmt.Fprintf(os.Stderr, "%sNick: %s, Channels: %s\n", tag, nc.Nick, strings.Join(nc.Channels, ", "))
+			fmt.Fprintf(os.Stderr, "%sIRC msg policy: max_len=%d split_long=%v formatting=%q (empty=extended)\n", tag, nc.MaxMessageLen, nc.SplitLong, nc.Formatting)
+			fmt.Fprintf(os.Stderr, "%sIRC send queue: %s\n", tag, queueSummary(nc.Queue))
+		}
+		fmt.Fprintf(os.Stderr, "TCP listen: %s (tls=%v, client_ca=%q, require_client_cert=%v)\n",
+			cfg.TCP.Listen, cfg.TCP.TLSCert != "", cfg.TCP.TLSClientCA, cfg.TCP.TLSRequireClientCert)
+		if cfg.HTTP.Listen != "" {
+			fmt.Fprintf(os.Stderr, "HTTP listen: %s (max_body_bytes=%d, 0=default 65536)\n", cfg.HTTP.Listen, cfg.HTTP.MaxBodyBytes)
+		}
+		if cfg.Alertmanager.Listen != "" {
+			fmt.Fprintf(os.Stderr, "Alertmanager listen: %s (routes=%d, max_lines=%d)\n", cfg.Alertmanager.Listen, len(cfg.Alertmanager.Routes), cfg.Alertmanager.MaxLines)
+		}
+		if cfg.Syslog.Listen != "" {
+			fmt.Fprintf(os.Stderr, "Syslog listen: %s (udp)\n", cfg.Syslog.Listen)
+		}
+		if len(cfg.ACL) > 0 {
+			fmt.Fprintf(os.Stderr, "ACL: %d rules (unmatched sources are refused)\n", len(cfg.ACL))
+		}
+		if len(cfg.Routes.Rules) > 0 || len(cfg.Routes.Default) > 0 {
+			fmt.Fprintf(os.Stderr, "Routes: %d rules, default: %s\n", len(cfg.Routes.Rules), strings.Join(cfg.Routes.Default, ", "))
+		}
+		if len(cfg.Redact.Rules) > 0 {
+			fmt.Fprintf(os.Stderr, "Redact: %d rules\n", len(cfg.Redact.Rules))
+		}
+		if cfg.Dedup.Enabled {
+			fmt.Fprintf(os.Stderr, "Dedup: window=%s (0=default 5m), normalize=%d patterns, channels=%s\n",
+				cfg.Dedup.Window, len(cfg.Dedup.Normalize), strings.Join(cfg.Dedup.Channels, ", "))
+		}
+		if cfg.Metrics.Listen != "" {
+			fmt.Fprintf(os.Stderr, "Metrics listen: %s (also /healthz, /readyz; unhealthy_after=%s, 0=default 5m)\n", cfg.Metrics.Listen, cfg.Metrics.UnhealthyAfter)
+		}
+		if cfg.Control.Socket != "" {
+			fmt.Fprintf(os.Stderr, "Control socket: %s (group=%q)\n", cfg.Control.Socket, cfg.Control.Group)
+		}
+		if cfg.Spool.Dir != "" {
+			fmt.Fprintf(os.Stderr, "Spool: %s (max_bytes=%d, max_age=%s, 0=defaults 64MiB/24h)\n", cfg.Spool.Dir, cfg.Spool.MaxBytes, cfg.Spool.MaxAge)
+		}
 		fmt.Fprintf(os.Stderr, "TCP max_line_bytes: %d (0=default 65536)\n", cfg.TCP.MaxLineBytes)
 
-		// Build IRC client
-		cli, err := irc.New(cfg.IRC, irc.Handlers{
-			Connected: func() { fmt.Fprintln(os.Stderr, "irc: connected, joining channels...") },
-			Welcome:   func(raw string) { fmt.Fprintf(os.Stderr, "<- %s\n", raw) },
-			Disconnected: func() {
-				fmt.Fprintln(os.Stderr, "irc: disconnected (will auto-reconnect)")
-			},
-			Error: func(text string) {
-				fmt.Fprintf(os.Stderr, "irc error: %s\n", text)
-			},
-		}, irc.Options{
-			DisableFlood: false,
-			Logger:       os.Stderr,
-		})
+		if cfg.TCP.Listen == "" && cfg.HTTP.Listen == "" && cfg.Alertmanager.Listen == "" && cfg.Syslog.Listen == "" {
+			return fmt.Errorf("no inputs configured (set tcp.listen, http.listen, alertmanager.listen and/or syslog.listen)")
+		}
+
+		// Create a logger that writes to stderr (captured by systemd)
+		slog := log.New(os.Stderr, "", 0)
+
+		// Connect to the IRC networks; messages go through each network's
+		// spool when enabled, so nothing is lost while disconnected
+		nets, err := startNetworks(&cfg, slog)
 		if err != nil {
 			return err
 		}
-		defer cli.Close()
-
-		// Start IRC connection with timeout
-		ictx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
-		defer cancel()
-		if err := cli.Start(ictx); err != nil {
-			return fmt.Errorf("irc connect: %w", err)
+		defer func() {
+			for _, n := range nets {
+				n.close()
+			}
+		}()
+		senders := make([]pipeline.Network, len(nets))
+		for i, n := range nets {
+			senders[i] = pipeline.Network{Name: n.name, Out: n.out()}
 		}
-		fmt.Fprintln(os.Stderr, "irc: ready")
+		out := pipeline.NewNetworks(senders...)
+		out.Logger = slog
 
-		// Highlighter from config
-		hl := highlight.New(cfg.Highlight)
-
-		// Start TCP server
 		ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 		defer stop()
 
-		// Create a logger that writes to stderr (captured by systemd)
-		slog := log.New(os.Stderr, "", 0)
+		// Pipeline shared by all inputs: highlighting -> (spool) -> IRC
+		pipe := pipeline.New(out, highlight.New(cfg.Highlight))
+		pipe.Logger = slog
+		pipe.Primary = nets[0].name
+		pipe.Networks = networkNames(nets)
+		pipe.SetChannels(networkChannels(nets, pipe))
+		if len(cfg.ACL) > 0 {
+			list, err := acl.New(cfg.ACL)
+			if err != nil {
+				return err
+			}
+			pipe.SetACL(list)
+		}
+		if len(cfg.Routes.Rules) > 0 || len(cfg.Routes.Default) > 0 {
+			router, err := pipeline.NewRouter(cfg.Routes)
+			if err != nil {
+				return err
+			}
+			pipe.SetRouter(router)
+		}
+		if len(cfg.Redact.Rules) > 0 {
+			rd, err := redact.New(cfg.Redact)
+			if err != nil {
+				return err
+			}
+			pipe.SetRedactor(rd)
+		}
+		if cfg.Dedup.Enabled {
+			dd, err := dedup.New(cfg.Dedup)
+			if err != nil {
+				return err
+			}
+			pipe.SetDedup(dd)
+		}
 
-		srv := &tcpin.Server{
-			ListenAddr:   cfg.TCP.Listen,
-			IRC:          cli,
-			HL:           hl,
-			MaxLineBytes: cfg.TCP.MaxLineBytes, // new: honor tcp.max_line_bytes
-			Logger:       slog,
+		srv, err := startTCP(ctx, cfg.TCP, pipe, slog, nil, shutdownTimeout(cfg))
+		if err != nil {
+			return err
+		}
+		web, err := startHTTP(ctx, cfg.HTTP, pipe, slog, nil, shutdownTimeout(cfg))
+		if err != nil {
+			return err
 		}
-		if err := srv.Start(ctx); err != nil {
+		am, err := startAlertmanager(ctx, cfg.Alertmanager, pipe, slog, nil, shutdownTimeout(cfg))
+		if err != nil {
+			return err
+		}
+		sys, err := startSyslog(ctx, cfg.Syslog, pipe, slog, nil, shutdownTimeout(cfg))
+		if err != nil {
 			return err
 		}
 
-		// Reload handler updates runtime parts (currently: highlighting rules)
-		reload := func(tag string) {
+		// Liveness/readiness from the IRC connection state
+		hc := &health.Checker{Source: nets[0].cli, MaxDown: cfg.Metrics.UnhealthyAfter, Logger: slog}
+		for _, n := range nets[1:] {
+			hc.Networks = append(hc.Networks, health.Network{Name: n.name, Source: n.cli})
+		}
+
+		var mon *metrics.Server
+		metrics.SetSourceLabels(cfg.Metrics.SourceLabels)
+		if cfg.Metrics.Listen != "" {
+			mon = &metrics.Server{
+				ListenAddr: cfg.Metrics.Listen,
+				Path:       cfg.Metrics.Path,
+				Logger:     slog,
+			}
+			mon.Handle("/healthz", hc.LiveHandler())
+			mon.Handle("/readyz", hc.ReadyHandler())
+			if err := mon.Start(ctx); err != nil {
+				return err
+			}
+		}
+
+		// Reload handler updates runtime parts (highlight, redact, acl, routes, dedup).
+		// cfgMu serializes reloads and guards cfg for the control socket.
+		var cfgMu sync.Mutex
+		reload := func(tag string) error {
+			cfgMu.Lock()
+			defer cfgMu.Unlock()
 			var newCfg appcfg.Config
 			if err := viper.Unmarshal(&newCfg); err != nil {
 				fmt.Fprintf(os.Stderr, "reload: unmarshal failed: %v\n", err)
-				return
+				return fmt.Errorf("unmarshal config: %w", err)
+			}
+			if err := validateConfig(&newCfg); err != nil {
+				fmt.Fprintf(os.Stderr, "reload: invalid config (keeping previous settings):\n%v\n", err)
+				return fmt.Errorf("invalid config:\n%w", err)
 			}
 			// Hot-reload highlight rules
-			srv.SetHighlighter(highlight.New(newCfg.Highlight))
+			pipe.SetHighlighter(highlight.New(newCfg.Highlight))
+
+			// Hot-reload redaction rules; an invalid rule keeps the previous set
+			if !reflect.DeepEqual(newCfg.Redact, cfg.Redact) {
+				if len(newCfg.Redact.Rules) == 0 {
+					pipe.SetRedactor(nil)
+				} else if rd, err := redact.New(newCfg.Redact); err != nil {
+					fmt.Fprintf(os.Stderr, "reload: %v (keeping previous redaction rules)\n", err)
+					newCfg.Redact = cfg.Redact
+				} else {
+					pipe.SetRedactor(rd)
+				}
+			}
+
+			// Hot-reload the acl; an invalid rule keeps the previous list
+			if list, err := acl.New(newCfg.ACL); err != nil {
+				fmt.Fprintf(os.Stderr, "reload: %v (keeping previous acl)\n", err)
+			} else {
+				pipe.SetACL(list)
+			}
+
+			// Hot-reload routes; an invalid rule keeps the previous set
+			if router, err := pipeline.NewRouter(newCfg.Routes); err != nil {
+				fmt.Fprintf(os.Stderr, "reload: %v (keeping previous routes)\n", err)
+			} else {
+				pipe.SetRouter(router)
+			}
+
+			// Hot-reload dedup settings (pending repeat counts are flushed)
+			if !reflect.DeepEqual(newCfg.Dedup, cfg.Dedup) {
+				if !newCfg.Dedup.Enabled {
+					pipe.SetDedup(nil)
+					fmt.Fprintln(os.Stderr, "reload: dedup disabled")
+				} else if dd, err := dedup.New(newCfg.Dedup); err != nil {
+					fmt.Fprintf(os.Stderr, "reload: dedup: %v (keeping previous settings)\n", err)
+					newCfg.Dedup = cfg.Dedup
+				} else {
+					pipe.SetDedup(dd)
+					fmt.Fprintln(os.Stderr, "reload: dedup settings applied")
+				}
+			}
+
+			// Restart inputs whose settings changed; the new listener binds
+			// before the old one closes, so on error the old input keeps running
+			if newCfg.TCP.MaxLineBytes == 0 {
+				newCfg.TCP.MaxLineBytes = 64 * 1024
+			}
+			if newCfg.TCP.Listen == "" && newCfg.HTTP.Listen == "" && newCfg.Alertmanager.Listen == "" && newCfg.Syslog.Listen == "" {
+				fmt.Fprintln(os.Stderr, "reload: no inputs configured (keeping previous inputs)")
+				newCfg.TCP, newCfg.HTTP, newCfg.Alertmanager, newCfg.Syslog = cfg.TCP, cfg.HTTP, cfg.Alertmanager, cfg.Syslog
+			}
+			if !reflect.DeepEqual(newCfg.TCP, cfg.TCP) {
+				if next, err := startTCP(ctx, newCfg.TCP, pipe, slog, srv, shutdownTimeout(newCfg)); err != nil {
+					fmt.Fprintf(os.Stderr, "reload: tcp: %v (keeping previous listener)\n", err)
+					newCfg.TCP = cfg.TCP
+				} else {
+					srv = next
+					fmt.Fprintf(os.Stderr, "reload: tcp input restarted (listen=%q)\n", newCfg.TCP.Listen)
+				}
+			}
+			if !reflect.DeepEqual(newCfg.HTTP, cfg.HTTP) {
+				if next, err := startHTTP(ctx, newCfg.HTTP, pipe, slog, web, shutdownTimeout(newCfg)); err != nil {
+					fmt.Fprintf(os.Stderr, "reload: http: %v (keeping previous listener)\n", err)
+					newCfg.HTTP = cfg.HTTP
+				} else {
+					web = next
+					fmt.Fprintf(os.Stderr, "reload: http input restarted (listen=%q)\n", newCfg.HTTP.Listen)
+				}
+			}
+			if !reflect.DeepEqual(newCfg.Alertmanager, cfg.Alertmanager) {
+				if next, err := startAlertmanager(ctx, newCfg.Alertmanager, pipe, slog, am, shutdownTimeout(newCfg)); err != nil {
+					fmt.Fprintf(os.Stderr, "reload: alertmanager: %v (keeping previous listener)\n", err)
+					newCfg.Alertmanager = cfg.Alertmanager
+				} else {
+					am = next
+					fmt.Fprintf(os.Stderr, "reload: alertmanager input restarted (listen=%q)\n", newCfg.Alertmanager.Listen)
+				}
+			}
+			if !reflect.DeepEqual(newCfg.Syslog, cfg.Syslog) {
+				if next, err := startSyslog(ctx, newCfg.Syslog, pipe, slog, sys, shutdownTimeout(newCfg)); err != nil {
+					fmt.Fprintf(os.Stderr, "reload: syslog: %v (keeping previous listener)\n", err)
+					newCfg.Syslog = cfg.Syslog
+				} else {
+					sys = next
+					fmt.Fprintf(os.Stderr, "reload: syslog input restarted (listen=%q)\n", newCfg.Syslog.Listen)
+				}
+			}
 
 			// Non-hot fields (inform user to restart if changed)
-			if newCfg.TCP.Listen != cfg.TCP.Listen {
-				fmt.Fprintf(os.Stderr, "reload: tcp.listen changed (%s -> %s), restart required\n", cfg.TCP.Listen, newCfg.TCP.Listen)
+			if newCfg.Metrics != cfg.Metrics {
+				fmt.Fprintf(os.Stderr, "reload: metrics settings changed, restart required\n")
+			}
+			if newCfg.Spool != cfg.Spool {
+				fmt.Fprintf(os.Stderr, "reload: spool settings changed, restart required\n")
 			}
-			if newCfg.IRC.Server != cfg.IRC.Server || newCfg.IRC.Nick != cfg.IRC.Nick {
-				fmt.Fprintf(os.Stderr, "reload: IRC connection settings changed, restart recommended\n")
+			// Hot-reload IRC channels, keys and message policy per network;
+			// connection settings make that network's client reconnect
+			if newNets := newCfg.IRCNetworks(); !sameNetworks(nets, newNets) {
+				fmt.Fprintln(os.Stderr, "reload: irc networks added, removed or renamed, restart required")
+			} else {
+				for i, n := range nets {
+					if reflect.DeepEqual(newNets[i], n.cfg) {
+						continue
+					}
+					if err := n.cli.Reconfigure(newNets[i]); err != nil {
+						fmt.Fprintf(os.Stderr, "reload: %s: %v (keeping previous irc settings)\n", n.name, err)
+						continue
+					}
+					n.cfg = newNets[i]
+					fmt.Fprintf(os.Stderr, "reload: irc settings applied (%s)\n", n.name)
+				}
+				pipe.SetChannels(networkChannels(nets, pipe))
+			}
+			if newCfg.Control != cfg.Control {
+				fmt.Fprintf(os.Stderr, "reload: control settings changed, restart required\n")
 			}
 			cfg = newCfg
 			fmt.Fprintf(os.Stderr, "reload: applied (%s)\n", tag)
+			return nil
+		}
+
+		// rereadConfig reloads from the config file (SIGHUP and ctl reload)
+		rereadConfig := func(tag string) error {
+			if viper.ConfigFileUsed() != "" {
+				if err := viper.ReadInConfig(); err != nil {
+					fmt.Fprintf(os.Stderr, "reload: read config failed: %v\n", err)
+					return fmt.Errorf("read config: %w", err)
+				}
+			}
+			return reload(tag)
+		}
+
+		var ctl *control.Server
+		if cfg.Control.Socket != "" {
+			ctl = &control.Server{
+				Path:   cfg.Control.Socket,
+				Group:  cfg.Control.Group,
+				Logger: slog,
+			}
+			registerControl(ctl, controlDeps{
+				nets:   nets,
+				pipe:   pipe,
+				health: hc,
+				reload: func() error { return rereadConfig("ctl") },
+				rules: func() []appcfg.HighlightRule {
+					cfgMu.Lock()
+					defer cfgMu.Unlock()
+					return cfg.Highlight.Rules
+				},
+				start: start,
+			})
+			if err := ctl.Start(ctx); err != nil {
+				return err
+			}
 		}
 
+		// systemd Type=notify: inputs are up; feed the watchdog while IRC is healthy
+		if _, err := health.Notify("READY=1"); err != nil {
+			fmt.Fprintf(os.Stderr, "sd_notify: %v\n", err)
+		}
+		go hc.Watchdog(ctx)
+
 		// Optional: auto-reload via fsnotify when enabled
 		if cfg.Highlight.AutoReload {
 			viper.WatchConfig()
 			viper.OnConfigChange(func(e fsnotify.Event) {
 				fmt.Fprintf(os.Stderr, "config: change detected (%s)\n", e.Name)
-				reload("fsnotify")
+				_ = reload("fsnotify")
 			})
 			fmt.Fprintln(os.Stderr, "config: highlight auto-reload enabled")
 		} else {
@@ -163,16 +426,54 @@ and supports hot-reloading of the highlighting rules when the config file change
 		go func() {
 			for range hupCh {
 				fmt.Fprintln(os.Stderr, "signal: SIGHUP received, reloading config")
-				reload("SIGHUP")
+				_ = rereadConfig("SIGHUP")
+				// Re-read TCP TLS certificates (e.g. after renewal); connections stay up
+				cfgMu.Lock()
+				cur := srv
+				cfgMu.Unlock()
+				if cur != nil {
+					if err := cur.ReloadTLS(); err != nil {
+						fmt.Fprintf(os.Stderr, "reload: tcp tls: %v (keeping previous certificates)\n", err)
+					}
+				}
 			}
 		}()
 
 		// Wait for termination
 		<-ctx.Done()
 		fmt.Fprintln(os.Stderr, "shutting down...")
-		_ = srv.Stop()
-		cli.Quit("shutdown")
-		time.Sleep(200 * time.Millisecond)
+		_, _ = health.Notify("STOPPING=1")
+		cfgMu.Lock() // inputs may be swapped by a reload
+		// One budget for everything: the inputs may use up to half of it to
+		// let senders finish, the networks the rest to flush and quit
+		budget := shutdownTimeout(cfg)
+		sctx, scancel := context.WithTimeout(context.Background(), budget)
+		defer scancel()
+		var inputs []inputServer
+		if srv != nil {
+			inputs = append(inputs, srv)
+		}
+		if web != nil {
+			inputs = append(inputs, web)
+		}
+		if am != nil {
+			inputs = append(inputs, am)
+		}
+		if sys != nil {
+			inputs = append(inputs, sys)
+		}
+		ictx, icancel := context.WithTimeout(sctx, budget/2)
+		shutdownInputs(ictx, inputs...)
+		icancel()
+		cfgMu.Unlock()
+		if mon != nil {
+			_ = mon.Stop()
+		}
+		if ctl != nil {
+			_ = ctl.Stop()
+		}
+		pipe.Close() // flush pending dedup summaries into the queue
+		shutdownNetworks(sctx, nets)
 		return nil
 	},
 }
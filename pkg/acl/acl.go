@@ -0,0 +1,197 @@
+/*
+MIT License
+
+Copyright (c) 2025 Mikael Schultz <mikael@conf-t.se>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package acl
+
+import (
+	"fmt"
+	"net"
+	"path/filepath"
+	"strings"
+
+	"github.com/bitcanon/ircpush/pkg/config"
+)
+
+// List decides which senders may connect and which channels they may target.
+// Rules are matched by source address and client certificate identity in
+// order; the first match decides. An empty list allows everything; otherwise
+// unmatched senders are refused.
+type List struct {
+	rules []rule
+}
+
+type rule struct {
+	nets       []*net.IPNet
+	identities []string // lower-cased glob patterns on certificate CN/SANs
+	channels   []string // lower-cased glob patterns; empty => all channels
+}
+
+// New compiles the acl config.
+func New(rules []config.ACLRule) (*List, error) {
+	l := &List{}
+	for i, r := range rules {
+		nets, err := ParseSources(r.Source)
+		if err != nil {
+			return nil, fmt.Errorf("acl: rule %d: %w", i+1, err)
+		}
+		cr := rule{nets: nets}
+		for _, id := range r.Identity {
+			if id = strings.TrimSpace(id); id != "" {
+				if _, err := filepath.Match(id, ""); err != nil {
+					return nil, fmt.Errorf("acl: rule %d: identity pattern %q: %w", i+1, id, err)
+				}
+				cr.identities = append(cr.identities, strings.ToLower(id))
+			}
+		}
+		if len(cr.nets) == 0 && len(cr.identities) == 0 {
+			return nil, fmt.Errorf("acl: rule %d: source or identity must be set", i+1)
+		}
+		for _, ch := range r.Channels {
+			if ch = strings.TrimSpace(ch); ch != "" {
+				if _, err := filepath.Match(ch, ""); err != nil {
+					return nil, fmt.Errorf("acl: rule %d: channel pattern %q: %w", i+1, ch, err)
+				}
+				cr.channels = append(cr.channels, strings.ToLower(ch))
+			}
+		}
+		l.rules = append(l.rules, cr)
+	}
+	return l, nil
+}
+
+// ParseSources parses IP addresses and CIDRs ("10.0.0.0/8", "192.0.2.7", "::1").
+func ParseSources(sources []string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, s := range sources {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		if !strings.Contains(s, "/") {
+			ip := net.ParseIP(s)
+			if ip == nil {
+				return nil, fmt.Errorf("source %q: not an IP address or CIDR", s)
+			}
+			bits := 128
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 32
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, fmt.Errorf("source %q: not an IP address or CIDR", s)
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+// Contains reports whether the host part of addr ("ip:port" or "ip") is in nets.
+func Contains(nets []*net.IPNet, addr string) bool {
+	host := addr
+	if h, _, err := net.SplitHostPort(addr); err == nil {
+		host = h
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchIdentity reports whether any of ids matches one of the lower-cased glob patterns.
+func MatchIdentity(patterns, ids []string) bool {
+	for _, id := range ids {
+		id = strings.ToLower(id)
+		for _, p := range patterns {
+			if ok, _ := filepath.Match(p, id); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (r *rule) matches(addr string, ids []string) bool {
+	if len(r.nets) > 0 && !Contains(r.nets, addr) {
+		return false
+	}
+	return len(r.identities) == 0 || MatchIdentity(r.identities, ids)
+}
+
+func (l *List) match(addr string, ids []string) *rule {
+	for i := range l.rules {
+		if l.rules[i].matches(addr, ids) {
+			return &l.rules[i]
+		}
+	}
+	return nil
+}
+
+// AllowSource reports whether addr may connect at all. It is checked before
+// a client certificate is known, so rules that require an identity admit the
+// connection; AllowPeer decides once the identity is known.
+func (l *List) AllowSource(addr string) bool {
+	if l == nil || len(l.rules) == 0 {
+		return true
+	}
+	for _, r := range l.rules {
+		if len(r.nets) == 0 || Contains(r.nets, addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowPeer reports whether addr with the given certificate identities may send at all.
+func (l *List) AllowPeer(addr string, ids []string) bool {
+	return l == nil || len(l.rules) == 0 || l.match(addr, ids) != nil
+}
+
+// AllowChannel reports whether addr with the given identities may send to channel.
+func (l *List) AllowChannel(addr string, ids []string, channel string) bool {
+	if l == nil || len(l.rules) == 0 {
+		return true
+	}
+	r := l.match(addr, ids)
+	if r == nil {
+		return false
+	}
+	if len(r.channels) == 0 {
+		return true
+	}
+	ch := strings.ToLower(channel)
+	for _, p := range r.channels {
+		if ok, _ := filepath.Match(p, ch); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,69 @@
+package acl
+
+import (
+	"testing"
+
+	"github.com/bitcanon/ircpush/pkg/config"
+)
+
+// TestList tests source and channel decisions with first-match semantics.
+func TestList(t *testing.T) {
+	l, err := New([]config.ACLRule{
+		{Source: []string{"10.1.2.3"}, Channels: []string{"#security"}},
+		{Source: []string{"10.1.0.0/16", "2001:db8::/32"}, Channels: []string{"#net*"}},
+		{Source: []string{"127.0.0.1"}},
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	// Setup test cases
+	tests := []struct {
+		name        string
+		addr        string
+		channel     string
+		wantSource  bool
+		wantChannel bool
+	}{
+		{name: "FirstMatchWins", addr: "10.1.2.3:514", channel: "#network", wantSource: true, wantChannel: false},
+		{name: "FirstMatchAllowed", addr: "10.1.2.3:514", channel: "#SECURITY", wantSource: true, wantChannel: true},
+		{name: "CIDRGlob", addr: "10.1.9.9:40000", channel: "#network", wantSource: true, wantChannel: true},
+		{name: "IPv6", addr: "[2001:db8::7]:40000", channel: "#netops", wantSource: true, wantChannel: true},
+		{name: "AllChannels", addr: "127.0.0.1:1234", channel: "#anything", wantSource: true, wantChannel: true},
+		{name: "UnknownSource", addr: "192.0.2.1:1234", channel: "#network", wantSource: false, wantChannel: false},
+	}
+	// Run test cases
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := l.AllowSource(test.addr); got != test.wantSource {
+				t.Errorf("AllowSource: expected %v, but got %v", test.wantSource, got)
+			}
+			if got := l.AllowChannel(test.addr, nil, test.channel); got != test.wantChannel {
+				t.Errorf("AllowChannel: expected %v, but got %v", test.wantChannel, got)
+			}
+		})
+	}
+}
+
+// TestEmptyList verifies that no rules allow everything, also on a nil list.
+func TestEmptyList(t *testing.T) {
+	var nilList *List
+	empty, _ := New(nil)
+	for _, l := range []*List{nilList, empty} {
+		if !l.AllowSource("192.0.2.1:1") || !l.AllowChannel("192.0.2.1:1", nil, "#x") {
+			t.Errorf("expected an empty list to allow everything")
+		}
+	}
+}
+
+// TestNewErrors rejects invalid rules.
+func TestNewErrors(t *testing.T) {
+	for _, r := range []config.ACLRule{
+		{},
+		{Source: []string{"10.0.0.300"}},
+		{Source: []string{"10.0.0.0/8"}, Channels: []string{"#[a"}},
+	} {
+		if _, err := New([]config.ACLRule{r}); err == nil {
+			t.Errorf("New(%+v): expected an error but got nil", r)
+		}
+	}
+}
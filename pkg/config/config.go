@@ -25,6 +25,7 @@ package config
 
 import (
 	"os"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -33,9 +34,125 @@ import (
 type TCPConfig struct {
 	Listen       string `yaml:"listen"          mapstructure:"listen"`
 	MaxLineBytes int    `yaml:"max_line_bytes"  mapstructure:"max_line_bytes"` // 0 => default 65536
+
+	// TLS (optional): serve TLS when tls_cert and tls_key are set; reloaded on SIGHUP
+	TLSCert              string `yaml:"tls_cert"                 mapstructure:"tls_cert"`
+	TLSKey               string `yaml:"tls_key"                  mapstructure:"tls_key"`
+	TLSClientCA          string `yaml:"tls_client_ca"            mapstructure:"tls_client_ca"`           // verify client certificates against this CA bundle
+	TLSRequireClientCert bool   `yaml:"tls_require_client_cert"  mapstructure:"tls_require_client_cert"` // refuse clients without a valid certificate (mTLS)
+}
+
+// HTTPConfig holds HTTP webhook listener settings (POST /send).
+type HTTPConfig struct {
+	Listen       string `yaml:"listen"          mapstructure:"listen"`         // empty => disabled
+	MaxBodyBytes int    `yaml:"max_body_bytes"  mapstructure:"max_body_bytes"` // 0 => default 65536
+}
+
+// AlertmanagerConfig holds Prometheus Alertmanager webhook receiver settings.
+type AlertmanagerConfig struct {
+	Listen          string       `yaml:"listen"            mapstructure:"listen"`           // empty => disabled
+	Path            string       `yaml:"path"              mapstructure:"path"`             // default "/alertmanager"
+	MaxBodyBytes    int          `yaml:"max_body_bytes"    mapstructure:"max_body_bytes"`   // 0 => default 1 MiB
+	MaxLines        int          `yaml:"max_lines"         mapstructure:"max_lines"`        // per notification and channel, 0 => default 10
+	Template        string       `yaml:"template"          mapstructure:"template"`         // text/template for one alert
+	SummaryTemplate string       `yaml:"summary_template"  mapstructure:"summary_template"` // text/template for the group summary line
+	Channels        []string     `yaml:"channels"          mapstructure:"channels"`         // when no route matches; empty => broadcast
+	Routes          []AlertRoute `yaml:"routes"            mapstructure:"routes"`
+}
+
+// AlertRoute sends alerts whose labels match all Matchers to Channels.
+// Matchers use Alertmanager syntax: label=value, label!=value, label=~regex, label!~regex.
+type AlertRoute struct {
+	Matchers []string `yaml:"matchers"  mapstructure:"matchers"`
+	Channels []string `yaml:"channels"  mapstructure:"channels"`
+	Continue bool     `yaml:"continue"  mapstructure:"continue"` // keep evaluating later routes after a match
+}
+
+// QueueConfig holds the outbound send queue and rate limits of the IRC client.
+type QueueConfig struct {
+	Size         int     `yaml:"size"           mapstructure:"size"`          // max queued messages; 0 => 1000
+	Overflow     string  `yaml:"overflow"       mapstructure:"overflow"`      // block | drop_newest | drop_oldest | collapse; "" => collapse
+	Rate         float64 `yaml:"rate"           mapstructure:"rate"`          // global messages/second; 0 => 2, < 0 => unlimited
+	Burst        int     `yaml:"burst"          mapstructure:"burst"`         // global burst; 0 => 5
+	ChannelRate  float64 `yaml:"channel_rate"   mapstructure:"channel_rate"`  // per-channel messages/second; 0 => no per-channel limit
+	ChannelBurst int     `yaml:"channel_burst"  mapstructure:"channel_burst"` // per-channel burst; 0 => 3
+}
+
+// RejoinConfig holds how the IRC client gets back into channels it was
+// kicked from or could not join.
+type RejoinConfig struct {
+	KickDelay  time.Duration `yaml:"kick_delay"   mapstructure:"kick_delay"`  // first rejoin after a KICK; 0 => 5s, < 0 => never
+	Retry      time.Duration `yaml:"retry"        mapstructure:"retry"`       // first retry after 471/473/474; 0 => 1m, < 0 => never
+	MaxDelay   time.Duration `yaml:"max_delay"    mapstructure:"max_delay"`   // cap of the doubling delays; 0 => 30m
+	InviteFrom []string      `yaml:"invite_from"  mapstructure:"invite_from"` // nick!user@host globs whose INVITE to a configured channel is followed; empty => ignore invites
+}
+
+// SyslogConfig holds UDP syslog listener settings (RFC 3164 / RFC 5424).
+type SyslogConfig struct {
+	Listen   string   `yaml:"listen"    mapstructure:"listen"`   // empty => disabled
+	Channels []string `yaml:"channels"  mapstructure:"channels"` // empty => broadcast to all irc.channels
+	Template string   `yaml:"template"  mapstructure:"template"` // text/template; empty => "HOST APP[PID]: MSG"
+}
+
+// ACLRule limits what senders in Source may do. Rules are matched in order by
+// source; the first match decides. With at least one rule, unmatched sources are refused.
+type ACLRule struct {
+	Source   []string `yaml:"source"    mapstructure:"source"`   // IPs or CIDRs
+	Identity []string `yaml:"identity"  mapstructure:"identity"` // glob patterns on the TLS client certificate CN/SANs
+	Channels []string `yaml:"channels"  mapstructure:"channels"` // glob patterns the source may target; empty => all irc.channels
+}
+
+// RoutesConfig selects target channels from message content for messages
+// that were not addressed to a channel explicitly (e.g. "#chan msg").
+type RoutesConfig struct {
+	Rules   []RouteRule `yaml:"rules"    mapstructure:"rules"`   // evaluated in order
+	Default []string    `yaml:"default"  mapstructure:"default"` // when no rule matches; empty => broadcast
+}
+
+// RouteRule matches when all of its non-empty conditions hold.
+type RouteRule struct {
+	Name     string   `yaml:"name"      mapstructure:"name"`     // used in log and error messages
+	Match    string   `yaml:"match"     mapstructure:"match"`    // regex on the message text
+	Source   []string `yaml:"source"    mapstructure:"source"`   // sender IPs or CIDRs
+	Identity []string `yaml:"identity"  mapstructure:"identity"` // glob patterns on the TLS client certificate CN/SANs
+	Input    []string `yaml:"input"     mapstructure:"input"`    // tcp, http, syslog, alertmanager
+	Severity []string `yaml:"severity"  mapstructure:"severity"` // syslog severities (names or 0-7)
+	Facility []string `yaml:"facility"  mapstructure:"facility"` // syslog facilities, e.g. local0
+	Channels []string `yaml:"channels"  mapstructure:"channels"`
+	Continue bool     `yaml:"continue"  mapstructure:"continue"` // keep evaluating later rules after a match (default: stop)
+}
+
+// DedupConfig holds repeat suppression settings applied before sending to IRC.
+type DedupConfig struct {
+	Enabled   bool          `yaml:"enabled"    mapstructure:"enabled"`
+	Window    time.Duration `yaml:"window"     mapstructure:"window"`    // 0 => 5m
+	Normalize []string      `yaml:"normalize"  mapstructure:"normalize"` // regexes removed before comparing; empty => timestamps and [pid]
+	Channels  []string      `yaml:"channels"   mapstructure:"channels"`  // glob patterns; empty => all channels
+}
+
+// MetricsConfig holds the monitoring listener settings (/metrics, /healthz, /readyz).
+type MetricsConfig struct {
+	Listen         string        `yaml:"listen"           mapstructure:"listen"`          // empty => disabled
+	Path           string        `yaml:"path"             mapstructure:"path"`            // default "/metrics"
+	UnhealthyAfter time.Duration `yaml:"unhealthy_after"  mapstructure:"unhealthy_after"` // IRC down this long fails /healthz and the watchdog; 0 => 5m
+	SourceLabels   bool          `yaml:"source_labels"    mapstructure:"source_labels"`   // label lines_received with the client IP (one series per address)
+}
+
+// ControlConfig holds the local admin control socket used by "ircpush ctl".
+type ControlConfig struct {
+	Socket string `yaml:"socket"  mapstructure:"socket"` // e.g. /run/ircpush/ircpush.sock; empty => disabled
+	Group  string `yaml:"group"   mapstructure:"group"`  // group allowed to connect; empty => the process group
+}
+
+// SpoolConfig holds the on-disk spool used while IRC is disconnected.
+type SpoolConfig struct {
+	Dir      string        `yaml:"dir"        mapstructure:"dir"`       // empty => disabled (messages are dropped while disconnected)
+	MaxBytes int64         `yaml:"max_bytes"  mapstructure:"max_bytes"` // 0 => 64 MiB; oldest messages are dropped beyond this
+	MaxAge   time.Duration `yaml:"max_age"    mapstructure:"max_age"`   // 0 => 24h; older messages are discarded on replay
 }
 
 type IRCConfig struct {
+	Name          string            `yaml:"name"            mapstructure:"name"` // network name, for "name/#channel" targets; default "default" for irc
 	Server        string            `yaml:"server"          mapstructure:"server"`
 	TLS           bool              `yaml:"tls"             mapstructure:"tls"`
 	TLSSkipVerify bool              `yaml:"tls_skip_verify" mapstructure:"tls_skip_verify"`
@@ -45,17 +162,30 @@ type IRCConfig struct {
 	Realname      string            `yaml:"realname"        mapstructure:"realname"`
 	ServerPass    string            `yaml:"server_pass"     mapstructure:"server_pass"`
 	IdentifyPass  string            `yaml:"identify_pass"   mapstructure:"identify_pass"`
+	AltNicks      []string          `yaml:"alt_nicks"       mapstructure:"alt_nicks"`      // tried in order when nick is taken
+	NickSuffix    string            `yaml:"nick_suffix"     mapstructure:"nick_suffix"`    // underscore (default), number or random: further nicks after alt_nicks
+	RegainCommand string            `yaml:"regain_command"  mapstructure:"regain_command"` // raw line with {nick} and {pass}; "" => NickServ REGAIN, "none" disables
+	NickReclaim   time.Duration     `yaml:"nick_reclaim"    mapstructure:"nick_reclaim"`   // retry interval for getting nick back; 0 => 1m, negative disables
 	SASLExternal  bool              `yaml:"sasl_external"   mapstructure:"sasl_external"`
 	SASLLogin     string            `yaml:"sasl_login"      mapstructure:"sasl_login"`
 	SASLPass      string            `yaml:"sasl_pass"       mapstructure:"sasl_pass"`
+	SASLRequired  bool              `yaml:"sasl_required"   mapstructure:"sasl_required"` // abort the connection if SASL fails
 	Channels      []string          `yaml:"channels"        mapstructure:"channels"`
 	Keys          map[string]string `yaml:"keys"   mapstructure:"keys"`
+	Queue         QueueConfig       `yaml:"queue"           mapstructure:"queue"`
+	Rejoin        RejoinConfig      `yaml:"rejoin"          mapstructure:"rejoin"`
+	PingInterval  time.Duration     `yaml:"ping_interval"   mapstructure:"ping_interval"` // client PING for lag and dead connections; 0 => 1m, < 0 => off
+	PingTimeout   time.Duration     `yaml:"ping_timeout"    mapstructure:"ping_timeout"`  // reconnect when the PONG takes longer; 0 => 30s
+	QuitMessage   string            `yaml:"quit_message"    mapstructure:"quit_message"`  // sent with QUIT on shutdown; "" => "shutdown"
 
 	// New: maximum length of an IRC message payload after highlighting (characters). 0 = unlimited.
 	MaxMessageLen int `yaml:"max_message_len" mapstructure:"max_message_len"`
 	// New: if true, split messages longer than MaxMessageLen into multiple PRIVMSGs;
 	// if false, truncate and append "..." (only when MaxMessageLen > 3).
 	SplitLong bool `yaml:"split_long" mapstructure:"split_long"`
+	// Formatting the network supports: extended (default), basic (bold, underline,
+	// reverse, 16 colors; others degrade to the nearest) or none (stripped).
+	Formatting string `yaml:"formatting" mapstructure:"formatting"`
 }
 
 type HighlightConfig struct {
@@ -66,9 +196,14 @@ type HighlightConfig struct {
 type HighlightRule struct {
 	Kind            string   `yaml:"kind"               mapstructure:"kind"`
 	Pattern         string   `yaml:"pattern"            mapstructure:"pattern"`
-	Color           string   `yaml:"color"              mapstructure:"color"`
+	Color           string   `yaml:"color"              mapstructure:"color"`      // name, palette number 0-99 or #RRGGBB; "fg,bg" also works
+	Background      string   `yaml:"background"         mapstructure:"background"` // like color; instead of a "fg,bg" color
 	Bold            bool     `yaml:"bold"               mapstructure:"bold"`
+	Italic          bool     `yaml:"italic"             mapstructure:"italic"`
 	Underline       bool     `yaml:"underline"          mapstructure:"underline"`
+	Strikethrough   bool     `yaml:"strikethrough"      mapstructure:"strikethrough"`
+	Monospace       bool     `yaml:"monospace"          mapstructure:"monospace"`
+	Reverse         bool     `yaml:"reverse"            mapstructure:"reverse"` // swap foreground and background
 	CaseInsensitive bool     `yaml:"case_insensitive"   mapstructure:"case_insensitive"`
 	WholeLine       bool     `yaml:"whole_line"         mapstructure:"whole_line"`
 	Channels        []string `yaml:"channels"           mapstructure:"channels"`
@@ -76,13 +211,50 @@ type HighlightRule struct {
 
 	// New: color only these submatch groups (by index or name). Example: ["1","2"] or ["src","dst"]
 	Groups []string `yaml:"groups"              mapstructure:"groups"`
+
+	// Where matches of rules overlap, the higher priority wins; equal priorities (default 0) go by rule order
+	Priority int `yaml:"priority"            mapstructure:"priority"`
+}
+
+// RedactConfig masks secrets and personal data in messages before they are
+// highlighted and sent. Rules apply in order, each to the previous one's output.
+type RedactConfig struct {
+	Rules   []RedactRule `yaml:"rules"     mapstructure:"rules"`
+	HashKey string       `yaml:"hash_key"  mapstructure:"hash_key"` // key for replace: hash (HMAC-SHA256); required by it
+}
+
+type RedactRule struct {
+	Name            string   `yaml:"name"               mapstructure:"name"`             // label in ircpush_redactions_total; default: the kind, or "ruleN" for regex
+	Kind            string   `yaml:"kind"               mapstructure:"kind"`             // regex, or a built-in: email, ipv4_last_octet, jwt, aws_key, url_credentials, bearer
+	Pattern         string   `yaml:"pattern"            mapstructure:"pattern"`          // kind regex only
+	CaseInsensitive bool     `yaml:"case_insensitive"   mapstructure:"case_insensitive"` // kind regex only
+	Groups          []string `yaml:"groups"             mapstructure:"groups"`           // kind regex only: mask just these submatch groups (by index or name)
+	Replace         string   `yaml:"replace"            mapstructure:"replace"`          // mask (default) or hash
+	Mask            string   `yaml:"mask"               mapstructure:"mask"`             // default "[redacted]" ("x" for ipv4_last_octet)
+	Channels        []string `yaml:"channels"           mapstructure:"channels"`
+	ExcludeChannels []string `yaml:"exclude_channels"   mapstructure:"exclude_channels"`
 }
 
 // Config is the root application config.
 type Config struct {
-	IRC       IRCConfig       `yaml:"irc"        mapstructure:"irc"`
-	TCP       TCPConfig       `yaml:"tcp"        mapstructure:"tcp"`
-	Highlight HighlightConfig `yaml:"highlight"  mapstructure:"highlight"`
+	IRC          IRCConfig          `yaml:"irc"           mapstructure:"irc"`
+	Networks     []IRCConfig        `yaml:"networks"      mapstructure:"networks"` // further IRC networks, each with its own connection
+	TCP          TCPConfig          `yaml:"tcp"           mapstructure:"tcp"`
+	HTTP         HTTPConfig         `yaml:"http"          mapstructure:"http"`
+	Alertmanager AlertmanagerConfig `yaml:"alertmanager"  mapstructure:"alertmanager"`
+	Syslog       SyslogConfig       `yaml:"syslog"        mapstructure:"syslog"`
+	Routes       RoutesConfig       `yaml:"routes"        mapstructure:"routes"`
+	ACL          []ACLRule          `yaml:"acl"           mapstructure:"acl"`
+	Dedup        DedupConfig        `yaml:"dedup"         mapstructure:"dedup"`
+	Spool        SpoolConfig        `yaml:"spool"         mapstructure:"spool"`
+	Metrics      MetricsConfig      `yaml:"metrics"       mapstructure:"metrics"`
+	Control      ControlConfig      `yaml:"control"       mapstructure:"control"`
+	Redact       RedactConfig       `yaml:"redact"        mapstructure:"redact"`
+	Highlight    HighlightConfig    `yaml:"highlight"     mapstructure:"highlight"`
+
+	// ShutdownTimeout bounds stopping: draining the inputs and then flushing
+	// the send queues and quitting IRC. 0 => 10s.
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" mapstructure:"shutdown_timeout"`
 }
 
 // Optional: legacy direct YAML loader (kept for tests/tools).
@@ -35,6 +35,7 @@ func TestLoadFile(t *testing.T) {
 					SASLExternal:  false,
 					SASLLogin:     "sasluser",
 					SASLPass:      "saslpass",
+					SASLRequired:  true,
 					Channels:      []string{"#channel1", "&channel2"},
 					Keys: map[string]string{
 						"#channel1": "key1",
@@ -101,7 +102,8 @@ func compareConfigs(a, b *Config) bool {
 		a.IRC.IdentifyPass != b.IRC.IdentifyPass ||
 		a.IRC.SASLExternal != b.IRC.SASLExternal ||
 		a.IRC.SASLLogin != b.IRC.SASLLogin ||
-		a.IRC.SASLPass != b.IRC.SASLPass {
+		a.IRC.SASLPass != b.IRC.SASLPass ||
+		a.IRC.SASLRequired != b.IRC.SASLRequired {
 		return false
 	}
 	if len(a.IRC.Channels) != len(b.IRC.Channels) {
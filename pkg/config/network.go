@@ -0,0 +1,73 @@
+/*
+MIT License
+
+Copyright (c) 2025 Mikael Schultz <mikael@conf-t.se>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package config
+
+import "strings"
+
+// DefaultNetwork is the name of the irc section when irc.name is not set.
+const DefaultNetwork = "default"
+
+// IRCNetworks returns the IRC networks to connect to, each with its name
+// set: the irc section (unless it is empty and networks are configured)
+// followed by networks. The first one is the primary network, whose channels
+// are addressed without the "name/" prefix.
+func (c *Config) IRCNetworks() []IRCConfig {
+	var out []IRCConfig
+	if c.IRC.Server != "" || c.IRC.Nick != "" || len(c.Networks) == 0 {
+		primary := c.IRC
+		if strings.TrimSpace(primary.Name) == "" {
+			primary.Name = DefaultNetwork
+		}
+		out = append(out, primary)
+	}
+	return append(out, c.Networks...)
+}
+
+// SplitChannel splits a "network/#channel" target into the network name and
+// the channel when the network is one of networks. network is "" for a plain
+// channel, including channel names that contain a "/" themselves, such as
+// "#ops/db", and for a prefix that is not one of networks.
+func SplitChannel(target string, networks []string) (network, channel string) {
+	target = strings.TrimSpace(target)
+	if net, ch := cutNetwork(target); net != "" {
+		for _, n := range networks {
+			if strings.EqualFold(n, net) {
+				return net, ch
+			}
+		}
+	}
+	return "", target
+}
+
+// cutNetwork splits target at the "/" of "network/#channel", whether or not
+// network exists.
+func cutNetwork(target string) (network, channel string) {
+	if strings.HasPrefix(target, "#") || strings.HasPrefix(target, "&") {
+		return "", target
+	}
+	if net, ch, ok := strings.Cut(target, "/"); ok && net != "" && (strings.HasPrefix(ch, "#") || strings.HasPrefix(ch, "&")) {
+		return net, ch
+	}
+	return "", target
+}
@@ -0,0 +1,51 @@
+package config
+
+import "testing"
+
+// TestSplitChannel verifies that network prefixes are split off channel targets.
+func TestSplitChannel(t *testing.T) {
+	// Setup test cases
+	tests := []struct {
+		name    string
+		target  string
+		network string
+		channel string
+	}{
+		{name: "Plain", target: "#ops", network: "", channel: "#ops"},
+		{name: "Qualified", target: "libera/#ops", network: "libera", channel: "#ops"},
+		{name: "Ampersand", target: "oftc/&local", network: "oftc", channel: "&local"},
+		{name: "SlashInChannel", target: "#ops/db", network: "", channel: "#ops/db"},
+		{name: "NoChannelAfterSlash", target: "a/b", network: "", channel: "a/b"},
+		{name: "EmptyNetwork", target: "/#ops", network: "", channel: "/#ops"},
+		{name: "CaseInsensitive", target: "Libera/#ops", network: "Libera", channel: "#ops"},
+		{name: "UnknownNetwork", target: "efnet/#ops", network: "", channel: "efnet/#ops"},
+		{name: "NotANetwork", target: "build/#123", network: "", channel: "build/#123"},
+	}
+
+	// Run test cases
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			network, channel := SplitChannel(test.target, []string{"libera", "oftc"})
+			if network != test.network || channel != test.channel {
+				t.Errorf("expected (%q, %q), but got (%q, %q)", test.network, test.channel, network, channel)
+			}
+		})
+	}
+}
+
+// TestIRCNetworks verifies the primary network and its default name.
+func TestIRCNetworks(t *testing.T) {
+	cfg := Config{
+		IRC:      IRCConfig{Server: "irc.example.com:6697", Nick: "bot"},
+		Networks: []IRCConfig{{Name: "libera", Server: "irc.libera.chat:6697", Nick: "bot"}},
+	}
+	nets := cfg.IRCNetworks()
+	if len(nets) != 2 || nets[0].Name != DefaultNetwork || nets[1].Name != "libera" {
+		t.Fatalf("unexpected networks: %+v", nets)
+	}
+
+	cfg.IRC = IRCConfig{}
+	if nets = cfg.IRCNetworks(); len(nets) != 1 || nets[0].Name != "libera" {
+		t.Errorf("expected only libera without an irc section, but got %+v", nets)
+	}
+}
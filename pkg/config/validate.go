@@ -0,0 +1,463 @@
+/*
+MIT License
+
+Copyright (c) 2025 Mikael Schultz <mikael@conf-t.se>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package config
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"regexp/syntax"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FieldError is a problem with one config value, located by its YAML path.
+type FieldError struct {
+	Path string // e.g. "highlight.rules[5].pattern"
+	Msg  string
+}
+
+func (e FieldError) Error() string {
+	return e.Path + ": " + e.Msg
+}
+
+// Errors lists every problem found by Validate.
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	lines := make([]string, len(e))
+	for i, fe := range e {
+		lines[i] = fe.Error()
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (e *Errors) add(path, format string, v ...any) {
+	*e = append(*e, FieldError{Path: path, Msg: fmt.Sprintf(format, v...)})
+}
+
+// Validate checks cfg for mistakes that would otherwise be ignored at
+// runtime, such as a highlight rule whose regex does not compile. doc is the
+// YAML file cfg was read from and is used to report unknown keys; it may be
+// nil. The returned error is an Errors value listing all problems.
+func Validate(cfg *Config, doc []byte) error {
+	var errs Errors
+	if doc != nil {
+		var root yaml.Node
+		if err := yaml.Unmarshal(doc, &root); err != nil {
+			return err
+		}
+		if len(root.Content) > 0 {
+			unknownKeys(&errs, "", root.Content[0], reflect.TypeOf(Config{}))
+		}
+	}
+
+	networks := map[string]bool{}
+	nets := cfg.IRCNetworks()
+	offset := len(nets) - len(cfg.Networks) // 1 when the irc section is used
+	for i, n := range nets {
+		path := "irc"
+		if i >= offset {
+			path = fmt.Sprintf("networks[%d]", i-offset)
+		}
+		checkNetwork(&errs, path, n, networks)
+	}
+	for i, r := range cfg.ACL {
+		path := fmt.Sprintf("acl[%d]", i)
+		checkGlobs(&errs, path+".identity", r.Identity)
+		checkGlobs(&errs, path+".channels", r.Channels)
+	}
+	for i, r := range cfg.Routes.Rules {
+		path := fmt.Sprintf("routes.rules[%d]", i)
+		if r.Match != "" {
+			if _, err := regexp.Compile(r.Match); err != nil {
+				errs.add(path+".match", "%s", regexpMsg(err, r.Match))
+			}
+		}
+		checkGlobs(&errs, path+".identity", r.Identity)
+		checkTargets(&errs, path+".channels", r.Channels, networks)
+	}
+	checkTargets(&errs, "routes.default", cfg.Routes.Default, networks)
+	for i, p := range cfg.Dedup.Normalize {
+		if _, err := regexp.Compile(p); err != nil {
+			errs.add(fmt.Sprintf("dedup.normalize[%d]", i), "%s", regexpMsg(err, p))
+		}
+	}
+	checkGlobs(&errs, "dedup.channels", cfg.Dedup.Channels)
+	hashed := false
+	for i, r := range cfg.Redact.Rules {
+		checkRedactRule(&errs, fmt.Sprintf("redact.rules[%d]", i), r)
+		hashed = hashed || strings.EqualFold(r.Replace, "hash")
+	}
+	if hashed && cfg.Redact.HashKey == "" {
+		errs.add("redact.hash_key", "required when a rule uses replace: hash")
+	}
+	for i, r := range cfg.Highlight.Rules {
+		checkRule(&errs, fmt.Sprintf("highlight.rules[%d]", i), r)
+	}
+	if cfg.ShutdownTimeout < 0 {
+		errs.add("shutdown_timeout", "must not be negative")
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// validNetworkName matches network names that can be used in "name/#channel".
+var validNetworkName = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+func checkNetwork(errs *Errors, path string, n IRCConfig, seen map[string]bool) {
+	name := strings.ToLower(strings.TrimSpace(n.Name))
+	switch {
+	case name == "":
+		errs.add(path+".name", "required")
+	case !validNetworkName.MatchString(name):
+		errs.add(path+".name", "invalid network name %q (use letters, digits, '.', '_' and '-')", n.Name)
+	case seen[name]:
+		errs.add(path+".name", "duplicate network name %q", n.Name)
+	}
+	seen[name] = true
+	if strings.TrimSpace(n.Server) == "" {
+		errs.add(path+".server", "required")
+	}
+	if strings.TrimSpace(n.Nick) == "" {
+		errs.add(path+".nick", "required")
+	}
+	for i, alt := range n.AltNicks {
+		if alt = strings.TrimSpace(alt); alt == "" || strings.ContainsAny(alt, " ,*?!@#&:") {
+			errs.add(fmt.Sprintf("%s.alt_nicks[%d]", path, i), "invalid nick %q", alt)
+		}
+	}
+	checkGlobs(errs, path+".rejoin.invite_from", n.Rejoin.InviteFrom)
+	switch n.NickSuffix {
+	case "", "underscore", "number", "random":
+	default:
+		errs.add(path+".nick_suffix", "unknown suffix %q (want underscore, number or random)", n.NickSuffix)
+	}
+	switch n.Formatting {
+	case "", "extended", "basic", "none":
+	default:
+		errs.add(path+".formatting", "unknown level %q (want extended, basic or none)", n.Formatting)
+	}
+}
+
+// checkTargets reports "network/#channel" targets naming an unknown network.
+func checkTargets(errs *Errors, path string, targets []string, networks map[string]bool) {
+	for i, t := range targets {
+		if net, _ := cutNetwork(strings.TrimSpace(t)); net != "" && !networks[strings.ToLower(net)] {
+			errs.add(fmt.Sprintf("%s[%d]", path, i), "unknown network %q", net)
+		}
+	}
+}
+
+func checkRule(errs *Errors, path string, r HighlightRule) {
+	switch strings.ToLower(r.Kind) {
+	case "regex", "word", "":
+	default:
+		errs.add(path+".kind", "unknown kind %q (want regex or word)", r.Kind)
+		return
+	}
+	re, err := r.Regexp()
+	if err != nil {
+		errs.add(path+".pattern", "%s", regexpMsg(err, r.Pattern))
+	}
+	fg, ok := ColorCode(r.Color)
+	if !ok {
+		errs.add(path+".color", "unknown color %q", r.Color)
+	}
+	if r.Background != "" {
+		if bg, ok := ColorCode(r.Background); !ok || strings.Contains(bg, ",") {
+			errs.add(path+".background", "unknown color %q", r.Background)
+		} else if strings.Contains(fg, ",") {
+			errs.add(path+".background", "color %q already sets a background", r.Color)
+		}
+	}
+	if re != nil {
+		checkGroups(errs, path+".groups", re, r.Groups)
+	}
+	checkGlobs(errs, path+".channels", r.Channels)
+	checkGlobs(errs, path+".exclude_channels", r.ExcludeChannels)
+}
+
+func checkRedactRule(errs *Errors, path string, r RedactRule) {
+	kind := strings.ToLower(strings.TrimSpace(r.Kind))
+	if _, ok := RedactBuiltins[kind]; ok {
+		if strings.TrimSpace(r.Pattern) != "" {
+			errs.add(path+".pattern", "only used with kind regex")
+		}
+		if len(r.Groups) > 0 {
+			errs.add(path+".groups", "only used with kind regex")
+		}
+	} else if kind == "regex" {
+		if re, err := r.Regexp(); err != nil {
+			errs.add(path+".pattern", "%s", regexpMsg(err, r.Pattern))
+		} else {
+			checkGroups(errs, path+".groups", re, r.Groups)
+		}
+	} else {
+		errs.add(path+".kind", "unknown kind %q (want regex or one of %s)", r.Kind, strings.Join(redactKinds(), ", "))
+	}
+	switch strings.ToLower(r.Replace) {
+	case "", "mask", "hash":
+	default:
+		errs.add(path+".replace", "unknown replacement %q (want mask or hash)", r.Replace)
+	}
+	checkGlobs(errs, path+".channels", r.Channels)
+	checkGlobs(errs, path+".exclude_channels", r.ExcludeChannels)
+}
+
+// checkGroups reports groups (by index or name) that re does not have.
+func checkGroups(errs *Errors, path string, re *regexp.Regexp, groups []string) {
+	for j, g := range groups {
+		g = strings.TrimSpace(g)
+		if n, err := strconv.Atoi(g); err == nil {
+			if n < 1 || n > re.NumSubexp() {
+				errs.add(fmt.Sprintf("%s[%d]", path, j), "no group %d in pattern (it has %d)", n, re.NumSubexp())
+			}
+		} else if re.SubexpIndex(g) < 0 {
+			errs.add(fmt.Sprintf("%s[%d]", path, j), "no group named %q in pattern", g)
+		}
+	}
+}
+
+func checkGlobs(errs *Errors, path string, patterns []string) {
+	for i, p := range patterns {
+		if _, err := filepath.Match(strings.TrimSpace(p), ""); err != nil {
+			errs.add(fmt.Sprintf("%s[%d]", path, i), "invalid glob pattern %q", p)
+		}
+	}
+}
+
+// regexpMsg returns the reason pattern does not compile, without Go's
+// "error parsing regexp" prefix.
+func regexpMsg(err error, pattern string) string {
+	var se *syntax.Error
+	if !errors.As(err, &se) {
+		return err.Error()
+	}
+	msg := strconv.Quote(se.Code.String())
+	if se.Expr != "" && len(se.Expr) < len(strings.TrimSpace(pattern)) {
+		msg += " at " + strconv.Quote(se.Expr)
+	}
+	return msg
+}
+
+// unknownKeys reports keys of the YAML node n that are not yaml tags of
+// the struct type t, recursing into nested structs and lists.
+func unknownKeys(errs *Errors, path string, n *yaml.Node, t reflect.Type) {
+	if n.Kind == yaml.AliasNode {
+		n = n.Alias
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		if n.Kind != yaml.MappingNode {
+			return
+		}
+		fields := map[string]reflect.Type{}
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if name, _, _ := strings.Cut(f.Tag.Get("yaml"), ","); name != "" && name != "-" {
+				fields[name] = f.Type
+			}
+		}
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			k := n.Content[i].Value
+			if k == "<<" { // merge key
+				continue
+			}
+			p := k
+			if path != "" {
+				p = path + "." + k
+			}
+			// Viper matches keys case-insensitively
+			ft, ok := fields[strings.ToLower(k)]
+			if !ok {
+				errs.add(p, "unknown key (line %d)", n.Content[i].Line)
+				continue
+			}
+			unknownKeys(errs, p, n.Content[i+1], ft)
+		}
+	case reflect.Slice:
+		if n.Kind != yaml.SequenceNode {
+			return
+		}
+		for i, item := range n.Content {
+			unknownKeys(errs, fmt.Sprintf("%s[%d]", path, i), item, t.Elem())
+		}
+	}
+}
+
+// Regexp compiles the rule's pattern for its kind: "regex" as is, "word"
+// (the default) as a literal matched on word boundaries.
+func (r HighlightRule) Regexp() (*regexp.Regexp, error) {
+	pat := strings.TrimSpace(r.Pattern)
+	if pat == "" {
+		return nil, errors.New("pattern is empty")
+	}
+	switch strings.ToLower(r.Kind) {
+	case "regex":
+		if r.CaseInsensitive && !strings.HasPrefix(pat, "(?i)") {
+			pat = "(?i)" + pat
+		}
+	case "word", "":
+		pat = `\b` + regexp.QuoteMeta(pat) + `\b`
+		if r.CaseInsensitive {
+			pat = "(?i)" + pat
+		}
+	default:
+		return nil, fmt.Errorf("unknown kind %q", r.Kind)
+	}
+	return regexp.Compile(pat)
+}
+
+// RedactBuiltin is a ready-made redaction rule, used as a redact rule kind.
+type RedactBuiltin struct {
+	Pattern string
+	Groups  []string // groups to mask; empty => the whole match
+	Mask    string   // default mask
+}
+
+// RedactBuiltins are the redaction kinds besides "regex".
+var RedactBuiltins = map[string]RedactBuiltin{
+	"email":           {Pattern: `(?i)\b[a-z0-9._%+-]+@[a-z0-9-]+(?:\.[a-z0-9-]+)*\.[a-z]{2,}\b`},
+	"ipv4_last_octet": {Pattern: `\b(?:\d{1,3}\.){3}(?P<octet>\d{1,3})\b`, Groups: []string{"octet"}, Mask: "x"},
+	"jwt":             {Pattern: `\beyJ[\w-]+\.[\w-]+\.[\w-]*`},
+	"aws_key":         {Pattern: `\b(?:AKIA|ASIA|AIDA|AROA|AGPA|ANPA|ANVA|AIPA)[A-Z0-9]{16}\b`},
+	"url_credentials": {Pattern: `(?i)\b[a-z][a-z0-9+.-]*://(?P<userinfo>[^\s/?#@]+)@`, Groups: []string{"userinfo"}},
+	"bearer":          {Pattern: `(?i)\bbearer\s+(?P<token>[\w.~+/-]+=*)`, Groups: []string{"token"}},
+}
+
+func redactKinds() []string {
+	kinds := make([]string, 0, len(RedactBuiltins))
+	for k := range RedactBuiltins {
+		kinds = append(kinds, k)
+	}
+	sort.Strings(kinds)
+	return kinds
+}
+
+// Regexp compiles the rule's pattern: Pattern for kind "regex", the
+// built-in pattern for the other kinds.
+func (r RedactRule) Regexp() (*regexp.Regexp, error) {
+	kind := strings.ToLower(strings.TrimSpace(r.Kind))
+	if b, ok := RedactBuiltins[kind]; ok {
+		return regexp.Compile(b.Pattern)
+	}
+	if kind != "regex" {
+		return nil, fmt.Errorf("unknown kind %q", r.Kind)
+	}
+	pat := strings.TrimSpace(r.Pattern)
+	if pat == "" {
+		return nil, errors.New("pattern is empty")
+	}
+	if r.CaseInsensitive && !strings.HasPrefix(pat, "(?i)") {
+		pat = "(?i)" + pat
+	}
+	return regexp.Compile(pat)
+}
+
+// colorCodes maps the color names of highlight rules to mIRC color codes.
+var colorCodes = map[string]string{
+	"white":      "00",
+	"black":      "01",
+	"blue":       "02",
+	"navy":       "02",
+	"green":      "03",
+	"red":        "04",
+	"brown":      "05",
+	"maroon":     "05",
+	"purple":     "06",
+	"orange":     "07",
+	"olive":      "07",
+	"yellow":     "08",
+	"lightgreen": "09",
+	"lime":       "09",
+	"teal":       "10",
+	"cyan":       "10",
+	"lightcyan":  "11",
+	"aqua":       "11",
+	"lightblue":  "12",
+	"royal":      "12",
+	"pink":       "13",
+	"fuchsia":    "13",
+	"grey":       "14",
+	"gray":       "14",
+	"lightgrey":  "15",
+	"lightgray":  "15",
+	"silver":     "15",
+}
+
+// ColorCode returns the IRC color code for a color: a name ("red" => "04"),
+// a palette number 0-99 ("4" => "04") or a hex color ("#ff8000" =>
+// "#FF8000"). A "fg,bg" pair is converted part by part ("red,1" =>
+// "04,01"). An empty color is "" and ok. ok is false for unknown names and
+// malformed values; malformed numbers are still normalized (e.g. "123" =>
+// "12") as they always have been.
+func ColorCode(color string) (code string, ok bool) {
+	c := strings.TrimSpace(strings.ToLower(color))
+	if c == "" {
+		return "", true
+	}
+	parts := strings.Split(c, ",")
+	ok = len(parts) <= 2
+	for i, p := range parts {
+		code, partOK := colorPart(strings.TrimSpace(p))
+		parts[i] = code
+		ok = ok && partOK
+	}
+	return strings.Join(parts, ","), ok
+}
+
+func colorPart(p string) (string, bool) {
+	if code, ok := colorCodes[p]; ok {
+		return code, true
+	}
+	if hex, ok := strings.CutPrefix(p, "#"); ok {
+		if _, err := strconv.ParseUint(hex, 16, 32); err != nil || len(hex) != 6 {
+			return "", false
+		}
+		return strings.ToUpper(p), true
+	}
+	for _, ch := range p {
+		if ch < '0' || ch > '9' {
+			return "", false
+		}
+	}
+	switch len(p) {
+	case 0:
+		return "", false
+	case 1:
+		return "0" + p, true
+	case 2:
+		return p, true
+	}
+	return p[:2], false
+}
@@ -0,0 +1,201 @@
+package config
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// TestValidate verifies that Validate reports each problem with its YAML path.
+func TestValidate(t *testing.T) {
+	base := func() *Config {
+		return &Config{IRC: IRCConfig{Server: "irc.example.com:6697", Nick: "bot"}}
+	}
+
+	// Setup test cases
+	tests := []struct {
+		name     string
+		doc      string
+		modify   func(c *Config)
+		expected []string
+	}{
+		{
+			name:     "Valid",
+			doc:      "irc:\n  server: irc.example.com:6697\n  nick: bot\nhighlight:\n  rules:\n    - pattern: down\n      color: red\n",
+			modify:   func(c *Config) { c.Highlight.Rules = []HighlightRule{{Pattern: "down", Color: "red"}} },
+			expected: nil,
+		},
+		{
+			name:     "MissingServerAndNick",
+			modify:   func(c *Config) { c.IRC = IRCConfig{} },
+			expected: []string{"irc.server: required", "irc.nick: required"},
+		},
+		{
+			name: "BadRegex",
+			modify: func(c *Config) {
+				c.Highlight.Rules = make([]HighlightRule, 6)
+				for i := range c.Highlight.Rules {
+					c.Highlight.Rules[i] = HighlightRule{Pattern: "ok"}
+				}
+				c.Highlight.Rules[5] = HighlightRule{Kind: "regex", Pattern: "(foo"}
+			},
+			expected: []string{`highlight.rules[5].pattern: "missing closing )"`},
+		},
+		{
+			name: "UnknownKindAndColor",
+			modify: func(c *Config) {
+				c.Highlight.Rules = []HighlightRule{
+					{Kind: "regexp", Pattern: "x"},
+					{Pattern: "x", Color: "reddish"},
+					{Pattern: "x", Color: "4,12"},
+				}
+			},
+			expected: []string{
+				`highlight.rules[0].kind: unknown kind "regexp" (want regex or word)`,
+				`highlight.rules[1].color: unknown color "reddish"`,
+			},
+		},
+		{
+			name: "ExtendedColors",
+			modify: func(c *Config) {
+				c.IRC.Formatting = "fancy"
+				c.Highlight.Rules = []HighlightRule{
+					{Pattern: "x", Color: "#FFB000", Background: "52"},
+					{Pattern: "x", Color: "#FFB00", Background: "#000000"},
+					{Pattern: "x", Color: "red,black", Background: "blue"},
+				}
+			},
+			expected: []string{
+				`irc.formatting: unknown level "fancy" (want extended, basic or none)`,
+				`highlight.rules[1].color: unknown color "#FFB00"`,
+				`highlight.rules[2].background: color "red,black" already sets a background`,
+			},
+		},
+		{
+			name: "MissingGroups",
+			modify: func(c *Config) {
+				c.Highlight.Rules = []HighlightRule{{Kind: "regex", Pattern: `(?P<port>\d+)`, Groups: []string{"port", "prt", "2"}}}
+			},
+			expected: []string{
+				`highlight.rules[0].groups[1]: no group named "prt" in pattern`,
+				`highlight.rules[0].groups[2]: no group 2 in pattern (it has 1)`,
+			},
+		},
+		{
+			name: "NickFallback",
+			modify: func(c *Config) {
+				c.IRC.AltNicks = []string{"bot_", "", "b#t"}
+				c.IRC.NickSuffix = "digits"
+				c.IRC.Rejoin.InviteFrom = []string{"ChanServ!*@services.*", "[op"}
+			},
+			expected: []string{
+				`irc.alt_nicks[1]: invalid nick ""`,
+				`irc.alt_nicks[2]: invalid nick "b#t"`,
+				`irc.rejoin.invite_from[1]: invalid glob pattern "[op"`,
+				`irc.nick_suffix: unknown suffix "digits" (want underscore, number or random)`,
+			},
+		},
+		{
+			name: "Networks",
+			modify: func(c *Config) {
+				c.Networks = []IRCConfig{
+					{Name: "libera", Server: "irc.libera.chat:6697", Nick: "bot"},
+					{Name: "Default", Server: "irc.example.net:6697"},
+					{Name: "oft c", Server: "irc.oftc.net:6697", Nick: "bot", Formatting: "ansi"},
+				}
+				c.Routes.Default = []string{"libera/#alerts", "efnet/#alerts", "#ops/db"}
+			},
+			expected: []string{
+				`networks[1].name: duplicate network name "Default"`,
+				`networks[1].nick: required`,
+				`networks[2].name: invalid network name "oft c" (use letters, digits, '.', '_' and '-')`,
+				`networks[2].formatting: unknown level "ansi" (want extended, basic or none)`,
+				`routes.default[1]: unknown network "efnet"`,
+			},
+		},
+		{
+			name: "NetworksOnly",
+			modify: func(c *Config) {
+				c.IRC = IRCConfig{}
+				c.Networks = []IRCConfig{{Server: "irc.example.net:6697", Nick: "bot"}}
+			},
+			expected: []string{"networks[0].name: required"},
+		},
+		{
+			name: "RedactRules",
+			modify: func(c *Config) {
+				c.Redact.Rules = []RedactRule{
+					{Kind: "email", Replace: "hash"},
+					{Kind: "url_creds"},
+					{Kind: "regex", Pattern: `token=(\w+`},
+					{Kind: "jwt", Groups: []string{"1"}, Replace: "scramble"},
+				}
+			},
+			expected: []string{
+				`redact.rules[1].kind: unknown kind "url_creds" (want regex or one of aws_key, bearer, email, ipv4_last_octet, jwt, url_credentials)`,
+				`redact.rules[2].pattern: "missing closing )"`,
+				`redact.rules[3].groups: only used with kind regex`,
+				`redact.rules[3].replace: unknown replacement "scramble" (want mask or hash)`,
+				`redact.hash_key: required when a rule uses replace: hash`,
+			},
+		},
+		{
+			name: "BadGlobs",
+			modify: func(c *Config) {
+				c.Highlight.Rules = []HighlightRule{{Pattern: "x", ExcludeChannels: []string{"#ok*", "#[a"}}}
+				c.ACL = []ACLRule{{Channels: []string{"#net["}}}
+			},
+			expected: []string{
+				`acl[0].channels[0]: invalid glob pattern "#net["`,
+				`highlight.rules[0].exclude_channels[1]: invalid glob pattern "#[a"`,
+			},
+		},
+		{
+			name:     "NegativeShutdownTimeout",
+			modify:   func(c *Config) { c.ShutdownTimeout = -time.Second },
+			expected: []string{"shutdown_timeout: must not be negative"},
+		},
+		{
+			name: "UnknownKeys",
+			doc:  "tcp:\n  lisen: \":9000\"\nirc:\n  server: x\n  nick: y\nacl:\n  - source: [\"::1\"]\n    chanels: []\nhighlights: {}\n",
+			expected: []string{
+				"tcp.lisen: unknown key (line 2)",
+				"acl[0].chanels: unknown key (line 8)",
+				"highlights: unknown key (line 9)",
+			},
+		},
+	}
+
+	// Run test cases
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := base()
+			if test.modify != nil {
+				test.modify(cfg)
+			}
+			var doc []byte
+			if test.doc != "" {
+				doc = []byte(test.doc)
+			}
+			err := Validate(cfg, doc)
+			if test.expected == nil {
+				if err != nil {
+					t.Fatalf("did not expect an error but got: %v", err)
+				}
+				return
+			}
+			var errs Errors
+			if !errors.As(err, &errs) {
+				t.Fatalf("expected Errors but got %v", err)
+			}
+			if len(errs) != len(test.expected) {
+				t.Fatalf("expected %d problems but got %d:\n%v", len(test.expected), len(errs), errs)
+			}
+			for i, e := range errs {
+				if e.Error() != test.expected[i] {
+					t.Errorf("problem %d: expected %q, but got %q", i, test.expected[i], e.Error())
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,336 @@
+/*
+MIT License
+
+Copyright (c) 2025 Mikael Schultz <mikael@conf-t.se>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package control
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultSocket is where ircpush serve listens unless control.socket says otherwise.
+const DefaultSocket = "/run/ircpush/ircpush.sock"
+
+// Request is one command. On the wire it is a JSON object per line, or a
+// plain text line ("mute #network 30") for use with socat/nc.
+type Request struct {
+	Cmd  string   `json:"cmd"`
+	Args []string `json:"args,omitempty"`
+}
+
+// Response answers one Request, as a JSON object per line.
+type Response struct {
+	OK    bool            `json:"ok"`
+	Error string          `json:"error,omitempty"`
+	Data  json.RawMessage `json:"data,omitempty"`
+}
+
+// HandlerFunc runs a command. The result is JSON-encoded into Response.Data.
+type HandlerFunc func(args []string) (any, error)
+
+// Server accepts control commands on a Unix domain socket. The socket is
+// created with mode 0600 and then opened up to 0660, so only the owner and
+// Group may ever connect.
+type Server struct {
+	Path  string // socket path, default DefaultSocket
+	Group string // group allowed to connect; "" keeps the process group
+
+	// Optional logging sink; if nil, logs go to stderr.
+	Logger Logger
+
+	mu       sync.RWMutex
+	handlers map[string]HandlerFunc
+	ln       net.Listener
+	wg       sync.WaitGroup
+	once     sync.Once
+
+	connMu  sync.Mutex
+	conns   map[net.Conn]struct{}
+	stopped bool
+}
+
+// Logger is a minimal logger interface.
+type Logger interface {
+	Printf(format string, v ...any)
+}
+
+func (s *Server) logf(format string, v ...any) {
+	if s.Logger != nil {
+		s.Logger.Printf(format, v...)
+		return
+	}
+	fmt.Fprintf(os.Stderr, format+"\n", v...)
+}
+
+// Handle registers h for cmd (case-insensitive).
+func (s *Server) Handle(cmd string, h HandlerFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.handlers == nil {
+		s.handlers = map[string]HandlerFunc{}
+	}
+	s.handlers[strings.ToLower(cmd)] = h
+}
+
+// Commands returns the registered command names, sorted.
+func (s *Server) Commands() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]string, 0, len(s.handlers))
+	for cmd := range s.handlers {
+		out = append(out, cmd)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Start creates the socket and serves connections until ctx is done or Stop is called.
+func (s *Server) Start(ctx context.Context) error {
+	if s.Path == "" {
+		s.Path = DefaultSocket
+	}
+	gid := -1
+	if s.Group != "" {
+		g, err := user.LookupGroup(s.Group)
+		if err != nil {
+			return fmt.Errorf("control: %w", err)
+		}
+		if gid, err = strconv.Atoi(g.Gid); err != nil {
+			return fmt.Errorf("control: group %s: invalid gid %q", s.Group, g.Gid)
+		}
+	}
+	dir := filepath.Dir(s.Path)
+	if _, err := os.Stat(dir); errors.Is(err, os.ErrNotExist) {
+		if err := os.MkdirAll(dir, 0o750); err != nil {
+			return fmt.Errorf("control: %w", err)
+		}
+		if gid >= 0 {
+			_ = os.Chown(dir, -1, gid)
+		}
+	}
+	if err := removeStale(s.Path); err != nil {
+		return err
+	}
+	ln, err := listenPrivate(s.Path)
+	if err != nil {
+		return fmt.Errorf("control: listen %s: %w", s.Path, err)
+	}
+	if gid >= 0 {
+		if err := os.Chown(s.Path, -1, gid); err != nil {
+			_ = ln.Close()
+			return fmt.Errorf("control: chown %s to group %s: %w", s.Path, s.Group, err)
+		}
+	}
+	if err := os.Chmod(s.Path, 0o660); err != nil {
+		_ = ln.Close()
+		return fmt.Errorf("control: %w", err)
+	}
+	s.ln = ln
+	s.logf("control: listening on %s", s.Path)
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				if !errors.Is(err, net.ErrClosed) && ctx.Err() == nil {
+					s.logf("control: accept error: %v", err)
+				}
+				return
+			}
+			if !s.track(conn) {
+				_ = conn.Close()
+				return
+			}
+			s.wg.Add(1)
+			go func() {
+				defer s.wg.Done()
+				defer s.untrack(conn)
+				s.handleConn(conn)
+			}()
+		}
+	}()
+	go func() {
+		<-ctx.Done()
+		_ = s.Stop()
+	}()
+	return nil
+}
+
+// Stop closes the socket and open connections, and waits for running
+// commands to finish.
+func (s *Server) Stop() error {
+	var err error
+	s.once.Do(func() {
+		if s.ln != nil {
+			err = s.ln.Close() // also removes the socket file
+		}
+		s.connMu.Lock()
+		s.stopped = true
+		for c := range s.conns {
+			_ = c.Close() // idle clients stop waiting; running commands still answer
+		}
+		s.connMu.Unlock()
+	})
+	s.wg.Wait()
+	return err
+}
+
+// track registers an accepted connection for Stop to close. It returns false
+// once the server is stopping.
+func (s *Server) track(c net.Conn) bool {
+	s.connMu.Lock()
+	defer s.connMu.Unlock()
+	if s.stopped {
+		return false
+	}
+	if s.conns == nil {
+		s.conns = map[net.Conn]struct{}{}
+	}
+	s.conns[c] = struct{}{}
+	return true
+}
+
+func (s *Server) untrack(c net.Conn) {
+	s.connMu.Lock()
+	defer s.connMu.Unlock()
+	delete(s.conns, c)
+}
+
+// removeStale removes a socket left behind by a previous run, but refuses
+// to take over one that is still in use.
+func removeStale(path string) error {
+	fi, err := os.Lstat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("control: %w", err)
+	}
+	if fi.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("control: %s exists and is not a socket", path)
+	}
+	if c, err := net.DialTimeout("unix", path, time.Second); err == nil {
+		_ = c.Close()
+		return fmt.Errorf("control: %s is in use by another process", path)
+	}
+	return os.Remove(path)
+}
+
+func (s *Server) handleConn(c net.Conn) {
+	defer c.Close()
+	sc := bufio.NewScanner(c)
+	sc.Buffer(make([]byte, 0, 4096), 64*1024)
+	enc := json.NewEncoder(c)
+	for {
+		_ = c.SetDeadline(time.Now().Add(time.Minute))
+		if !sc.Scan() {
+			return
+		}
+		line := strings.TrimSpace(sc.Text())
+		if line == "" {
+			continue
+		}
+		req, err := ParseRequest(line)
+		var resp Response
+		if err != nil {
+			resp = Response{Error: err.Error()}
+		} else {
+			resp = s.dispatch(req)
+		}
+		if err := enc.Encode(resp); err != nil {
+			return
+		}
+	}
+}
+
+func (s *Server) dispatch(req Request) Response {
+	s.mu.RLock()
+	h := s.handlers[strings.ToLower(req.Cmd)]
+	s.mu.RUnlock()
+	if h == nil {
+		return Response{Error: fmt.Sprintf("unknown command %q (commands: %s)", req.Cmd, strings.Join(s.Commands(), ", "))}
+	}
+	s.logf("control: %s %s", req.Cmd, strings.Join(req.Args, " "))
+	data, err := h(req.Args)
+	if err != nil {
+		return Response{Error: err.Error()}
+	}
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return Response{Error: fmt.Sprintf("encode result: %v", err)}
+	}
+	return Response{OK: true, Data: raw}
+}
+
+// ParseRequest parses a JSON request object or a plain text command line.
+func ParseRequest(line string) (Request, error) {
+	var req Request
+	if strings.HasPrefix(line, "{") {
+		if err := json.Unmarshal([]byte(line), &req); err != nil {
+			return req, fmt.Errorf("invalid request: %v", err)
+		}
+	} else {
+		f := strings.Fields(line)
+		req = Request{Cmd: f[0], Args: f[1:]}
+	}
+	if req.Cmd == "" {
+		return req, fmt.Errorf("invalid request: missing cmd")
+	}
+	return req, nil
+}
+
+// Call sends req to the control socket at path and returns the response.
+// A command that failed on the server is returned as an error.
+func Call(path string, req Request, timeout time.Duration) (json.RawMessage, error) {
+	c, err := net.DialTimeout("unix", path, timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+	_ = c.SetDeadline(time.Now().Add(timeout))
+	if err := json.NewEncoder(c).Encode(req); err != nil {
+		return nil, err
+	}
+	var resp Response
+	if err := json.NewDecoder(c).Decode(&resp); err != nil {
+		return nil, fmt.Errorf("read response: %w", err)
+	}
+	if !resp.OK {
+		return nil, errors.New(resp.Error)
+	}
+	return resp.Data, nil
+}
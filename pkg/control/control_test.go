@@ -0,0 +1,156 @@
+package control
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func startServer(t *testing.T) *Server {
+	t.Helper()
+	s := &Server{Path: filepath.Join(t.TempDir(), "run", "ircpush.sock")}
+	s.Handle("echo", func(args []string) (any, error) { return strings.Join(args, " "), nil })
+	s.Handle("fail", func(args []string) (any, error) { return nil, errors.New("no such channel") })
+	if err := s.Start(context.Background()); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	t.Cleanup(func() { _ = s.Stop() })
+	return s
+}
+
+// TestCall verifies JSON requests, error responses and socket permissions.
+func TestCall(t *testing.T) {
+	s := startServer(t)
+
+	fi, err := os.Stat(s.Path)
+	if err != nil {
+		t.Fatalf("stat: %v", err)
+	}
+	if perm := fi.Mode().Perm(); perm != 0o660 {
+		t.Errorf("expected socket mode 0660, but got %o", perm)
+	}
+
+	// Setup test cases
+	tests := []struct {
+		name     string
+		req      Request
+		expected string
+		err      string
+	}{
+		{name: "Echo", req: Request{Cmd: "echo", Args: []string{"#a", "hello world"}}, expected: `"#a hello world"`},
+		{name: "CaseInsensitive", req: Request{Cmd: "ECHO"}, expected: `""`},
+		{name: "HandlerError", req: Request{Cmd: "fail"}, err: "no such channel"},
+		{name: "UnknownCommand", req: Request{Cmd: "nope"}, err: `unknown command "nope" (commands: echo, fail)`},
+	}
+
+	// Run test cases
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data, err := Call(s.Path, test.req, 2*time.Second)
+			if test.err != "" {
+				if err == nil || err.Error() != test.err {
+					t.Fatalf("expected error %q, but got %v", test.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Call: %v", err)
+			}
+			if string(data) != test.expected {
+				t.Errorf("expected %s, but got %s", test.expected, data)
+			}
+		})
+	}
+}
+
+// TestPlainText verifies line-based commands on one connection.
+func TestPlainText(t *testing.T) {
+	s := startServer(t)
+	c, err := net.Dial("unix", s.Path)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer c.Close()
+	_ = c.SetDeadline(time.Now().Add(2 * time.Second))
+
+	_, _ = c.Write([]byte("echo one two\n\n{\"cmd\":\"fail\"}\n{bad\n"))
+	br := bufio.NewReader(c)
+	var got []Response
+	for i := 0; i < 3; i++ {
+		line, err := br.ReadBytes('\n')
+		if err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		var r Response
+		if err := json.Unmarshal(line, &r); err != nil {
+			t.Fatalf("decode %q: %v", line, err)
+		}
+		got = append(got, r)
+	}
+	if !got[0].OK || string(got[0].Data) != `"one two"` {
+		t.Errorf("unexpected response to plain text: %+v", got[0])
+	}
+	if got[1].OK || got[1].Error != "no such channel" {
+		t.Errorf("unexpected response to failing command: %+v", got[1])
+	}
+	if got[2].OK || !strings.HasPrefix(got[2].Error, "invalid request") {
+		t.Errorf("unexpected response to invalid json: %+v", got[2])
+	}
+}
+
+// TestStaleSocket verifies a leftover socket is replaced but a live one is not.
+func TestStaleSocket(t *testing.T) {
+	s := startServer(t)
+	other := &Server{Path: s.Path}
+	if err := other.Start(context.Background()); err == nil {
+		_ = other.Stop()
+		t.Fatalf("expected error for a socket in use")
+	}
+
+	// Leave a socket file behind without a listener
+	path := filepath.Join(t.TempDir(), "stale.sock")
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	ln.(*net.UnixListener).SetUnlinkOnClose(false)
+	_ = ln.Close()
+
+	fresh := &Server{Path: path}
+	if err := fresh.Start(context.Background()); err != nil {
+		t.Fatalf("Start over stale socket: %v", err)
+	}
+	_ = fresh.Stop()
+}
+
+// TestStopClosesIdleConnections verifies that a client that never sends a
+// command does not hold up Stop.
+func TestStopClosesIdleConnections(t *testing.T) {
+	s := startServer(t)
+	c, err := net.Dial("unix", s.Path)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer c.Close()
+	if _, err := Call(s.Path, Request{Cmd: "echo"}, 2*time.Second); err != nil {
+		t.Fatalf("Call: %v", err) // the idle connection has been accepted by now
+	}
+
+	done := make(chan struct{})
+	go func() {
+		_ = s.Stop()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("Stop waited for an idle connection")
+	}
+}
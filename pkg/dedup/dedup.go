@@ -0,0 +1,228 @@
+/*
+MIT License
+
+Copyright (c) 2025 Mikael Schultz <mikael@conf-t.se>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package dedup
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bitcanon/ircpush/pkg/config"
+)
+
+const (
+	defaultWindow = 5 * time.Minute
+	maxKeys       = 10000 // beyond this, new messages are not tracked (always sent)
+)
+
+// DefaultNormalize strips the parts of a line that typically differ between
+// repeats of the same event: timestamps and process IDs.
+var DefaultNormalize = []string{
+	`\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}:\d{2}(?:\.\d+)?(?:Z|[+-]\d{2}:?\d{2})?`, // ISO 8601 / RFC 3339
+	`\b[A-Z][a-z]{2} +\d{1,2} \d{2}:\d{2}:\d{2}(?:\.\d+)?\b`,                  // BSD syslog "Oct 11 22:14:15"
+	`\[\d+\]`, // PIDs, e.g. sshd[1234]
+}
+
+// entry tracks one normalized message in one channel.
+type entry struct {
+	channel string
+	text    string // first occurrence, used in the summary
+	first   time.Time
+	last    time.Time
+	count   int // repeats suppressed after the first occurrence
+}
+
+// Deduper suppresses repeats of the same message per channel. The first
+// occurrence passes; repeats within the window are counted and reported as a
+// single "(repeated N times in 5m)" line when the window ends.
+type Deduper struct {
+	// Emit receives summary lines. It must be set before Start.
+	Emit func(channel, text string)
+
+	window   time.Duration
+	norm     []*regexp.Regexp
+	channels []string // lower-cased glob patterns; empty => all channels
+
+	mu   sync.Mutex
+	seen map[string]*entry
+	stop chan struct{}
+	done chan struct{}
+	now  func() time.Time // for tests
+}
+
+// New compiles the dedup config. Invalid normalize patterns are an error.
+func New(dc config.DedupConfig) (*Deduper, error) {
+	d := &Deduper{
+		window: dc.Window,
+		seen:   map[string]*entry{},
+		now:    time.Now,
+	}
+	if d.window <= 0 {
+		d.window = defaultWindow
+	}
+	patterns := dc.Normalize
+	if len(patterns) == 0 {
+		patterns = DefaultNormalize
+	}
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("dedup: normalize %q: %w", p, err)
+		}
+		d.norm = append(d.norm, re)
+	}
+	for _, ch := range dc.Channels {
+		if ch = strings.TrimSpace(ch); ch != "" {
+			d.channels = append(d.channels, strings.ToLower(ch))
+		}
+	}
+	return d, nil
+}
+
+// Key returns the normalized form of text used to detect repeats.
+func (d *Deduper) Key(text string) string {
+	for _, re := range d.norm {
+		text = re.ReplaceAllString(text, "")
+	}
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// enabledFor reports whether dedup applies to channel ("" = broadcast).
+func (d *Deduper) enabledFor(channel string) bool {
+	if len(d.channels) == 0 {
+		return true
+	}
+	ch := strings.ToLower(channel)
+	for _, p := range d.channels {
+		if ok, _ := filepath.Match(p, ch); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Allow reports whether text should be sent to channel now.
+func (d *Deduper) Allow(channel, text string) bool {
+	if !d.enabledFor(channel) {
+		return true
+	}
+	key := strings.ToLower(channel) + "\x00" + d.Key(text)
+	now := d.now()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if e := d.seen[key]; e != nil && now.Sub(e.first) < d.window {
+		e.count++
+		e.last = now
+		return false
+	}
+	if len(d.seen) >= maxKeys {
+		return true
+	}
+	d.seen[key] = &entry{channel: channel, text: text, first: now, last: now}
+	return true
+}
+
+// Start runs the background sweeper that emits summaries for ended windows.
+func (d *Deduper) Start() {
+	d.stop = make(chan struct{})
+	d.done = make(chan struct{})
+	interval := min(max(d.window/10, time.Second), 30*time.Second)
+	go func() {
+		defer close(d.done)
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-d.stop:
+				return
+			case <-t.C:
+				d.flush(false)
+			}
+		}
+	}()
+}
+
+// Stop ends the sweeper and emits summaries for all pending repeats.
+func (d *Deduper) Stop() {
+	if d.stop != nil {
+		close(d.stop)
+		<-d.done
+		d.stop = nil
+	}
+	d.flush(true)
+}
+
+// flush removes ended windows (all windows when force is set) and emits their summaries.
+func (d *Deduper) flush(force bool) {
+	now := d.now()
+	var out []*entry
+	d.mu.Lock()
+	for k, e := range d.seen {
+		if force || now.Sub(e.first) >= d.window {
+			delete(d.seen, k)
+			if e.count > 0 {
+				out = append(out, e)
+			}
+		}
+	}
+	d.mu.Unlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].first.Before(out[j].first) })
+
+	if d.Emit == nil {
+		return
+	}
+	for _, e := range out {
+		d.Emit(e.channel, summary(e))
+	}
+}
+
+// summary renders e.g. "line protocol down (repeated 37 times in 5m)".
+func summary(e *entry) string {
+	times := "times"
+	if e.count == 1 {
+		times = "time"
+	}
+	return fmt.Sprintf("%s (repeated %d %s in %s)", e.text, e.count, times, shortDuration(e.last.Sub(e.first)))
+}
+
+// shortDuration formats d rounded to seconds without zero units: "5m", "1h2m", "42s".
+func shortDuration(d time.Duration) string {
+	d = d.Round(time.Second)
+	if d < time.Second {
+		return "1s"
+	}
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
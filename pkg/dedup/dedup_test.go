@@ -0,0 +1,128 @@
+package dedup
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/bitcanon/ircpush/pkg/config"
+)
+
+// TestKey tests the default normalization of repeated syslog lines.
+func TestKey(t *testing.T) {
+	d, err := New(config.DedupConfig{})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	// Setup test cases
+	tests := []struct {
+		name     string
+		a, b     string
+		expected bool // same key
+	}{
+		{
+			name:     "BSDTimestampAndPID",
+			a:        "Oct 11 22:14:15 sw1 ifmgr[231]: Gi0/1 line protocol down",
+			b:        "Oct 11 22:14:47 sw1 ifmgr[232]: Gi0/1 line protocol down",
+			expected: true,
+		},
+		{
+			name:     "ISOTimestamp",
+			a:        "2025-01-02T03:04:05.123Z fw1 link flap",
+			b:        "2025-01-02T03:09:59+01:00 fw1  link flap",
+			expected: true,
+		},
+		{
+			name:     "DifferentInterface",
+			a:        "sw1: Gi0/1 line protocol down",
+			b:        "sw1: Gi0/2 line protocol down",
+			expected: false,
+		},
+	}
+	// Run test cases
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := d.Key(test.a) == d.Key(test.b); got != test.expected {
+				t.Errorf("expected same=%v for %q and %q (keys %q, %q)", test.expected, test.a, test.b, d.Key(test.a), d.Key(test.b))
+			}
+		})
+	}
+}
+
+// TestAllowAndSummary verifies first-occurrence passthrough, per-channel keys and the summary line.
+func TestAllowAndSummary(t *testing.T) {
+	d, err := New(config.DedupConfig{Window: 5 * time.Minute, Normalize: []string{`\d+ms`}})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	now := time.Unix(1000, 0)
+	d.now = func() time.Time { return now }
+	var got []string
+	d.Emit = func(ch, text string) { got = append(got, ch+" "+text) }
+
+	if !d.Allow("#network", "rtt 10ms up") {
+		t.Fatalf("first occurrence must pass")
+	}
+	if !d.Allow("#ops", "rtt 10ms up") {
+		t.Fatalf("same text in another channel must pass")
+	}
+	for i := 0; i < 37; i++ {
+		now = now.Add(5 * time.Second)
+		if d.Allow("#network", "rtt 12ms up") {
+			t.Fatalf("repeat %d passed", i)
+		}
+	}
+
+	d.flush(false)
+	if len(got) != 0 {
+		t.Fatalf("summary emitted before the window ended: %#v", got)
+	}
+	now = time.Unix(1000, 0).Add(5 * time.Minute)
+	d.flush(false)
+	expected := []string{"#network rtt 10ms up (repeated 37 times in 3m5s)"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %#v, but got %#v", expected, got)
+	}
+	if !d.Allow("#network", "rtt 10ms up") {
+		t.Errorf("message after the window must pass again")
+	}
+}
+
+// TestChannels verifies per-channel enablement with glob patterns.
+func TestChannels(t *testing.T) {
+	d, err := New(config.DedupConfig{Channels: []string{"#net*"}})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	d.Allow("#ops", "x")
+	if !d.Allow("#ops", "x") {
+		t.Errorf("dedup applied to a channel that is not enabled")
+	}
+	d.Allow("#network", "x")
+	if d.Allow("#NETWORK", "x") {
+		t.Errorf("dedup not applied to an enabled channel")
+	}
+}
+
+// TestNewInvalidPattern rejects invalid normalize regexes.
+func TestNewInvalidPattern(t *testing.T) {
+	if _, err := New(config.DedupConfig{Normalize: []string{"("}}); err == nil {
+		t.Errorf("expected an error but got nil")
+	}
+}
+
+// TestShortDuration tests the summary duration format.
+func TestShortDuration(t *testing.T) {
+	for in, expected := range map[time.Duration]string{
+		0:                         "1s",
+		42 * time.Second:          "42s",
+		5 * time.Minute:           "5m",
+		time.Hour + 2*time.Minute: "1h2m",
+		2 * time.Hour:             "2h",
+		90*time.Second + 400*1e6:  "1m30s",
+	} {
+		if got := shortDuration(in); got != expected {
+			t.Errorf("shortDuration(%s): expected %q, but got %q", in, expected, got)
+		}
+	}
+}
@@ -0,0 +1,250 @@
+/*
+MIT License
+
+Copyright (c) 2025 Mikael Schultz <mikael@conf-t.se>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/bitcanon/ircpush/pkg/irc"
+)
+
+const defaultMaxDown = 5 * time.Minute
+
+// Source reports the IRC connection state. It is implemented by *irc.Client.
+type Source interface {
+	Status() irc.Status
+}
+
+// Checker turns the IRC connection state into liveness and readiness.
+//
+// Ready means registered with the configured channels joined, except those
+// the client was kicked from or could not join (see irc.Client.Ready). Healthy means
+// registered, or not registered for less than MaxDown; a bot stuck in the
+// reconnector (K-line, long netsplit) becomes unhealthy, so /healthz fails and
+// the systemd watchdog is no longer fed.
+type Checker struct {
+	Source  Source
+	MaxDown time.Duration // 0 => 5m
+
+	// Networks are further IRC networks (optional). They must be ready for
+	// Ready, but don't affect Healthy: a restart by the watchdog would take
+	// down the primary network too, without fixing the other one.
+	Networks []Network
+
+	// Optional logging sink; if nil, logs go to stderr.
+	Logger Logger
+
+	now func() time.Time // for tests
+}
+
+// Logger is a minimal logger interface.
+type Logger interface {
+	Printf(format string, v ...any)
+}
+
+func (c *Checker) logf(format string, v ...any) {
+	if c.Logger != nil {
+		c.Logger.Printf(format, v...)
+		return
+	}
+	fmt.Fprintf(os.Stderr, format+"\n", v...)
+}
+
+// Network is a further IRC network watched by a Checker.
+type Network struct {
+	Name   string
+	Source Source
+}
+
+// Report is the JSON body of /healthz and /readyz. The top-level fields
+// describe the primary network, except Ready which covers all of them.
+type Report struct {
+	Network         string    `json:"network,omitempty"`
+	Status          string    `json:"status"` // "ready", "joining", "connecting" or "down"
+	Healthy         bool      `json:"healthy"`
+	Ready           bool      `json:"ready"`
+	Registered      bool      `json:"registered"`
+	Server          string    `json:"server"`
+	Nick            string    `json:"nick"`
+	WantNick        string    `json:"want_nick,omitempty"` // the configured nick while another one is in use
+	Channels        []Channel `json:"channels"`
+	StateSeconds    float64   `json:"state_seconds"`         // since registration state last changed
+	LastSendSeconds *float64  `json:"last_send_seconds"`     // since the last successful send; null if none yet
+	LagSeconds      *float64  `json:"lag_seconds,omitempty"` // round trip of the last client PING
+	Networks        []Report  `json:"networks,omitempty"`
+}
+
+// Channel is the join state of one configured channel.
+type Channel struct {
+	Name           string   `json:"name"`
+	Joined         bool     `json:"joined"`
+	Error          string   `json:"error,omitempty"`            // why it is not joined, e.g. "banned (474)"
+	RetryInSeconds *float64 `json:"retry_in_seconds,omitempty"` // until the next join attempt
+}
+
+func (c *Checker) clock() time.Time {
+	if c.now != nil {
+		return c.now()
+	}
+	return time.Now()
+}
+
+func (c *Checker) maxDown() time.Duration {
+	if c.MaxDown > 0 {
+		return c.MaxDown
+	}
+	return defaultMaxDown
+}
+
+// Report returns the current state.
+func (c *Checker) Report() Report {
+	now := c.clock()
+	r := c.report(c.Source.Status(), now)
+	for _, n := range c.Networks {
+		nr := c.report(n.Source.Status(), now)
+		nr.Network = n.Name
+		r.Ready = r.Ready && nr.Ready
+		r.Networks = append(r.Networks, nr)
+	}
+	return r
+}
+
+func (c *Checker) report(st irc.Status, now time.Time) Report {
+	r := Report{
+		Ready:        st.Ready,
+		Registered:   st.Registered,
+		Server:       st.Server,
+		Nick:         st.Nick,
+		Channels:     []Channel{},
+		StateSeconds: seconds(now.Sub(st.Since)),
+	}
+	if st.WantNick != "" && !strings.EqualFold(st.WantNick, st.Nick) {
+		r.WantNick = st.WantNick
+	}
+	for _, ch := range st.Channels {
+		rc := Channel{Name: ch.Name, Joined: ch.Joined, Error: ch.Error}
+		if !ch.RetryAt.IsZero() {
+			s := seconds(max(ch.RetryAt.Sub(now), 0))
+			rc.RetryInSeconds = &s
+		}
+		r.Channels = append(r.Channels, rc)
+	}
+	if !st.LastSend.IsZero() {
+		s := seconds(now.Sub(st.LastSend))
+		r.LastSendSeconds = &s
+	}
+	if st.Lag > 0 {
+		s := seconds(st.Lag)
+		r.LagSeconds = &s
+	}
+	down := now.Sub(st.Since)
+	switch {
+	case st.Ready:
+		r.Status = "ready"
+	case st.Registered:
+		r.Status = "joining"
+	case down < c.maxDown():
+		r.Status = "connecting"
+	default:
+		r.Status = "down"
+	}
+	r.Healthy = r.Status != "down"
+	return r
+}
+
+// Healthy reports whether the primary IRC network is registered or has been
+// down for less than MaxDown.
+func (c *Checker) Healthy() bool {
+	return c.Report().Healthy
+}
+
+// LiveHandler serves /healthz: 200 while healthy, 503 otherwise.
+func (c *Checker) LiveHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		r := c.Report()
+		writeReport(w, r, r.Healthy)
+	})
+}
+
+// ReadyHandler serves /readyz: 200 when every network is ready, 503 otherwise.
+func (c *Checker) ReadyHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		r := c.Report()
+		writeReport(w, r, r.Ready)
+	})
+}
+
+func writeReport(w http.ResponseWriter, r Report, ok bool) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if !ok {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	_ = json.NewEncoder(w).Encode(r)
+}
+
+// Watchdog feeds the systemd watchdog (WATCHDOG=1) while the checker is healthy,
+// until ctx is done. It returns immediately when the watchdog is not enabled.
+func (c *Checker) Watchdog(ctx context.Context) {
+	interval := WatchdogInterval()
+	if interval <= 0 {
+		return
+	}
+	c.logf("health: systemd watchdog enabled (timeout %s)", interval)
+	t := time.NewTicker(interval / 2)
+	defer t.Stop()
+	withheld := false
+	for {
+		if c.Healthy() {
+			if withheld {
+				c.logf("health: irc recovered, feeding the systemd watchdog again")
+				withheld = false
+			}
+			if _, err := Notify("WATCHDOG=1"); err != nil {
+				c.logf("health: sd_notify: %v", err)
+			}
+		} else if !withheld {
+			c.logf("health: irc down for more than %s, withholding the systemd watchdog", c.maxDown())
+			withheld = true
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+func seconds(d time.Duration) float64 {
+	if d < 0 {
+		d = 0
+	}
+	return float64(d.Round(time.Millisecond)) / float64(time.Second)
+}
@@ -0,0 +1,176 @@
+package health
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bitcanon/ircpush/pkg/irc"
+)
+
+type fakeSource struct{ st irc.Status }
+
+func (f *fakeSource) Status() irc.Status { return f.st }
+
+// TestReport verifies status, liveness and readiness for each connection state.
+func TestReport(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	chans := func(joined ...bool) []irc.ChannelState {
+		out := []irc.ChannelState{}
+		for i, j := range joined {
+			out = append(out, irc.ChannelState{Name: []string{"#a", "#b"}[i], Joined: j})
+		}
+		return out
+	}
+
+	// Setup test cases
+	tests := []struct {
+		name       string
+		st         irc.Status
+		status     string
+		healthCode int
+		readyCode  int
+	}{
+		{
+			name:       "Ready",
+			st:         irc.Status{Registered: true, Ready: true, Channels: chans(true, true), Since: now.Add(-time.Hour)},
+			status:     "ready",
+			healthCode: http.StatusOK,
+			readyCode:  http.StatusOK,
+		},
+		{
+			name:       "Joining",
+			st:         irc.Status{Registered: true, Channels: chans(true, false), Since: now.Add(-time.Second)},
+			status:     "joining",
+			healthCode: http.StatusOK,
+			readyCode:  http.StatusServiceUnavailable,
+		},
+		{
+			name:       "Reconnecting",
+			st:         irc.Status{Channels: chans(false, false), Since: now.Add(-time.Minute)},
+			status:     "connecting",
+			healthCode: http.StatusOK,
+			readyCode:  http.StatusServiceUnavailable,
+		},
+		{
+			name:       "DownTooLong",
+			st:         irc.Status{Channels: chans(false, false), Since: now.Add(-10 * time.Minute)},
+			status:     "down",
+			healthCode: http.StatusServiceUnavailable,
+			readyCode:  http.StatusServiceUnavailable,
+		},
+	}
+
+	// Run test cases
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := &Checker{Source: &fakeSource{st: test.st}, now: func() time.Time { return now }}
+
+			for _, h := range []struct {
+				handler http.Handler
+				code    int
+			}{{c.LiveHandler(), test.healthCode}, {c.ReadyHandler(), test.readyCode}} {
+				rec := httptest.NewRecorder()
+				h.handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+				if rec.Code != h.code {
+					t.Errorf("expected status code %d, but got %d", h.code, rec.Code)
+				}
+				var r Report
+				if err := json.Unmarshal(rec.Body.Bytes(), &r); err != nil {
+					t.Fatalf("decode body: %v", err)
+				}
+				if r.Status != test.status {
+					t.Errorf("expected status %q, but got %q", test.status, r.Status)
+				}
+				if len(r.Channels) != len(test.st.Channels) {
+					t.Errorf("expected %d channels, but got %v", len(test.st.Channels), r.Channels)
+				}
+			}
+		})
+	}
+}
+
+// TestReportLastSend verifies the time since the last send is null until something was sent.
+func TestReportLastSend(t *testing.T) {
+	now := time.Now()
+	src := &fakeSource{st: irc.Status{Since: now}}
+	c := &Checker{Source: src, now: func() time.Time { return now }}
+	if r := c.Report(); r.LastSendSeconds != nil {
+		t.Errorf("expected no last send, but got %v", *r.LastSendSeconds)
+	}
+	src.st.LastSend = now.Add(-1500 * time.Millisecond)
+	if r := c.Report(); r.LastSendSeconds == nil || *r.LastSendSeconds != 1.5 {
+		t.Errorf("expected last send 1.5s ago, but got %v", r.LastSendSeconds)
+	}
+}
+
+// TestReportNetworks verifies that further networks count for readiness but not for health.
+func TestReportNetworks(t *testing.T) {
+	now := time.Now()
+	primary := &fakeSource{st: irc.Status{Registered: true, Ready: true, Since: now.Add(-time.Hour)}}
+	libera := &fakeSource{st: irc.Status{Server: "irc.libera.chat:6697", Since: now.Add(-time.Hour)}}
+	c := &Checker{Source: primary, Networks: []Network{{Name: "libera", Source: libera}}, now: func() time.Time { return now }}
+
+	r := c.Report()
+	if !r.Healthy || r.Ready || r.Status != "ready" {
+		t.Errorf("expected healthy, not ready and primary status ready, but got healthy=%v ready=%v status=%q", r.Healthy, r.Ready, r.Status)
+	}
+	if len(r.Networks) != 1 || r.Networks[0].Network != "libera" || r.Networks[0].Status != "down" {
+		t.Errorf("expected libera to be reported down, but got %+v", r.Networks)
+	}
+	libera.st = irc.Status{Registered: true, Ready: true, Since: now}
+	if r := c.Report(); !r.Ready {
+		t.Errorf("expected ready once every network is ready")
+	}
+}
+
+// TestNotify verifies sd_notify messages reach $NOTIFY_SOCKET.
+func TestNotify(t *testing.T) {
+	t.Setenv("NOTIFY_SOCKET", "")
+	if ok, err := Notify("READY=1"); ok || err != nil {
+		t.Fatalf("expected no-op without NOTIFY_SOCKET, but got %v, %v", ok, err)
+	}
+
+	path := filepath.Join(t.TempDir(), "notify.sock")
+	ln, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+	t.Setenv("NOTIFY_SOCKET", path)
+
+	if ok, err := Notify("READY=1"); !ok || err != nil {
+		t.Fatalf("Notify: %v, %v", ok, err)
+	}
+	buf := make([]byte, 64)
+	_ = ln.SetReadDeadline(time.Now().Add(2 * time.Second))
+	n, err := ln.Read(buf)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if got := string(buf[:n]); got != "READY=1" {
+		t.Errorf("expected %q, but got %q", "READY=1", got)
+	}
+}
+
+// TestWatchdogInterval verifies parsing of WATCHDOG_USEC and WATCHDOG_PID.
+func TestWatchdogInterval(t *testing.T) {
+	t.Setenv("WATCHDOG_USEC", "30000000")
+	t.Setenv("WATCHDOG_PID", "")
+	if got := WatchdogInterval(); got != 30*time.Second {
+		t.Errorf("expected 30s, but got %s", got)
+	}
+	t.Setenv("WATCHDOG_PID", "1")
+	if got := WatchdogInterval(); got != 0 {
+		t.Errorf("expected 0 for another pid, but got %s", got)
+	}
+	t.Setenv("WATCHDOG_USEC", "")
+	t.Setenv("WATCHDOG_PID", "")
+	if got := WatchdogInterval(); got != 0 {
+		t.Errorf("expected 0 when disabled, but got %s", got)
+	}
+}
@@ -0,0 +1,87 @@
+/*
+MIT License
+
+Copyright (c) 2025 Mikael Schultz <mikael@conf-t.se>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package highlight
+
+import (
+	"sort"
+	"strings"
+)
+
+// Match is the result of one rule for a line, as reported by Explain.
+type Match struct {
+	Rule      int      // index in highlight.rules
+	Skipped   bool     // the pattern matches but the rule's channel filters exclude the channel
+	WholeLine bool     // a whole_line rule; it styles the line around the other rules' spans
+	Spans     [][2]int // byte offsets [start, end) in the plain line that the rule styles (or would, when skipped)
+	Dropped   [][2]int // matches not styled because they overlap a rule with higher priority
+}
+
+// Explain reports, in rule order, which rules style s when it is sent to
+// channel and which spans they style, which of their matches lost to rules
+// with higher priority, and which rules would have matched but were
+// skipped because of their channel filters.
+func (h *Highlighter) Explain(channel, s string) []Match {
+	var out []Match
+	if s == "" {
+		return out
+	}
+	byRule := map[int]*Match{}
+	for _, sp := range h.layout(strings.ToLower(strings.TrimSpace(channel)), s) {
+		m := byRule[sp.rule.idx]
+		if m == nil {
+			m = &Match{Rule: sp.rule.idx, WholeLine: sp.rule.wholeLine, Skipped: sp.state == spanSkipped}
+			byRule[sp.rule.idx] = m
+		}
+		if sp.state == spanDropped {
+			m.Dropped = append(m.Dropped, [2]int{sp.start, sp.end})
+		} else {
+			m.Spans = append(m.Spans, [2]int{sp.start, sp.end})
+		}
+	}
+	for _, m := range byRule {
+		out = append(out, *m)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Rule < out[j].Rule })
+	return out
+}
+
+// ruleSpans returns the parts of s that r styles: whole matches, or only
+// the selected capture groups.
+func ruleSpans(r compiledRule, s string) []span {
+	var spans []span
+	for _, m := range r.re.FindAllStringSubmatchIndex(s, -1) {
+		if len(r.groupIdxs) == 0 {
+			if m[0] < m[1] {
+				spans = append(spans, span{start: m[0], end: m[1]})
+			}
+			continue
+		}
+		for _, g := range r.groupIdxs {
+			if 2*g+1 < len(m) && m[2*g] >= 0 && m[2*g] < m[2*g+1] {
+				spans = append(spans, span{start: m[2*g], end: m[2*g+1]})
+			}
+		}
+	}
+	return spans
+}
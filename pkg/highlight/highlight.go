@@ -24,6 +24,7 @@ SOFTWARE.
 package highlight
 
 import (
+	"fmt"
 	"path/filepath"
 	"regexp"
 	"sort"
@@ -31,22 +32,18 @@ import (
 	"strings"
 
 	"github.com/bitcanon/ircpush/pkg/config"
-)
-
-const (
-	ircColor = "\x03"
-	ircBold  = "\x02"
-	ircUnder = "\x1F"
-	ircReset = "\x0F"
+	"github.com/bitcanon/ircpush/pkg/ircfmt"
 )
 
 type Highlighter struct {
-	rules []compiledRule
+	rules []compiledRule // by priority: highest first, then in config order
 }
 
 type compiledRule struct {
+	idx        int // index in highlight.rules
+	priority   int
 	re         *regexp.Regexp
-	stylePref  string
+	st         style
 	wholeLine  bool
 	includes   []string
 	excludes   []string
@@ -56,14 +53,16 @@ type compiledRule struct {
 
 func New(hc config.HighlightConfig) *Highlighter {
 	hl := &Highlighter{}
-	for _, r := range hc.Rules {
+	for i, r := range hc.Rules {
 		re := compileRule(r)
 		if re == nil {
 			continue
 		}
 		cr := compiledRule{
+			idx:        i,
+			priority:   r.Priority,
 			re:         re,
-			stylePref:  buildStyle(r),
+			st:         buildStyle(r),
 			wholeLine:  r.WholeLine,
 			includes:   nil,
 			excludes:   nil,
@@ -108,6 +107,7 @@ func New(hc config.HighlightConfig) *Highlighter {
 
 		hl.rules = append(hl.rules, cr)
 	}
+	sort.SliceStable(hl.rules, func(i, j int) bool { return hl.rules[i].priority > hl.rules[j].priority })
 	return hl
 }
 
@@ -118,37 +118,159 @@ func (h *Highlighter) Apply(s string) string {
 
 // ApplyFor applies highlighting considering the target channel.
 // If channel is empty, only rules without channel filters are considered.
+//
+// Every rule is matched against the plain text. Where matches overlap, the
+// rule with the higher priority (then the earlier rule) wins: a lower rule's
+// match that overlaps it is dropped, unless it encloses it, in which case
+// the winner is nested inside and the enclosing style resumes after it. A
+// matching whole_line rule styles the rest of the line the same way.
 func (h *Highlighter) ApplyFor(channel string, s string) string {
 	if s == "" || len(h.rules) == 0 {
 		return s
 	}
-	chLower := strings.ToLower(strings.TrimSpace(channel))
-	out := s
+	var shown []span
+	for _, sp := range h.layout(strings.ToLower(strings.TrimSpace(channel)), s) {
+		if sp.state == spanShown {
+			shown = append(shown, sp)
+		}
+	}
+	if len(shown) == 0 {
+		return s
+	}
+	return render(s, shown)
+}
+
+// Span states in a layout
+const (
+	spanShown   = iota
+	spanDropped // overlaps a match of a rule with higher priority
+	spanSkipped // the rule's channel filters exclude the channel
+)
+
+// span is a part of the plain text that a rule styles.
+type span struct {
+	start, end int
+	rule       *compiledRule
+	state      int
+}
+
+func (a span) contains(b span) bool {
+	return a.start <= b.start && b.end <= a.end
+}
 
-	// whole-line first
-	for _, r := range h.rules {
-		if !h.ruleAppliesTo(r, chLower) {
+// layout matches all rules against s and decides which spans are shown,
+// in rule priority order.
+func (h *Highlighter) layout(chLower, s string) []span {
+	var out []span
+	var shown []span
+	base := false
+	for i := range h.rules {
+		r := &h.rules[i]
+		applies := h.ruleAppliesTo(*r, chLower)
+		if r.wholeLine {
+			if !r.re.MatchString(s) {
+				continue
+			}
+			sp := span{start: 0, end: len(s), rule: r}
+			switch {
+			case !applies:
+				sp.state = spanSkipped
+			case base:
+				sp.state = spanDropped
+			default:
+				base = true
+			}
+			out = append(out, sp)
 			continue
 		}
-		if r.wholeLine && r.re.MatchString(out) {
-			return r.stylePref + out + ircReset
+		for _, sp := range ruleSpans(*r, s) {
+			sp.rule = r
+			if !applies {
+				sp.state = spanSkipped
+			} else if !fits(sp, shown) {
+				sp.state = spanDropped
+			} else {
+				shown = append(shown, sp)
+			}
+			out = append(out, sp)
 		}
 	}
+	return out
+}
 
-	// per-match/group
-	for _, r := range h.rules {
-		if !h.ruleAppliesTo(r, chLower) || r.wholeLine {
+// fits reports whether sp can be shown along with the spans of rules with
+// higher priority: it must not overlap them, other than by enclosing them.
+func fits(sp span, shown []span) bool {
+	for _, o := range shown {
+		if sp.end <= o.start || o.end <= sp.start {
 			continue
 		}
-		if len(r.groupIdxs) == 0 {
-			out = r.re.ReplaceAllStringFunc(out, func(m string) string {
-				return r.stylePref + m + ircReset
-			})
+		if sp.contains(o) && (sp.start != o.start || sp.end != o.end) {
 			continue
 		}
-		out = applyGroups(out, r.re, r.groupIdxs, r.stylePref)
+		return false
 	}
-	return out
+	return true
+}
+
+// render emits s with the styles of the spans, which are disjoint or
+// nested. Each change of style is written once; leaving a nested span
+// resets and restores the style of the spans around it.
+func render(s string, spans []span) string {
+	cuts := []int{0, len(s)}
+	for _, sp := range spans {
+		cuts = append(cuts, sp.start, sp.end)
+	}
+	sort.Ints(cuts)
+
+	var b strings.Builder
+	var cur style
+	for i := 0; i+1 < len(cuts); i++ {
+		from, to := cuts[i], cuts[i+1]
+		if from == to {
+			continue
+		}
+		st := styleAt(spans, from, to)
+		if st != cur {
+			if cur != (style{}) {
+				b.WriteString(ircfmt.Reset)
+			}
+			b.WriteString(st.codes())
+			// Keep a following ",N" from being read as a background color
+			if st.fg != "" && st.bg == "" && s[from] == ',' {
+				b.WriteString(ircfmt.Bold + ircfmt.Bold)
+			}
+			cur = st
+		}
+		b.WriteString(s[from:to])
+	}
+	if cur != (style{}) {
+		b.WriteString(ircfmt.Reset)
+	}
+	return b.String()
+}
+
+// styleAt combines the styles of the spans covering [from, to), from the
+// outermost to the innermost.
+func styleAt(spans []span, from, to int) style {
+	var cover []span
+	for _, sp := range spans {
+		if sp.start <= from && to <= sp.end {
+			cover = append(cover, sp)
+		}
+	}
+	sort.SliceStable(cover, func(i, j int) bool {
+		a, b := cover[i], cover[j]
+		if la, lb := a.end-a.start, b.end-b.start; la != lb {
+			return la > lb
+		}
+		return a.rule.wholeLine && !b.rule.wholeLine
+	})
+	var st style
+	for _, sp := range cover {
+		st = st.with(sp.rule.st)
+	}
+	return st
 }
 
 func (h *Highlighter) ruleAppliesTo(r compiledRule, chLower string) bool {
@@ -184,166 +306,121 @@ func globMatch(pattern, name string) bool {
 	return ok
 }
 
+// compileRule returns nil for rules config.Validate reports as invalid.
 func compileRule(r config.HighlightRule) *regexp.Regexp {
-	pat := strings.TrimSpace(r.Pattern)
-	if pat == "" {
-		return nil
-	}
-	switch strings.ToLower(r.Kind) {
-	case "regex":
-		if r.CaseInsensitive && !strings.HasPrefix(pat, "(?i)") {
-			pat = "(?i)" + pat
-		}
-	case "word", "":
-		pat = `\b` + regexp.QuoteMeta(pat) + `\b`
-		if r.CaseInsensitive {
-			pat = "(?i)" + pat
-		}
-	default:
-		return nil
-	}
-	re, err := regexp.Compile(pat)
+	re, err := r.Regexp()
 	if err != nil {
 		return nil
 	}
 	return re
 }
 
-func buildStyle(r config.HighlightRule) string {
+// style is the formatting of a rule.
+type style struct {
+	bold, italic, underline, strike, mono, reverse bool
+	fg, bg                                         string // palette code ("04") or "#RRGGBB", "" for none
+}
+
+// codes returns the IRC control codes that switch st on.
+func (st style) codes() string {
 	var b strings.Builder
-	if r.Bold {
-		b.WriteString(ircBold)
-	}
-	if r.Underline {
-		b.WriteString(ircUnder)
-	}
-	if code := colorToCode(r.Color); code != "" {
-		b.WriteString(ircColor)
-		b.WriteString(code)
+	for _, a := range []struct {
+		on   bool
+		code string
+	}{
+		{st.bold, ircfmt.Bold},
+		{st.italic, ircfmt.Italic},
+		{st.underline, ircfmt.Underline},
+		{st.strike, ircfmt.Strikethrough},
+		{st.mono, ircfmt.Monospace},
+		{st.reverse, ircfmt.Reverse},
+	} {
+		if a.on {
+			b.WriteString(a.code)
+		}
 	}
+	b.WriteString(st.colorCode())
 	return b.String()
 }
 
-func colorToCode(name string) string {
-	n := strings.TrimSpace(strings.ToLower(name))
-	if n == "" {
-		return ""
-	}
-	if isNumericColor(n) {
-		return normalizeNumeric(n)
-	}
-	switch n {
-	case "white":
-		return "00"
-	case "black":
-		return "01"
-	case "blue", "navy":
-		return "02"
-	case "green":
-		return "03"
-	case "red":
-		return "04"
-	case "brown", "maroon":
-		return "05"
-	case "purple":
-		return "06"
-	case "orange", "olive":
-		return "07"
-	case "yellow":
-		return "08"
-	case "lightgreen", "lime":
-		return "09"
-	case "teal", "cyan":
-		return "10"
-	case "lightcyan", "aqua":
-		return "11"
-	case "lightblue", "royal":
-		return "12"
-	case "pink", "fuchsia":
-		return "13"
-	case "grey", "gray":
-		return "14"
-	case "lightgrey", "lightgray", "silver":
-		return "15"
-	default:
+// colorCode returns the color part of codes: palette colors with \x03, or
+// \x04 as soon as one of the colors is a hex color.
+func (st style) colorCode() string {
+	fg, bg := st.fg, st.bg
+	switch {
+	case fg == "" && bg == "":
 		return ""
+	case !strings.HasPrefix(fg, "#") && !strings.HasPrefix(bg, "#"):
+		if fg == "" {
+			fg = strconv.Itoa(ircfmt.DefaultColor)
+		}
+		if bg == "" {
+			return ircfmt.Color + fg
+		}
+		return ircfmt.Color + fg + "," + bg
+	case fg == "":
+		// \x04 has no default foreground; use the nearest palette background
+		return fmt.Sprintf("%s%d,%02d", ircfmt.Color, ircfmt.DefaultColor, ircfmt.Nearest(hexValue(bg), ircfmt.DefaultColor))
+	case bg == "":
+		return ircfmt.HexColor + hexDigits(fg)
 	}
+	return ircfmt.HexColor + hexDigits(fg) + "," + hexDigits(bg)
 }
 
-func isNumericColor(s string) bool {
-	for _, ch := range s {
-		if (ch < '0' || ch > '9') && ch != ',' {
-			return false
-		}
-	}
-	return true
+// hexDigits returns a color code as RRGGBB.
+func hexDigits(code string) string {
+	return fmt.Sprintf("%06X", hexValue(code))
 }
 
-func normalizeNumeric(s string) string {
-	parts := strings.Split(s, ",")
-	for i, p := range parts {
-		if len(p) == 1 {
-			parts[i] = "0" + p
-		} else if len(p) == 2 {
-			parts[i] = p
-		} else if len(p) > 2 {
-			parts[i] = p[:2]
-		}
+func hexValue(code string) uint32 {
+	if v, ok := ircfmt.ParseHex(code); ok && strings.HasPrefix(code, "#") {
+		return v
 	}
-	return strings.Join(parts, ",")
+	n, _ := strconv.Atoi(code)
+	return ircfmt.RGB(n)
 }
 
-func applyGroups(s string, re *regexp.Regexp, groups []int, style string) string {
-	matches := re.FindAllStringSubmatchIndex(s, -1)
-	if len(matches) == 0 {
-		return s
+// with returns st with inner nested in it: attributes add up and inner's
+// colors replace st's.
+func (st style) with(inner style) style {
+	out := style{
+		bold:      st.bold || inner.bold,
+		italic:    st.italic || inner.italic,
+		underline: st.underline || inner.underline,
+		strike:    st.strike || inner.strike,
+		mono:      st.mono || inner.mono,
+		reverse:   st.reverse || inner.reverse,
+		fg:        st.fg,
+		bg:        st.bg,
 	}
-	// Build list of intervals [start,end) to color across all matches
-	type seg struct{ a, b int }
-	var segs []seg
-	for _, idx := range matches {
-		for _, g := range groups {
-			// idx layout: [m0s,m0e, g1s,g1e, g2s,g2e, ...]
-			pos := 2 * g
-			if pos+1 >= len(idx) {
-				continue
-			}
-			a, b := idx[pos], idx[pos+1]
-			if a >= 0 && b >= 0 && b > a {
-				segs = append(segs, seg{a: a, b: b})
-			}
-		}
+	if inner.fg != "" {
+		out.fg = inner.fg
 	}
-	if len(segs) == 0 {
-		return s
-	}
-	// sort by start, merge overlaps
-	sort.Slice(segs, func(i, j int) bool { return segs[i].a < segs[j].a })
-	merged := segs[:0]
-	for _, cur := range segs {
-		n := len(merged)
-		if n == 0 || cur.a > merged[n-1].b {
-			merged = append(merged, cur)
-		} else if cur.b > merged[n-1].b {
-			merged[n-1].b = cur.b
-		}
+	if inner.bg != "" {
+		out.bg = inner.bg
 	}
+	return out
+}
 
-	var bld strings.Builder
-	last := 0
-	for _, sg := range merged {
-		if sg.a > last {
-			bld.WriteString(s[last:sg.a])
-		}
-		bld.WriteString(style)
-		bld.WriteString(s[sg.a:sg.b])
-		bld.WriteString(ircReset)
-		last = sg.b
+func buildStyle(r config.HighlightRule) style {
+	st := style{
+		bold:      r.Bold,
+		italic:    r.Italic,
+		underline: r.Underline,
+		strike:    r.Strikethrough,
+		mono:      r.Monospace,
+		reverse:   r.Reverse,
 	}
-	if last < len(s) {
-		bld.WriteString(s[last:])
+	st.fg, st.bg, _ = strings.Cut(colorToCode(r.Color), ",")
+	if r.Background != "" {
+		st.bg, _, _ = strings.Cut(colorToCode(r.Background), ",")
 	}
-	return bld.String()
+	return st
+}
+
+func colorToCode(name string) string {
+	code, _ := config.ColorCode(name)
+	return code
 }
 
 func uniqueInts(in []int) []int {
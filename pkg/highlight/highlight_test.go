@@ -0,0 +1,156 @@
+package highlight
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/bitcanon/ircpush/pkg/config"
+)
+
+// TestExplain verifies the reported spans and the rules skipped by channel filters.
+func TestExplain(t *testing.T) {
+	hl := New(config.HighlightConfig{Rules: []config.HighlightRule{
+		{Kind: "regex", Pattern: "(", Color: "red"}, // invalid, never reported
+		{Kind: "regex", Pattern: `\b(?:\d{1,3}\.){3}\d{1,3}:(?P<port>\w+)`, Groups: []string{"port"}},
+		{Pattern: "down", Channels: []string{"#net*"}},
+		{Pattern: "drop", WholeLine: true, Channels: []string{"#security"}},
+	}})
+
+	// Setup test cases
+	tests := []struct {
+		name     string
+		channel  string
+		line     string
+		expected []Match
+	}{
+		{
+			name:    "GroupSpan",
+			channel: "#network",
+			line:    "10.0.0.1:443 down",
+			expected: []Match{
+				{Rule: 1, Spans: [][2]int{{9, 12}}},
+				{Rule: 2, Spans: [][2]int{{13, 17}}},
+			},
+		},
+		{
+			name:    "SkippedByChannel",
+			channel: "#server",
+			line:    "link down",
+			expected: []Match{
+				{Rule: 2, Skipped: true, Spans: [][2]int{{5, 9}}},
+			},
+		},
+		{
+			name:    "WholeLineAroundSpans",
+			channel: "#security",
+			line:    "drop 10.0.0.1:22",
+			expected: []Match{
+				{Rule: 1, Spans: [][2]int{{14, 16}}},
+				{Rule: 3, WholeLine: true, Spans: [][2]int{{0, 16}}},
+			},
+		},
+		{
+			name:    "DroppedByPriority",
+			channel: "#network",
+			line:    "10.0.0.1:443down",
+			expected: []Match{
+				{Rule: 1, Spans: [][2]int{{9, 16}}},
+			},
+		},
+		{
+			name:     "NoMatch",
+			channel:  "#network",
+			line:     "all good",
+			expected: nil,
+		},
+	}
+
+	// Run test cases
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := hl.Explain(test.channel, test.line)
+			if !reflect.DeepEqual(got, test.expected) {
+				t.Errorf("expected %+v, but got %+v", test.expected, got)
+			}
+		})
+	}
+}
+
+// TestApplyFor verifies that rules are matched against the plain text and
+// that overlaps are resolved by priority, with nested styles restored.
+func TestApplyFor(t *testing.T) {
+	ipv4 := config.HighlightRule{Kind: "regex", Pattern: `\b(?:\d{1,3}\.){3}\d{1,3}\b`, Color: "lightgreen"}
+	port := config.HighlightRule{Kind: "regex", Pattern: `\b(?:\d{1,3}\.){3}\d{1,3}:(?P<port>\d{1,5})\b`, Color: "white", Bold: true, Groups: []string{"port"}}
+	digits := config.HighlightRule{Kind: "regex", Pattern: `\d+`, Color: "cyan"}
+
+	// Setup test cases
+	tests := []struct {
+		name     string
+		rules    []config.HighlightRule
+		channel  string
+		line     string
+		expected string
+	}{
+		{
+			name:     "OrderOfIPv4RulesDoesNotMatter",
+			rules:    []config.HighlightRule{ipv4, port},
+			line:     "to 10.0.0.1:443",
+			expected: "to \x0309" + "10.0.0.1\x0F:\x02\x0300" + "443\x0F",
+		},
+		{
+			name:     "LaterRuleDoesNotMatchInsideCodes",
+			rules:    []config.HighlightRule{{Pattern: "down", Color: "red"}, digits},
+			line:     "eth0 down",
+			expected: "eth\x0310" + "0\x0F \x0304down\x0F",
+		},
+		{
+			name:     "FirstRuleWinsOverlap",
+			rules:    []config.HighlightRule{{Kind: "regex", Pattern: "ab", Color: "red"}, {Kind: "regex", Pattern: "bc", Color: "blue"}},
+			line:     "abc",
+			expected: "\x0304ab\x0Fc",
+		},
+		{
+			name:     "PriorityWinsOverlap",
+			rules:    []config.HighlightRule{{Kind: "regex", Pattern: "ab", Color: "red"}, {Kind: "regex", Pattern: "bc", Color: "blue", Priority: 1}},
+			line:     "abc",
+			expected: "a\x0302bc\x0F",
+		},
+		{
+			name:     "EnclosingLowerRuleIsRestored",
+			rules:    []config.HighlightRule{{Pattern: "critical", Color: "red", Bold: true, CaseInsensitive: true}, {Kind: "regex", Pattern: `\[[A-Z ]+\]`, Color: "yellow"}},
+			line:     "[CRITICAL ALERT]",
+			expected: "\x0308[\x0F\x02\x0304CRITICAL\x0F\x0308 ALERT]\x0F",
+		},
+		{
+			name: "WholeLineAroundSpans",
+			rules: []config.HighlightRule{
+				{Pattern: "drop", Color: "0,4", WholeLine: true, CaseInsensitive: true},
+				ipv4,
+			},
+			line:     "DROP from 10.0.0.1 now",
+			expected: "\x0300,04DROP from \x0F\x0309,04" + "10.0.0.1\x0F\x0300,04 now\x0F",
+		},
+		{
+			name:     "CommaAfterColor",
+			rules:    []config.HighlightRule{{Pattern: "x", Color: "red"}},
+			line:     "x,1",
+			expected: "\x0304x\x0F,1",
+		},
+		{
+			name:     "CommaInsideStyledSpan",
+			rules:    []config.HighlightRule{{Kind: "regex", Pattern: `a\b`, Color: "red"}, {Kind: "regex", Pattern: `,\d`, Color: "blue"}},
+			line:     "a,1",
+			expected: "\x0304a\x0F\x0302\x02\x02,1\x0F",
+		},
+	}
+
+	// Run test cases
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			hl := New(config.HighlightConfig{Rules: test.rules})
+			if got := hl.ApplyFor(test.channel, test.line); got != test.expected {
+				t.Errorf("expected %q, but got %q", test.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,287 @@
+/*
+MIT License
+
+Copyright (c) 2025 Mikael Schultz <mikael@conf-t.se>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package alertmanager
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/bitcanon/ircpush/pkg/config"
+)
+
+// Notification is the Alertmanager webhook payload (version "4").
+type Notification struct {
+	Version           string            `json:"version"`
+	GroupKey          string            `json:"groupKey"`
+	TruncatedAlerts   int               `json:"truncatedAlerts"`
+	Status            string            `json:"status"`
+	Receiver          string            `json:"receiver"`
+	GroupLabels       map[string]string `json:"groupLabels"`
+	CommonLabels      map[string]string `json:"commonLabels"`
+	CommonAnnotations map[string]string `json:"commonAnnotations"`
+	ExternalURL       string            `json:"externalURL"`
+	Alerts            []Alert           `json:"alerts"`
+}
+
+// Alert is a single alert inside a Notification.
+type Alert struct {
+	Status       string            `json:"status"`
+	Labels       map[string]string `json:"labels"`
+	Annotations  map[string]string `json:"annotations"`
+	StartsAt     time.Time         `json:"startsAt"`
+	EndsAt       time.Time         `json:"endsAt"`
+	GeneratorURL string            `json:"generatorURL"`
+	Fingerprint  string            `json:"fingerprint"`
+}
+
+const (
+	// DefaultTemplate renders one alert line.
+	DefaultTemplate = `[{{ .Status | upper }}] {{ .Labels.alertname }}` +
+		`{{ with .Labels.severity }} ({{ . }}){{ end }}` +
+		`{{ with .Labels.instance }} {{ . }}{{ end }}` +
+		`{{ with or .Annotations.summary .Annotations.description }}: {{ . }}{{ end }}`
+
+	// DefaultSummaryTemplate renders the summary line of a grouped notification.
+	// .Alerts holds only the alerts routed to the channel being rendered.
+	DefaultSummaryTemplate = `[{{ .Status | upper }}:{{ len .Alerts }}] {{ .GroupLabels | labels }}`
+)
+
+var funcs = template.FuncMap{
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	"join":  func(sep string, s []string) string { return strings.Join(s, sep) },
+	// labels renders a label set as "k=v k=v" sorted by key.
+	"labels": func(m map[string]string) string {
+		keys := make([]string, 0, len(m))
+		for k := range m {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		parts := make([]string, 0, len(keys))
+		for _, k := range keys {
+			parts = append(parts, k+"="+m[k])
+		}
+		return strings.Join(parts, " ")
+	},
+}
+
+// Renderer maps alerts to channels and renders them into IRC lines.
+type Renderer struct {
+	alertTmpl   *template.Template
+	summaryTmpl *template.Template
+	routes      []route
+	defaults    []string
+	maxLines    int
+}
+
+type route struct {
+	matchers []matcher
+	channels []string
+	cont     bool
+}
+
+type matcher struct {
+	label string
+	op    string // "=", "!=", "=~", "!~"
+	value string
+	re    *regexp.Regexp
+}
+
+// Output is one rendered line and the channels it goes to (empty = broadcast).
+type Output struct {
+	Channels []string
+	Line     string
+}
+
+// NewRenderer compiles templates and route matchers from the config.
+func NewRenderer(ac config.AlertmanagerConfig) (*Renderer, error) {
+	r := &Renderer{defaults: ac.Channels, maxLines: ac.MaxLines}
+	if r.maxLines <= 0 {
+		r.maxLines = 10
+	} else if r.maxLines < 2 {
+		r.maxLines = 2 // room for a summary and "... and N more"
+	}
+	var err error
+	if r.alertTmpl, err = parseTemplate("template", ac.Template, DefaultTemplate); err != nil {
+		return nil, err
+	}
+	if r.summaryTmpl, err = parseTemplate("summary_template", ac.SummaryTemplate, DefaultSummaryTemplate); err != nil {
+		return nil, err
+	}
+	for i, rc := range ac.Routes {
+		rt := route{channels: rc.Channels, cont: rc.Continue}
+		for _, s := range rc.Matchers {
+			m, err := parseMatcher(s)
+			if err != nil {
+				return nil, fmt.Errorf("alertmanager: routes[%d]: %w", i, err)
+			}
+			rt.matchers = append(rt.matchers, m)
+		}
+		r.routes = append(r.routes, rt)
+	}
+	return r, nil
+}
+
+func parseTemplate(name, text, def string) (*template.Template, error) {
+	if strings.TrimSpace(text) == "" {
+		text = def
+	}
+	t, err := template.New(name).Funcs(funcs).Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("alertmanager: %s: %w", name, err)
+	}
+	return t, nil
+}
+
+// parseMatcher parses "label=value", "label!=value", "label=~re" or "label!~re".
+func parseMatcher(s string) (matcher, error) {
+	s = strings.TrimSpace(s)
+	for _, op := range []string{"=~", "!~", "!=", "="} {
+		label, value, ok := strings.Cut(s, op)
+		if !ok {
+			continue
+		}
+		m := matcher{label: strings.TrimSpace(label), op: op, value: strings.Trim(strings.TrimSpace(value), `"`)}
+		if m.label == "" {
+			return matcher{}, fmt.Errorf("matcher %q: empty label name", s)
+		}
+		if op == "=~" || op == "!~" {
+			// Anchored like Alertmanager regex matchers
+			re, err := regexp.Compile("^(?:" + m.value + ")$")
+			if err != nil {
+				return matcher{}, fmt.Errorf("matcher %q: %w", s, err)
+			}
+			m.re = re
+		}
+		return m, nil
+	}
+	return matcher{}, fmt.Errorf("matcher %q: expected label=value, label!=value, label=~regex or label!~regex", s)
+}
+
+func (m matcher) matches(labels map[string]string) bool {
+	v := labels[m.label]
+	switch m.op {
+	case "=":
+		return v == m.value
+	case "!=":
+		return v != m.value
+	case "=~":
+		return m.re.MatchString(v)
+	default: // "!~"
+		return !m.re.MatchString(v)
+	}
+}
+
+// channelsFor returns the channels an alert is routed to, or nil to broadcast.
+func (r *Renderer) channelsFor(a Alert) []string {
+	var out []string
+	matched := false
+	for _, rt := range r.routes {
+		ok := true
+		for _, m := range rt.matchers {
+			if !m.matches(a.Labels) {
+				ok = false
+				break
+			}
+		}
+		if !ok {
+			continue
+		}
+		matched = true
+		out = append(out, rt.channels...)
+		if !rt.cont {
+			break
+		}
+	}
+	if !matched {
+		return r.defaults
+	}
+	return out
+}
+
+// Render groups the notification's alerts by target channels and renders
+// them. A group with several alerts gets a summary line first; the number of
+// lines per group is capped at max_lines, with a final "... and N more" line.
+func (r *Renderer) Render(n Notification) ([]Output, error) {
+	type group struct {
+		channels []string
+		alerts   []Alert
+	}
+	var groups []*group
+	byKey := map[string]*group{}
+	for _, a := range n.Alerts {
+		chs := r.channelsFor(a)
+		key := strings.ToLower(strings.Join(chs, ","))
+		g, ok := byKey[key]
+		if !ok {
+			g = &group{channels: chs}
+			byKey[key] = g
+			groups = append(groups, g)
+		}
+		g.alerts = append(g.alerts, a)
+	}
+
+	var out []Output
+	for _, g := range groups {
+		var lines []string
+		if len(g.alerts) > 1 {
+			sub := n
+			sub.Alerts = g.alerts
+			line, err := execute(r.summaryTmpl, sub)
+			if err != nil {
+				return nil, err
+			}
+			lines = append(lines, line)
+		}
+		for i, a := range g.alerts {
+			if len(lines) >= r.maxLines-1 && i < len(g.alerts)-1 {
+				lines = append(lines, fmt.Sprintf("... and %d more", len(g.alerts)-i))
+				break
+			}
+			line, err := execute(r.alertTmpl, a)
+			if err != nil {
+				return nil, err
+			}
+			lines = append(lines, line)
+		}
+		for _, line := range lines {
+			out = append(out, Output{Channels: g.channels, Line: line})
+		}
+	}
+	return out, nil
+}
+
+// execute runs t and flattens the result to a single IRC line.
+func execute(t *template.Template, data any) (string, error) {
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("alertmanager: %s: %w", t.Name(), err)
+	}
+	return strings.TrimSpace(strings.NewReplacer("\r", " ", "\n", " ").Replace(buf.String())), nil
+}
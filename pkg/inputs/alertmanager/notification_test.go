@@ -0,0 +1,118 @@
+package alertmanager
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/bitcanon/ircpush/pkg/config"
+)
+
+// payload is a trimmed Alertmanager v4 webhook with three alerts.
+const payload = `{
+  "version": "4",
+  "groupKey": "{}:{alertname=\"DiskFull\"}",
+  "status": "firing",
+  "receiver": "ircpush",
+  "groupLabels": {"alertname": "DiskFull"},
+  "commonLabels": {"alertname": "DiskFull"},
+  "alerts": [
+    {"status": "firing", "labels": {"alertname": "DiskFull", "severity": "critical", "team": "ops", "instance": "db1"},
+     "annotations": {"summary": "/var is 97% full"}},
+    {"status": "firing", "labels": {"alertname": "DiskFull", "severity": "warning", "team": "ops", "instance": "db2"},
+     "annotations": {"description": "/var is 85% full"}},
+    {"status": "resolved", "labels": {"alertname": "DiskFull", "severity": "critical", "team": "web", "instance": "web1"}}
+  ]
+}`
+
+func decode(t *testing.T) Notification {
+	t.Helper()
+	var n Notification
+	if err := json.Unmarshal([]byte(payload), &n); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	return n
+}
+
+// TestRenderRoutesAndSummary verifies label routing, default templates and the group summary line.
+func TestRenderRoutesAndSummary(t *testing.T) {
+	r, err := NewRenderer(config.AlertmanagerConfig{
+		Channels: []string{"#alerts"},
+		Routes: []config.AlertRoute{
+			{Matchers: []string{"team=ops"}, Channels: []string{"#ops"}},
+			{Matchers: []string{`team=~"web|api"`, "severity!=info"}, Channels: []string{"#web"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewRenderer: %v", err)
+	}
+	out, err := r.Render(decode(t))
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	expected := []Output{
+		{Channels: []string{"#ops"}, Line: "[FIRING:2] alertname=DiskFull"},
+		{Channels: []string{"#ops"}, Line: "[FIRING] DiskFull (critical) db1: /var is 97% full"},
+		{Channels: []string{"#ops"}, Line: "[FIRING] DiskFull (warning) db2: /var is 85% full"},
+		{Channels: []string{"#web"}, Line: "[RESOLVED] DiskFull (critical) web1"},
+	}
+	if !reflect.DeepEqual(out, expected) {
+		t.Errorf("expected %#v, but got %#v", expected, out)
+	}
+}
+
+// TestRenderContinueAndDefault verifies continue semantics and the default channels.
+func TestRenderContinueAndDefault(t *testing.T) {
+	r, err := NewRenderer(config.AlertmanagerConfig{
+		Template: "{{ .Labels.instance }} {{ .Status }}",
+		Routes: []config.AlertRoute{
+			{Matchers: []string{"severity=critical"}, Channels: []string{"#pager"}, Continue: true},
+			{Matchers: []string{"team=ops"}, Channels: []string{"#ops"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewRenderer: %v", err)
+	}
+	n := decode(t)
+	if got := r.channelsFor(n.Alerts[0]); !reflect.DeepEqual(got, []string{"#pager", "#ops"}) {
+		t.Errorf("critical ops alert: expected [#pager #ops], got %v", got)
+	}
+	if got := r.channelsFor(n.Alerts[1]); !reflect.DeepEqual(got, []string{"#ops"}) {
+		t.Errorf("warning ops alert: expected [#ops], got %v", got)
+	}
+	n.Alerts[2].Labels["severity"] = "info"
+	if got := r.channelsFor(n.Alerts[2]); got != nil {
+		t.Errorf("unmatched alert: expected broadcast (nil), got %v", got)
+	}
+}
+
+// TestRenderMaxLines verifies that grouped output is capped.
+func TestRenderMaxLines(t *testing.T) {
+	r, err := NewRenderer(config.AlertmanagerConfig{MaxLines: 3, Template: "{{ .Labels.instance }}"})
+	if err != nil {
+		t.Fatalf("NewRenderer: %v", err)
+	}
+	n := decode(t)
+	n.Alerts = append(n.Alerts, n.Alerts...)
+	out, err := r.Render(n)
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	var lines []string
+	for _, o := range out {
+		lines = append(lines, o.Line)
+	}
+	expected := []string{"[FIRING:6] alertname=DiskFull", "db1", "... and 5 more"}
+	if !reflect.DeepEqual(lines, expected) {
+		t.Errorf("expected %#v, but got %#v", expected, lines)
+	}
+}
+
+// TestParseMatcherErrors verifies that invalid matchers are rejected.
+func TestParseMatcherErrors(t *testing.T) {
+	for _, s := range []string{"severity", "=critical", "team=~(ops"} {
+		if _, err := parseMatcher(s); err == nil {
+			t.Errorf("parseMatcher(%q): expected an error but got nil", s)
+		}
+	}
+}
@@ -0,0 +1,235 @@
+/*
+MIT License
+
+Copyright (c) 2025 Mikael Schultz <mikael@conf-t.se>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package alertmanager
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/bitcanon/ircpush/pkg/inputs"
+	"github.com/bitcanon/ircpush/pkg/metrics"
+	"github.com/bitcanon/ircpush/pkg/pipeline"
+)
+
+// Server receives Alertmanager webhook notifications and forwards them to IRC.
+type Server struct {
+	ListenAddr string
+	Path       string // default "/alertmanager"
+
+	// Pipeline highlights and forwards rendered lines to IRC.
+	Pipeline *pipeline.Pipeline
+
+	// Renderer maps alerts to channels and formats them.
+	Renderer *Renderer
+
+	// Optional logging sink; if nil, logs go to stderr.
+	Logger Logger
+
+	// Control whether to log each rendered line (default false).
+	LogMessages bool
+
+	// Request body limit (default 1 MiB; notifications can carry many alerts).
+	MaxBodyBytes int
+
+	// Replaces is the running server this one takes over from on reload
+	// (optional). Start binds first, reusing its socket when the address is
+	// unchanged, and then stops it; its open connections are served until
+	// they close.
+	Replaces *Server
+
+	sock *inputs.Listener
+	srv  *http.Server
+	wg   sync.WaitGroup
+	once sync.Once
+}
+
+// Logger is a minimal logger interface.
+type Logger interface {
+	Printf(format string, v ...any)
+}
+
+func (s *Server) logf(format string, v ...any) {
+	if s.Logger != nil {
+		s.Logger.Printf(format, v...)
+		return
+	}
+	fmt.Fprintf(os.Stderr, format+"\n", v...)
+}
+
+// Start begins listening and serving requests until ctx is done or an error occurs.
+// It returns once the listener is up and the HTTP server has been started.
+// Use Stop() to shut it down.
+func (s *Server) Start(ctx context.Context) error {
+	if s.ListenAddr == "" {
+		return fmt.Errorf("alertmanager server: ListenAddr is empty")
+	}
+	if s.Pipeline == nil {
+		return fmt.Errorf("alertmanager server: Pipeline is nil")
+	}
+	if s.Renderer == nil {
+		return fmt.Errorf("alertmanager server: Renderer is nil")
+	}
+	if s.Path == "" {
+		s.Path = "/alertmanager"
+	}
+	if s.MaxBodyBytes <= 0 {
+		s.MaxBodyBytes = 1 << 20
+	}
+	var prev *inputs.Listener
+	if s.Replaces != nil {
+		prev = s.Replaces.sock
+	}
+	ln, err := inputs.Listen("tcp", s.ListenAddr, prev)
+	if err != nil {
+		return err
+	}
+	s.sock = ln
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(s.Path, s.handleWebhook)
+	s.srv = &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       30 * time.Second,
+	}
+	s.logf("alertmanager: listening on %s%s", s.ListenAddr, s.Path)
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		if err := s.srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) && !errors.Is(err, net.ErrClosed) {
+			s.logf("alertmanager: serve error: %v", err)
+		}
+		s.logf("alertmanager: listener closed")
+	}()
+
+	s.retire()
+
+	// Shut down when ctx is done
+	go func() {
+		<-ctx.Done()
+		_ = s.Stop()
+	}()
+
+	return nil
+}
+
+// retire stops the server this one replaces; Stop waits for it.
+func (s *Server) retire() {
+	old := s.Replaces
+	if old == nil {
+		return
+	}
+	s.Replaces = nil
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		_ = old.Stop()
+	}()
+}
+
+// Stop shuts down the server, giving in-flight requests a short grace period.
+func (s *Server) Stop() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	return s.Shutdown(ctx)
+}
+
+// Shutdown closes the listener and waits for in-flight requests to finish.
+// When ctx is done first, it closes the remaining connections.
+func (s *Server) Shutdown(ctx context.Context) error {
+	var err error
+	s.once.Do(func() {
+		if s.srv != nil {
+			if err = s.srv.Shutdown(ctx); err != nil {
+				_ = s.srv.Close()
+			}
+		}
+	})
+	s.wg.Wait()
+	return err
+}
+
+func (s *Server) handleWebhook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "only POST is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	ra := r.RemoteAddr
+	if !s.Pipeline.AllowSource(ra) {
+		s.logf("alertmanager: %s rejected: source not allowed by acl", ra)
+		metrics.LinesDropped.Inc("alertmanager", "acl")
+		http.Error(w, "source not allowed", http.StatusForbidden)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, int64(s.MaxBodyBytes)))
+	if err != nil {
+		var mbe *http.MaxBytesError
+		if errors.As(err, &mbe) {
+			s.logf("alertmanager: %s body exceeded %d bytes, dropping", ra, s.MaxBodyBytes)
+			http.Error(w, "body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var n Notification
+	if err := json.Unmarshal(body, &n); err != nil {
+		s.logf("alertmanager: %s invalid payload: %v", ra, err)
+		http.Error(w, "invalid JSON: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if n.Version != "" && n.Version != "4" {
+		s.logf("alertmanager: %s sent webhook version %q (expected 4), trying anyway", ra, n.Version)
+	}
+
+	outs, err := s.Renderer.Render(n)
+	if err != nil {
+		s.logf("alertmanager: %s render: %v", ra, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	for _, o := range outs {
+		if s.LogMessages {
+			s.logf("alertmanager: %s -> targets %v: %q", ra, o.Channels, o.Line)
+		}
+		metrics.LinesReceived.Inc("alertmanager", metrics.Source(ra))
+		s.Pipeline.Submit(pipeline.Message{Input: "alertmanager", Source: ra, Targets: o.Channels, Text: o.Line})
+	}
+	if n.TruncatedAlerts > 0 {
+		s.logf("alertmanager: %s notification %s had %d truncated alerts", ra, n.GroupKey, n.TruncatedAlerts)
+	}
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,158 @@
+/*
+MIT License
+
+Copyright (c) 2025 Mikael Schultz <mikael@conf-t.se>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package inputs
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Listener is a net.Listener that a replacement server can take over on
+// reload, so the socket stays bound and no connection is refused while the
+// input is restarted with new settings.
+type Listener struct {
+	net.Listener
+	addr     string
+	released atomic.Bool
+	stopped  chan struct{}
+	once     sync.Once
+}
+
+// Listen binds addr. When prev is bound to the same address its socket is
+// taken over instead: prev's Accept fails with net.ErrClosed from then on,
+// as if it was closed, and prev.Close becomes a no-op. prev may be nil.
+func Listen(network, addr string, prev *Listener) (*Listener, error) {
+	if prev != nil && prev.addr == addr {
+		d, ok := prev.Listener.(interface{ SetDeadline(time.Time) error })
+		if !ok {
+			return nil, fmt.Errorf("listen %s: socket cannot be handed over", addr)
+		}
+		// Wake up prev's accept loop and wait until it has stopped using the socket
+		prev.released.Store(true)
+		_ = d.SetDeadline(time.Now())
+		select {
+		case <-prev.stopped:
+		case <-time.After(time.Second):
+		}
+		_ = d.SetDeadline(time.Time{})
+		return newListener(prev.Listener, addr), nil
+	}
+	ln, err := net.Listen(network, addr)
+	if err != nil {
+		return nil, fmt.Errorf("listen %s: %w", addr, err)
+	}
+	return newListener(ln, addr), nil
+}
+
+func newListener(ln net.Listener, addr string) *Listener {
+	return &Listener{Listener: ln, addr: addr, stopped: make(chan struct{})}
+}
+
+// Accept waits for the next connection.
+func (l *Listener) Accept() (net.Conn, error) {
+	if l.released.Load() {
+		l.stop()
+		return nil, net.ErrClosed
+	}
+	c, err := l.Listener.Accept()
+	if err != nil && l.released.Load() {
+		l.stop()
+		return nil, net.ErrClosed
+	}
+	return c, err
+}
+
+// Close closes the socket unless it has been handed over.
+func (l *Listener) Close() error {
+	if l.released.Load() {
+		return nil
+	}
+	return l.Listener.Close()
+}
+
+func (l *Listener) stop() {
+	l.once.Do(func() { close(l.stopped) })
+}
+
+// PacketConn is the datagram counterpart of Listener.
+type PacketConn struct {
+	net.PacketConn
+	addr     string
+	released atomic.Bool
+	stopped  chan struct{}
+	once     sync.Once
+}
+
+// ListenPacket binds addr, or takes over prev's socket when it is bound to
+// the same address (see Listen). prev may be nil.
+func ListenPacket(network, addr string, prev *PacketConn) (*PacketConn, error) {
+	if prev != nil && prev.addr == addr {
+		prev.released.Store(true)
+		_ = prev.PacketConn.SetReadDeadline(time.Now())
+		select {
+		case <-prev.stopped:
+		case <-time.After(time.Second):
+		}
+		_ = prev.PacketConn.SetReadDeadline(time.Time{})
+		return newPacketConn(prev.PacketConn, addr), nil
+	}
+	pc, err := net.ListenPacket(network, addr)
+	if err != nil {
+		return nil, fmt.Errorf("listen %s %s: %w", network, addr, err)
+	}
+	return newPacketConn(pc, addr), nil
+}
+
+func newPacketConn(pc net.PacketConn, addr string) *PacketConn {
+	return &PacketConn{PacketConn: pc, addr: addr, stopped: make(chan struct{})}
+}
+
+// ReadFrom reads the next datagram.
+func (p *PacketConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	if p.released.Load() {
+		p.stop()
+		return 0, nil, net.ErrClosed
+	}
+	n, addr, err := p.PacketConn.ReadFrom(b)
+	if err != nil && p.released.Load() {
+		p.stop()
+		return 0, nil, net.ErrClosed
+	}
+	return n, addr, err
+}
+
+// Close closes the socket unless it has been handed over.
+func (p *PacketConn) Close() error {
+	if p.released.Load() {
+		return nil
+	}
+	return p.PacketConn.Close()
+}
+
+func (p *PacketConn) stop() {
+	p.once.Do(func() { close(p.stopped) })
+}
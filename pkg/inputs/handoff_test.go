@@ -0,0 +1,116 @@
+package inputs
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+// TestListenTakeover verifies that a listener on the same address is taken
+// over without closing the socket, and that bind errors leave prev running.
+func TestListenTakeover(t *testing.T) {
+	old, err := Listen("tcp", "127.0.0.1:0", nil)
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	addr := old.Addr().String()
+	old.addr = addr // as if configured with the resolved address
+
+	oldDone := make(chan error, 1)
+	go func() {
+		for {
+			c, err := old.Accept()
+			if err != nil {
+				oldDone <- err
+				return
+			}
+			c.Close()
+		}
+	}()
+
+	// A different address that cannot be bound leaves the old listener alone
+	if _, err := Listen("tcp", "192.0.2.1:1", old); err == nil {
+		t.Fatalf("expected a bind error")
+	}
+	if c, err := net.Dial("tcp", addr); err != nil {
+		t.Fatalf("old listener stopped after a failed bind: %v", err)
+	} else {
+		c.Close()
+	}
+
+	next, err := Listen("tcp", addr, old)
+	if err != nil {
+		t.Fatalf("takeover: %v", err)
+	}
+	defer next.Close()
+	select {
+	case err := <-oldDone:
+		if !errors.Is(err, net.ErrClosed) {
+			t.Errorf("expected net.ErrClosed for the old accept loop, but got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("old accept loop did not stop")
+	}
+	if err := old.Close(); err != nil {
+		t.Errorf("Close after takeover: %v", err)
+	}
+
+	// The socket is still bound and served by the new listener
+	go func() {
+		c, err := net.Dial("tcp", addr)
+		if err == nil {
+			c.Close()
+		}
+	}()
+	_ = next.Listener.(*net.TCPListener).SetDeadline(time.Now().Add(2 * time.Second))
+	c, err := next.Accept()
+	if err != nil {
+		t.Fatalf("Accept on the new listener: %v", err)
+	}
+	c.Close()
+}
+
+// TestListenPacketTakeover verifies the datagram socket handover.
+func TestListenPacketTakeover(t *testing.T) {
+	old, err := ListenPacket("udp", "127.0.0.1:0", nil)
+	if err != nil {
+		t.Fatalf("ListenPacket: %v", err)
+	}
+	addr := old.LocalAddr().String()
+	old.addr = addr
+
+	oldDone := make(chan error, 1)
+	go func() {
+		buf := make([]byte, 64)
+		for {
+			if _, _, err := old.ReadFrom(buf); err != nil {
+				oldDone <- err
+				return
+			}
+		}
+	}()
+
+	next, err := ListenPacket("udp", addr, old)
+	if err != nil {
+		t.Fatalf("takeover: %v", err)
+	}
+	defer next.Close()
+	if err := <-oldDone; !errors.Is(err, net.ErrClosed) {
+		t.Errorf("expected net.ErrClosed for the old read loop, but got %v", err)
+	}
+	_ = old.Close()
+
+	c, err := net.Dial("udp", addr)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer c.Close()
+	_, _ = c.Write([]byte("hello"))
+	_ = next.SetReadDeadline(time.Now().Add(2 * time.Second))
+	buf := make([]byte, 64)
+	n, _, err := next.ReadFrom(buf)
+	if err != nil || string(buf[:n]) != "hello" {
+		t.Fatalf("expected %q on the new socket, but got %q, %v", "hello", buf[:n], err)
+	}
+}
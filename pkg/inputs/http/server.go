@@ -0,0 +1,385 @@
+/*
+MIT License
+
+Copyright (c) 2025 Mikael Schultz <mikael@conf-t.se>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	nethttp "net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bitcanon/ircpush/pkg/inputs"
+	"github.com/bitcanon/ircpush/pkg/metrics"
+	"github.com/bitcanon/ircpush/pkg/pipeline"
+)
+
+// Server receives messages over HTTP (POST /send) and forwards them to IRC.
+type Server struct {
+	ListenAddr string
+
+	// Pipeline highlights and forwards received messages to IRC.
+	Pipeline *pipeline.Pipeline
+
+	// Channels lists the configured IRC channels; requested targets outside
+	// this list are rejected, and broadcasts go to all of them. When empty,
+	// the pipeline's channels are used, which follow config reloads.
+	Channels []string
+
+	// Optional logging sink; if nil, logs go to stderr.
+	Logger Logger
+
+	// Control whether to log each received message (default false).
+	LogMessages bool
+
+	// Request body limit, like tcp.max_line_bytes for a single line.
+	MaxBodyBytes int
+
+	// Replaces is the running server this one takes over from on reload
+	// (optional). Start binds first, reusing its socket when the address is
+	// unchanged, and then stops it; its open connections are served until
+	// they close.
+	Replaces *Server
+
+	sock *inputs.Listener
+	srv  *nethttp.Server
+	wg   sync.WaitGroup
+	once sync.Once
+}
+
+// Logger is a minimal logger interface.
+type Logger interface {
+	Printf(format string, v ...any)
+}
+
+// sendRequest is the JSON body accepted by POST /send.
+type sendRequest struct {
+	Channels []string `json:"channels"`
+	Message  string   `json:"message"`
+}
+
+// sendResponse tells the caller where the message went.
+type sendResponse struct {
+	Accepted []string `json:"accepted"`
+	Rejected []string `json:"rejected"`
+	Lines    int      `json:"lines"`
+	Error    string   `json:"error,omitempty"`
+}
+
+func (s *Server) logf(format string, v ...any) {
+	if s.Logger != nil {
+		s.Logger.Printf(format, v...)
+		return
+	}
+	fmt.Fprintf(os.Stderr, format+"\n", v...)
+}
+
+// Start begins listening and serving requests until ctx is done or an error occurs.
+// It returns once the listener is up and the HTTP server has been started.
+// Use Stop() to shut it down.
+func (s *Server) Start(ctx context.Context) error {
+	if s.ListenAddr == "" {
+		return fmt.Errorf("http server: ListenAddr is empty")
+	}
+	if s.Pipeline == nil {
+		return fmt.Errorf("http server: Pipeline is nil")
+	}
+	if s.MaxBodyBytes <= 0 {
+		s.MaxBodyBytes = 64 * 1024
+	}
+	// Listen first so bind errors are reported to the caller
+	var prev *inputs.Listener
+	if s.Replaces != nil {
+		prev = s.Replaces.sock
+	}
+	ln, err := inputs.Listen("tcp", s.ListenAddr, prev)
+	if err != nil {
+		return err
+	}
+	s.sock = ln
+
+	mux := nethttp.NewServeMux()
+	mux.HandleFunc("/send", s.handleSend)
+	s.srv = &nethttp.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       30 * time.Second,
+	}
+	s.logf("http: listening on %s", s.ListenAddr)
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		if err := s.srv.Serve(ln); err != nil && !errors.Is(err, nethttp.ErrServerClosed) && !errors.Is(err, net.ErrClosed) {
+			s.logf("http: serve error: %v", err)
+		}
+		s.logf("http: listener closed")
+	}()
+
+	s.retire()
+
+	// Shut down when ctx is done
+	go func() {
+		<-ctx.Done()
+		_ = s.Stop()
+	}()
+
+	return nil
+}
+
+// retire stops the server this one replaces; Stop waits for it.
+func (s *Server) retire() {
+	old := s.Replaces
+	if old == nil {
+		return
+	}
+	s.Replaces = nil
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		_ = old.Stop()
+	}()
+}
+
+// Stop shuts down the server, giving in-flight requests a short grace period.
+func (s *Server) Stop() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	return s.Shutdown(ctx)
+}
+
+// Shutdown closes the listener and waits for in-flight requests to finish.
+// When ctx is done first, it closes the remaining connections.
+func (s *Server) Shutdown(ctx context.Context) error {
+	var err error
+	s.once.Do(func() {
+		if s.srv != nil {
+			if err = s.srv.Shutdown(ctx); err != nil {
+				_ = s.srv.Close()
+			}
+		}
+	})
+	s.wg.Wait()
+	return err
+}
+
+// handleSend accepts JSON ({"channels":[...],"message":"..."}) or plain-text bodies.
+// Plain text may use the TCP "#chan msg" prefix per line or ?channels=#a,#b.
+func (s *Server) handleSend(w nethttp.ResponseWriter, r *nethttp.Request) {
+	if r.Method != nethttp.MethodPost {
+		w.Header().Set("Allow", nethttp.MethodPost)
+		writeJSON(w, nethttp.StatusMethodNotAllowed, sendResponse{Error: "only POST is allowed"})
+		return
+	}
+	ra := r.RemoteAddr
+	if !s.Pipeline.AllowSource(ra) {
+		s.logf("http: %s rejected: source not allowed by acl", ra)
+		metrics.LinesDropped.Inc("http", "acl")
+		writeJSON(w, nethttp.StatusForbidden, sendResponse{Error: "source not allowed"})
+		return
+	}
+
+	body, err := io.ReadAll(nethttp.MaxBytesReader(w, r.Body, int64(s.MaxBodyBytes)))
+	if err != nil {
+		var mbe *nethttp.MaxBytesError
+		if errors.As(err, &mbe) {
+			s.logf("http: %s body exceeded max_body_bytes=%d, dropping", ra, s.MaxBodyBytes)
+			metrics.LinesDropped.Inc("http", "too_long")
+			writeJSON(w, nethttp.StatusRequestEntityTooLarge,
+				sendResponse{Error: fmt.Sprintf("body exceeds %d bytes", s.MaxBodyBytes)})
+			return
+		}
+		writeJSON(w, nethttp.StatusBadRequest, sendResponse{Error: err.Error()})
+		return
+	}
+
+	reqs, err := parseBody(r.Header.Get("Content-Type"), r.URL.Query().Get("channels"), body, s.Pipeline.Networks)
+	if err != nil {
+		writeJSON(w, nethttp.StatusBadRequest, sendResponse{Error: err.Error()})
+		return
+	}
+
+	resp := sendResponse{Accepted: []string{}, Rejected: []string{}}
+	seen := map[string]struct{}{}
+	note := func(list *[]string, ch string) {
+		if _, dup := seen[strings.ToLower(ch)]; !dup {
+			seen[strings.ToLower(ch)] = struct{}{}
+			*list = append(*list, ch)
+		}
+	}
+	channels := s.channels()
+	if len(channels) == 0 {
+		channels = s.Pipeline.ConfiguredChannels()
+	}
+	for _, req := range reqs {
+		metrics.LinesReceived.Inc("http", metrics.Source(ra))
+		var targets []string
+		if len(req.Channels) == 0 {
+			// Broadcast: to our channels when set, else routed by the pipeline
+			targets = s.channels()
+		} else {
+			requested := make([]string, len(req.Channels))
+			for i, ch := range req.Channels {
+				requested[i] = s.Pipeline.Canonical(ch)
+			}
+			var bad []string
+			targets, bad = filterTargets(requested, channels)
+			for _, ch := range bad {
+				s.logf("http: %s rejected target %s (not in irc.channels)", ra, ch)
+				note(&resp.Rejected, ch)
+			}
+			allowed := targets[:0]
+			for _, ch := range targets {
+				if !s.Pipeline.AllowChannel(ra, ch) {
+					s.logf("http: %s rejected target %s (not allowed by acl)", ra, ch)
+					note(&resp.Rejected, ch)
+					continue
+				}
+				allowed = append(allowed, ch)
+			}
+			targets = allowed
+			if len(targets) == 0 {
+				metrics.LinesDropped.Inc("http", "channel_not_allowed")
+				continue // nothing left to send to
+			}
+		}
+		if s.LogMessages {
+			s.logf("http: %s -> targets %v: %q", ra, targets, req.Message)
+		}
+		// Muted channels and repeats suppressed by dedup are not accepted
+		sent := s.Pipeline.Submit(pipeline.Message{Input: "http", Source: ra, Targets: targets, Text: req.Message})
+		for _, ch := range sent {
+			note(&resp.Accepted, pipeline.ChannelName(ch))
+		}
+		if len(sent) > 0 {
+			resp.Lines++
+		}
+	}
+
+	status := nethttp.StatusOK
+	if resp.Lines == 0 {
+		status = nethttp.StatusUnprocessableEntity
+		resp.Error = "no message was sent"
+	}
+	writeJSON(w, status, resp)
+}
+
+// channels returns s.Channels as the pipeline names them.
+func (s *Server) channels() []string {
+	var out []string
+	for _, ch := range s.Channels {
+		out = append(out, s.Pipeline.Canonical(ch))
+	}
+	return out
+}
+
+// parseBody turns a request body into one send request per message line.
+// queryChannels is the comma-separated ?channels= value used for plain text,
+// networks the network names a leading channel list may use.
+func parseBody(contentType, queryChannels string, body []byte, networks []string) ([]sendRequest, error) {
+	mt, _, _ := mime.ParseMediaType(contentType)
+	var out []sendRequest
+
+	if mt == "application/json" {
+		var req sendRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			return nil, fmt.Errorf("invalid JSON: %w", err)
+		}
+		for _, line := range splitLines(req.Message) {
+			out = append(out, sendRequest{Channels: req.Channels, Message: line})
+		}
+	} else {
+		var defaults []string
+		for _, ch := range strings.Split(queryChannels, ",") {
+			if ch = strings.TrimSpace(ch); ch != "" {
+				defaults = append(defaults, ch)
+			}
+		}
+		for _, line := range splitLines(string(body)) {
+			targets, msg := inputs.ParseTargets(line, networks)
+			if len(targets) == 0 {
+				targets = defaults
+			}
+			if msg == "" {
+				continue
+			}
+			out = append(out, sendRequest{Channels: targets, Message: msg})
+		}
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("message is empty")
+	}
+	return out, nil
+}
+
+// splitLines splits s into non-empty lines (IRC messages cannot contain newlines).
+func splitLines(s string) []string {
+	var out []string
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.TrimRight(line, "\r"); strings.TrimSpace(line) != "" {
+			out = append(out, line)
+		}
+	}
+	return out
+}
+
+// filterTargets splits requested channels into configured (ok) and unknown
+// (bad) ones. Like the pipeline, it accepts nothing when none are configured.
+func filterTargets(requested, configured []string) (ok, bad []string) {
+	allowed := make([]string, len(configured))
+	for i, ch := range configured {
+		allowed[i] = pipeline.ChannelName(ch)
+	}
+	seen := map[string]struct{}{}
+	for _, ch := range requested {
+		ch = pipeline.ChannelName(ch)
+		if ch == "" {
+			continue
+		}
+		lc := strings.ToLower(ch)
+		if _, dup := seen[lc]; dup {
+			continue
+		}
+		seen[lc] = struct{}{}
+		if pipeline.Listed(allowed, ch) {
+			ok = append(ok, ch)
+		} else {
+			bad = append(bad, ch)
+		}
+	}
+	return ok, bad
+}
+
+func writeJSON(w nethttp.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,182 @@
+package http
+
+import (
+	"encoding/json"
+	nethttp "net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bitcanon/ircpush/pkg/pipeline"
+)
+
+// TestParseBody tests the parseBody function with JSON and plain-text bodies.
+func TestParseBody(t *testing.T) {
+	// Setup test cases
+	tests := []struct {
+		name        string
+		contentType string
+		query       string
+		body        string
+		expected    []sendRequest
+		expectedErr bool
+	}{
+		{
+			name:        "JSONWithChannels",
+			contentType: "application/json",
+			body:        `{"channels":["#ops","deploy"],"message":"build 42 passed"}`,
+			expected:    []sendRequest{{Channels: []string{"#ops", "deploy"}, Message: "build 42 passed"}},
+		},
+		{
+			name:        "JSONMultiLineBroadcast",
+			contentType: "application/json; charset=utf-8",
+			body:        `{"message":"line one\r\n\nline two"}`,
+			expected:    []sendRequest{{Message: "line one"}, {Message: "line two"}},
+		},
+		{
+			name:        "JSONInvalid",
+			contentType: "application/json",
+			body:        `{"message":`,
+			expectedErr: true,
+		},
+		{
+			name:        "JSONEmptyMessage",
+			contentType: "application/json",
+			body:        `{"channels":["#ops"],"message":"  "}`,
+			expectedErr: true,
+		},
+		{
+			name:        "PlainWithPrefixAndQueryDefault",
+			contentType: "text/plain",
+			query:       "#ops, #ci",
+			body:        "#security intrusion detected\nall good\n",
+			expected: []sendRequest{
+				{Channels: []string{"#security"}, Message: "intrusion detected"},
+				{Channels: []string{"#ops", "#ci"}, Message: "all good"},
+			},
+		},
+		{
+			name:        "PlainWithNetworkPrefix",
+			contentType: "text/plain",
+			body:        "libera/#ops,#ci deploy done\napi/v2 is live\n",
+			expected: []sendRequest{
+				{Channels: []string{"libera/#ops", "#ci"}, Message: "deploy done"},
+				{Message: "api/v2 is live"},
+			},
+		},
+		{
+			name:     "PlainWithoutContentType",
+			body:     "hello",
+			expected: []sendRequest{{Message: "hello"}},
+		},
+	}
+	// Run test cases
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			out, err := parseBody(test.contentType, test.query, []byte(test.body), []string{"libera"})
+			if test.expectedErr {
+				if err == nil {
+					t.Errorf("expected an error but got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("did not expect an error but got: %v", err)
+			}
+			if !reflect.DeepEqual(out, test.expected) {
+				t.Errorf("expected %#v, but got %#v", test.expected, out)
+			}
+		})
+	}
+}
+
+// TestFilterTargets verifies that only configured channels are accepted.
+func TestFilterTargets(t *testing.T) {
+	ok, bad := filterTargets([]string{"#OPS", "ops", "#random", "ci"}, []string{"#ops", "ci"})
+	if !reflect.DeepEqual(ok, []string{"#OPS", "#ci"}) {
+		t.Errorf("unexpected accepted: %#v", ok)
+	}
+	if !reflect.DeepEqual(bad, []string{"#random"}) {
+		t.Errorf("unexpected rejected: %#v", bad)
+	}
+
+	ok, bad = filterTargets([]string{"#ops"}, nil)
+	if len(ok) != 0 || !reflect.DeepEqual(bad, []string{"#ops"}) {
+		t.Errorf("expected everything rejected without configured channels, got %#v and %#v", ok, bad)
+	}
+}
+
+// recordSender collects pipeline output.
+type recordSender struct{ sent []string }
+
+func (r *recordSender) SendTo(channels []string, msg string) {
+	for _, ch := range channels {
+		r.sent = append(r.sent, ch+" "+msg)
+	}
+}
+
+func (r *recordSender) Broadcast(msg string) { r.SendTo([]string{"*"}, msg) }
+
+type discard struct{}
+
+func (discard) Printf(string, ...any) {}
+
+// TestHandleSend verifies that the response lists the channels the pipeline
+// actually sent to.
+func TestHandleSend(t *testing.T) {
+	out := &recordSender{}
+	pipe := pipeline.New(out, nil)
+	pipe.Logger = discard{}
+	pipe.Channels = []string{"#ops", "#ci"}
+	pipe.Mute("#ci", time.Minute)
+	s := &Server{Pipeline: pipe, Logger: discard{}, MaxBodyBytes: 1024}
+
+	// Setup test cases
+	tests := []struct {
+		name     string
+		body     string
+		status   int
+		expected sendResponse
+	}{
+		{
+			name:     "BroadcastSkipsMuted",
+			body:     `{"message":"deploy done"}`,
+			status:   nethttp.StatusOK,
+			expected: sendResponse{Accepted: []string{"#ops"}, Rejected: []string{}, Lines: 1},
+		},
+		{
+			name:     "Targets",
+			body:     `{"channels":["#ops","#nope"],"message":"hello"}`,
+			status:   nethttp.StatusOK,
+			expected: sendResponse{Accepted: []string{"#ops"}, Rejected: []string{"#nope"}, Lines: 1},
+		},
+		{
+			name:     "OnlyMuted",
+			body:     `{"channels":["#ci"],"message":"quiet"}`,
+			status:   nethttp.StatusUnprocessableEntity,
+			expected: sendResponse{Accepted: []string{}, Rejected: []string{}, Error: "no message was sent"},
+		},
+	}
+
+	// Run test cases
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(nethttp.MethodPost, "/send", strings.NewReader(test.body))
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+			s.handleSend(rec, req)
+			var got sendResponse
+			if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if rec.Code != test.status || !reflect.DeepEqual(got, test.expected) {
+				t.Errorf("expected %d %#v, but got %d %#v", test.status, test.expected, rec.Code, got)
+			}
+		})
+	}
+	if expected := []string{"#ops deploy done", "#ops hello"}; !reflect.DeepEqual(out.sent, expected) {
+		t.Errorf("expected %#v sent, but got %#v", expected, out.sent)
+	}
+}
@@ -0,0 +1,222 @@
+/*
+MIT License
+
+Copyright (c) 2025 Mikael Schultz <mikael@conf-t.se>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package syslog
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Message is a parsed syslog message (RFC 3164 or RFC 5424).
+type Message struct {
+	Facility  int
+	Severity  int
+	Timestamp time.Time // zero if missing or unparsable
+	Hostname  string
+	AppName   string // RFC 3164 TAG or RFC 5424 APP-NAME
+	ProcID    string
+	MsgID     string // RFC 5424 only
+	Msg       string
+	RFC5424   bool
+}
+
+// ErrNoPriority is returned when a packet does not start with "<PRI>".
+var ErrNoPriority = errors.New("syslog: missing <PRI> header")
+
+var facilityNames = []string{
+	"kern", "user", "mail", "daemon", "auth", "syslog", "lpr", "news",
+	"uucp", "cron", "authpriv", "ftp", "ntp", "security", "console", "solaris-cron",
+	"local0", "local1", "local2", "local3", "local4", "local5", "local6", "local7",
+}
+
+var severityNames = []string{
+	"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug",
+}
+
+// FacilityName returns the keyword for the facility (e.g. "local0").
+func (m Message) FacilityName() string {
+	if m.Facility >= 0 && m.Facility < len(facilityNames) {
+		return facilityNames[m.Facility]
+	}
+	return strconv.Itoa(m.Facility)
+}
+
+// SeverityName returns the keyword for the severity (e.g. "warning").
+func (m Message) SeverityName() string {
+	if m.Severity >= 0 && m.Severity < len(severityNames) {
+		return severityNames[m.Severity]
+	}
+	return strconv.Itoa(m.Severity)
+}
+
+// Parse decodes a single syslog packet. RFC 5424 is detected by the version
+// digit after PRI; anything else is parsed leniently as RFC 3164, since many
+// devices send only parts of the BSD header.
+func Parse(b []byte) (Message, error) {
+	s := strings.TrimRight(string(b), "\r\n\x00")
+	var m Message
+
+	// <PRI>
+	if !strings.HasPrefix(s, "<") {
+		return m, ErrNoPriority
+	}
+	end := strings.IndexByte(s, '>')
+	if end < 2 || end > 4 {
+		return m, ErrNoPriority
+	}
+	pri, err := strconv.Atoi(s[1:end])
+	if err != nil || pri < 0 || pri > 191 {
+		return m, ErrNoPriority
+	}
+	m.Facility = pri / 8
+	m.Severity = pri % 8
+	s = s[end+1:]
+
+	if len(s) >= 2 && s[0] == '1' && s[1] == ' ' {
+		parse5424(&m, s[2:])
+		return m, nil
+	}
+	parse3164(&m, s)
+	return m, nil
+}
+
+// parse5424 parses "TIMESTAMP HOSTNAME APP-NAME PROCID MSGID SD [MSG]".
+func parse5424(m *Message, s string) {
+	m.RFC5424 = true
+	fields := make([]string, 5)
+	for i := range fields {
+		var tok string
+		tok, s, _ = strings.Cut(s, " ")
+		if tok != "-" {
+			fields[i] = tok
+		}
+	}
+	if fields[0] != "" {
+		if ts, err := time.Parse(time.RFC3339Nano, fields[0]); err == nil {
+			m.Timestamp = ts
+		}
+	}
+	m.Hostname, m.AppName, m.ProcID, m.MsgID = fields[1], fields[2], fields[3], fields[4]
+
+	// STRUCTURED-DATA: "-" or one or more [id key="value" ...] elements
+	s = skipStructuredData(s)
+	s = strings.TrimPrefix(s, " ")
+	m.Msg = strings.TrimPrefix(s, "\ufeff") // optional UTF-8 BOM
+}
+
+// skipStructuredData returns s after the STRUCTURED-DATA part.
+func skipStructuredData(s string) string {
+	if strings.HasPrefix(s, "-") {
+		return s[1:]
+	}
+	for strings.HasPrefix(s, "[") {
+		end := sdElementEnd(s)
+		if end < 0 {
+			return "" // unterminated SD element
+		}
+		s = s[end+1:]
+	}
+	return s
+}
+
+// sdElementEnd returns the index of the ']' closing the SD element at the start
+// of s, honoring quoted values and backslash escapes, or -1 if there is none.
+func sdElementEnd(s string) int {
+	inQuote := false
+	for i := 1; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\\' && inQuote:
+			i++ // skip escaped char (\" \] \\)
+		case c == '"':
+			inQuote = !inQuote
+		case c == ']' && !inQuote:
+			return i
+		}
+	}
+	return -1
+}
+
+// parse3164 parses "Mmm dd hh:mm:ss HOSTNAME TAG[PID]: MSG" and degrades
+// gracefully when the timestamp or hostname are missing.
+func parse3164(m *Message, s string) {
+	if len(s) >= 16 && s[15] == ' ' {
+		if ts, err := time.Parse(time.Stamp, s[:15]); err == nil {
+			// BSD timestamps have no year; assume the current one.
+			now := time.Now()
+			ts = time.Date(now.Year(), ts.Month(), ts.Day(), ts.Hour(), ts.Minute(), ts.Second(), 0, time.Local)
+			if ts.After(now.Add(24 * time.Hour)) {
+				ts = ts.AddDate(-1, 0, 0) // December message received in January
+			}
+			m.Timestamp = ts
+			s = s[16:]
+
+			// HOSTNAME follows a valid timestamp unless it is already the tag.
+			if host, rest, ok := strings.Cut(s, " "); ok && !isTag(host) {
+				m.Hostname = host
+				s = rest
+			}
+		}
+	}
+
+	// TAG: alphanumeric name, optional [PID], terminated by ':'
+	if tok, rest, ok := strings.Cut(s, " "); ok && isTag(tok) {
+		tok = strings.TrimSuffix(tok, ":")
+		if name, pid, ok := strings.Cut(tok, "["); ok {
+			m.AppName = name
+			m.ProcID = strings.TrimSuffix(pid, "]")
+		} else {
+			m.AppName = tok
+		}
+		s = rest
+	}
+	m.Msg = strings.TrimSpace(s)
+}
+
+// isTag reports whether tok looks like a 3164 TAG ("sshd:", "sshd[123]:").
+func isTag(tok string) bool {
+	if !strings.HasSuffix(tok, ":") || len(tok) < 2 {
+		return false
+	}
+	name := strings.TrimSuffix(tok, ":")
+	if i := strings.IndexByte(name, '['); i >= 0 {
+		if !strings.HasSuffix(name, "]") {
+			return false
+		}
+		name = name[:i]
+	}
+	if name == "" || len(name) > 48 {
+		return false
+	}
+	for _, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '-' || r == '_' || r == '.' || r == '/':
+		default:
+			return false
+		}
+	}
+	return true
+}
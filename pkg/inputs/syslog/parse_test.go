@@ -0,0 +1,109 @@
+package syslog
+
+import (
+	"testing"
+	"time"
+)
+
+// TestParse tests the Parse function with RFC 3164 and RFC 5424 packets,
+// including the partial headers that network devices commonly send.
+func TestParse(t *testing.T) {
+	// Setup test cases
+	tests := []struct {
+		name     string
+		input    string
+		expected Message
+		hasTS    bool
+	}{
+		{
+			name:  "RFC3164Full",
+			input: "<34>Oct 11 22:14:15 mymachine su[230]: 'su root' failed for lonvick on /dev/pts/8",
+			expected: Message{
+				Facility: 4, Severity: 2,
+				Hostname: "mymachine", AppName: "su", ProcID: "230",
+				Msg: "'su root' failed for lonvick on /dev/pts/8",
+			},
+			hasTS: true,
+		},
+		{
+			name:  "RFC3164NoHostname",
+			input: "<13>Feb  5 17:32:18 sshd: Accepted publickey for admin",
+			expected: Message{
+				Facility: 1, Severity: 5,
+				AppName: "sshd",
+				Msg:     "Accepted publickey for admin",
+			},
+			hasTS: true,
+		},
+		{
+			name:  "RFC3164PriOnly",
+			input: "<189>%LINK-3-UPDOWN: Interface Gi0/1, changed state to down\n",
+			expected: Message{
+				Facility: 23, Severity: 5,
+				Msg: "%LINK-3-UPDOWN: Interface Gi0/1, changed state to down",
+			},
+		},
+		{
+			name:  "RFC5424WithStructuredData",
+			input: `<165>1 2003-10-11T22:14:15.003Z mymachine.example.com evntslog - ID47 [exampleSDID@32473 iut="3" eventSource="Application" eventID="1011"] An application event`,
+			expected: Message{
+				Facility: 20, Severity: 5,
+				Hostname: "mymachine.example.com", AppName: "evntslog", MsgID: "ID47",
+				Msg: "An application event", RFC5424: true,
+			},
+			hasTS: true,
+		},
+		{
+			name:  "RFC5424EscapedBracketInSD",
+			input: `<14>1 - host app 42 - [a@1 k="x\]y"][b@1 z="1"] hello`,
+			expected: Message{
+				Facility: 1, Severity: 6,
+				Hostname: "host", AppName: "app", ProcID: "42",
+				Msg: "hello", RFC5424: true,
+			},
+		},
+		{
+			name:  "RFC5424NilSDWithBOM",
+			input: "<11>1 2025-01-02T03:04:05+01:00 fw1 kernel - - - \ufeffdrop in:eth0",
+			expected: Message{
+				Facility: 1, Severity: 3,
+				Hostname: "fw1", AppName: "kernel",
+				Msg: "drop in:eth0", RFC5424: true,
+			},
+			hasTS: true,
+		},
+	}
+	// Run test cases
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			m, err := Parse([]byte(test.input))
+			if err != nil {
+				t.Fatalf("did not expect an error but got: %v", err)
+			}
+			if m.Timestamp.IsZero() == test.hasTS {
+				t.Errorf("expected timestamp=%v, got %v", test.hasTS, m.Timestamp)
+			}
+			m.Timestamp = time.Time{}
+			if m != test.expected {
+				t.Errorf("expected %+v, but got %+v", test.expected, m)
+			}
+		})
+	}
+}
+
+// TestParseInvalid verifies that packets without a valid <PRI> are rejected.
+func TestParseInvalid(t *testing.T) {
+	for _, in := range []string{"", "hello", "<>x", "<999>x", "<abc>x"} {
+		if _, err := Parse([]byte(in)); err == nil {
+			t.Errorf("Parse(%q): expected an error but got nil", in)
+		}
+	}
+}
+
+// TestNames verifies facility and severity keywords.
+func TestNames(t *testing.T) {
+	m := Message{Facility: 16, Severity: 4}
+	if m.FacilityName() != "local0" || m.SeverityName() != "warning" {
+		t.Errorf("expected local0.warning, got %s.%s", m.FacilityName(), m.SeverityName())
+	}
+}
@@ -0,0 +1,248 @@
+/*
+MIT License
+
+Copyright (c) 2025 Mikael Schultz <mikael@conf-t.se>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package syslog
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/bitcanon/ircpush/pkg/inputs"
+	"github.com/bitcanon/ircpush/pkg/metrics"
+	"github.com/bitcanon/ircpush/pkg/pipeline"
+)
+
+// maxPacketBytes is the largest UDP datagram we accept (RFC 5426 allows up to 64 KiB).
+const maxPacketBytes = 64 * 1024
+
+// Server receives syslog messages over UDP and forwards them to IRC.
+type Server struct {
+	ListenAddr string
+
+	// Pipeline highlights and forwards received messages to IRC.
+	Pipeline *pipeline.Pipeline
+
+	// Channels to send to; empty means broadcast to all configured channels.
+	Channels []string
+
+	// Template renders a Message into the IRC line (text/template syntax).
+	// Empty uses "HOSTNAME APP[PID]: MSG".
+	Template string
+
+	// Optional logging sink; if nil, logs go to stderr.
+	Logger Logger
+
+	// Control whether to log each received message (default false).
+	LogMessages bool
+
+	// Replaces is the running server this one takes over from on reload
+	// (optional). Start binds first, reusing its socket when the address is
+	// unchanged, and then stops it; its read loop finishes the datagram in
+	// progress.
+	Replaces *Server
+
+	tmpl *template.Template
+	pc   *inputs.PacketConn
+	wg   sync.WaitGroup
+	once sync.Once
+}
+
+// Logger is a minimal logger interface.
+type Logger interface {
+	Printf(format string, v ...any)
+}
+
+func (s *Server) logf(format string, v ...any) {
+	if s.Logger != nil {
+		s.Logger.Printf(format, v...)
+		return
+	}
+	fmt.Fprintf(os.Stderr, format+"\n", v...)
+}
+
+// Start begins listening for datagrams until ctx is done or Stop is called.
+// It returns once the socket is bound and the read loop has been started.
+func (s *Server) Start(ctx context.Context) error {
+	if s.ListenAddr == "" {
+		return fmt.Errorf("syslog server: ListenAddr is empty")
+	}
+	if s.Pipeline == nil {
+		return fmt.Errorf("syslog server: Pipeline is nil")
+	}
+	if strings.TrimSpace(s.Template) != "" {
+		t, err := template.New("syslog").Parse(s.Template)
+		if err != nil {
+			return fmt.Errorf("syslog server: template: %w", err)
+		}
+		s.tmpl = t
+	}
+	var prev *inputs.PacketConn
+	if s.Replaces != nil {
+		prev = s.Replaces.pc
+	}
+	pc, err := inputs.ListenPacket("udp", s.ListenAddr, prev)
+	if err != nil {
+		return err
+	}
+	s.pc = pc
+	s.logf("syslog: listening on udp %s", s.ListenAddr)
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		buf := make([]byte, maxPacketBytes)
+		for {
+			n, addr, err := pc.ReadFrom(buf)
+			if err != nil {
+				if errors.Is(err, net.ErrClosed) || ctx.Err() != nil {
+					s.logf("syslog: listener closed")
+					return
+				}
+				s.logf("syslog: read error: %v", err)
+				continue
+			}
+			s.handlePacket(buf[:n], addr)
+		}
+	}()
+
+	s.retire()
+
+	// Close socket when ctx is done
+	go func() {
+		<-ctx.Done()
+		_ = s.Stop()
+	}()
+
+	return nil
+}
+
+// retire stops the server this one replaces; Stop waits for it.
+func (s *Server) retire() {
+	old := s.Replaces
+	if old == nil {
+		return
+	}
+	s.Replaces = nil
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		_ = old.Stop()
+	}()
+}
+
+// Stop closes the socket and waits for the read loop to finish.
+func (s *Server) Stop() error {
+	var err error
+	s.once.Do(func() {
+		if s.pc != nil {
+			err = s.pc.Close()
+		}
+	})
+	s.wg.Wait()
+	return err
+}
+
+// Shutdown is Stop: datagrams leave nothing to drain once the socket is
+// closed. It lets the syslog input be drained like the others.
+func (s *Server) Shutdown(context.Context) error {
+	return s.Stop()
+}
+
+func (s *Server) handlePacket(b []byte, addr net.Addr) {
+	src := addr.String()
+	metrics.LinesReceived.Inc("syslog", metrics.Source(src))
+	if !s.Pipeline.AllowSource(src) {
+		s.logf("syslog: %s rejected: source not allowed by acl", src)
+		metrics.LinesDropped.Inc("syslog", "acl")
+		return
+	}
+	m, err := Parse(b)
+	if err != nil {
+		s.logf("syslog: %s: %v, dropping", src, err)
+		metrics.LinesDropped.Inc("syslog", "invalid")
+		return
+	}
+	if m.Hostname == "" {
+		// Fall back to the sender's address (RFC 3164 relays may omit it)
+		if host, _, err := net.SplitHostPort(src); err == nil {
+			m.Hostname = host
+		}
+	}
+	if strings.TrimSpace(m.Msg) == "" {
+		metrics.LinesDropped.Inc("syslog", "empty")
+		return
+	}
+
+	line, err := s.render(m)
+	if err != nil {
+		s.logf("syslog: %s: template: %v", src, err)
+		return
+	}
+	// IRC lines cannot carry newlines
+	line = strings.TrimSpace(strings.NewReplacer("\r", " ", "\n", " ").Replace(line))
+	if line == "" {
+		return
+	}
+	if s.LogMessages {
+		s.logf("syslog: %s [%s.%s] -> %q", src, m.FacilityName(), m.SeverityName(), line)
+	}
+	s.Pipeline.Submit(pipeline.Message{
+		Input:   "syslog",
+		Source:  src,
+		Targets: s.Channels,
+		Text:    line,
+
+		Severity: m.SeverityName(),
+		Facility: m.FacilityName(),
+	})
+}
+
+// render formats m with the configured template, or "HOST APP[PID]: MSG".
+func (s *Server) render(m Message) (string, error) {
+	if s.tmpl != nil {
+		var buf bytes.Buffer
+		if err := s.tmpl.Execute(&buf, m); err != nil {
+			return "", err
+		}
+		return buf.String(), nil
+	}
+	var b strings.Builder
+	b.WriteString(m.Hostname)
+	if m.AppName != "" {
+		b.WriteString(" ")
+		b.WriteString(m.AppName)
+		if m.ProcID != "" {
+			b.WriteString("[" + m.ProcID + "]")
+		}
+	}
+	b.WriteString(": ")
+	b.WriteString(m.Msg)
+	return b.String(), nil
+}
@@ -0,0 +1,138 @@
+package syslog
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bitcanon/ircpush/pkg/metrics"
+	"github.com/bitcanon/ircpush/pkg/pipeline"
+)
+
+/*
+UDP server test on the loopback interface.
+
+Verifies:
+1. Start binds the socket and datagrams are parsed, rendered and forwarded.
+2. Packets that are not syslog are dropped and counted.
+3. Stop closes the socket and returns once the read loop has finished.
+*/
+
+// recordSender collects pipeline output.
+type recordSender struct {
+	mu   sync.Mutex
+	sent []string
+}
+
+func (r *recordSender) SendTo(channels []string, msg string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, ch := range channels {
+		r.sent = append(r.sent, ch+" "+msg)
+	}
+}
+
+func (r *recordSender) Broadcast(msg string) { r.SendTo([]string{"*"}, msg) }
+
+func (r *recordSender) lines() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.sent...)
+}
+
+type discard struct{}
+
+func (discard) Printf(string, ...any) {}
+
+func waitSent(t *testing.T, r *recordSender, line string) {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for time.Now().Before(deadline) {
+		for _, l := range r.lines() {
+			if l == line {
+				return
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("timeout waiting for %q (got %#v)", line, r.lines())
+}
+
+func TestServerReceivesDatagrams(t *testing.T) {
+	out := &recordSender{}
+	pipe := pipeline.New(out, nil)
+	pipe.Logger = discard{}
+	pipe.Channels = []string{"#syslog"}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s := &Server{ListenAddr: "127.0.0.1:0", Pipeline: pipe, Channels: []string{"#syslog"}, Logger: discard{}}
+	if err := s.Start(ctx); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	conn, err := net.Dial("udp", s.pc.LocalAddr().String())
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	defer conn.Close()
+
+	// Setup test cases
+	tests := []struct {
+		name     string
+		packet   string
+		expected string
+	}{
+		{
+			name:     "RFC3164",
+			packet:   "<34>Oct 11 22:14:15 mymachine su[230]: 'su root' failed",
+			expected: "#syslog mymachine su[230]: 'su root' failed",
+		},
+		{
+			name:     "RFC5424",
+			packet:   "<165>1 2003-10-11T22:14:15.003Z host1 evntslog - ID47 - link down",
+			expected: "#syslog host1 evntslog: link down",
+		},
+		{
+			name:     "NoHostname",
+			packet:   "<13>Feb  5 17:32:18 sshd: Accepted publickey for admin",
+			expected: "#syslog 127.0.0.1 sshd: Accepted publickey for admin",
+		},
+	}
+
+	// Run test cases
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := conn.Write([]byte(test.packet)); err != nil {
+				t.Fatalf("Write: %v", err)
+			}
+			waitSent(t, out, test.expected)
+		})
+	}
+
+	invalid := metrics.LinesDropped.Value("syslog", "invalid")
+	_, _ = conn.Write([]byte("no priority here"))
+	_, _ = conn.Write([]byte("<34>Oct 11 22:14:15 mymachine app: marker"))
+	waitSent(t, out, "#syslog mymachine app: marker")
+	if got := metrics.LinesDropped.Value("syslog", "invalid"); got != invalid+1 {
+		t.Errorf("expected 1 invalid packet to be counted, but got %v", got-invalid)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- s.Stop() }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Stop: %v", err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatalf("Stop did not return")
+	}
+	n := len(out.lines())
+	_, _ = conn.Write([]byte("<34>Oct 11 22:14:15 mymachine app: after stop"))
+	time.Sleep(100 * time.Millisecond)
+	if got := len(out.lines()); got != n {
+		t.Errorf("expected nothing forwarded after Stop, but got %#v", out.lines()[n:])
+	}
+}
@@ -0,0 +1,74 @@
+/*
+MIT License
+
+Copyright (c) 2025 Mikael Schultz <mikael@conf-t.se>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package inputs
+
+import (
+	"strings"
+
+	"github.com/bitcanon/ircpush/pkg/config"
+	"github.com/bitcanon/ircpush/pkg/pipeline"
+)
+
+// ParseTargets parses an optional leading channel list of a line-based input
+// and returns the targets and the message. A list starts with a channel or
+// with "network/#channel" for one of networks.
+// Examples:
+//
+//	"#security hello"    -> ["#security"], "hello"
+//	"#a,#b hi"           -> ["#a", "#b"], "hi"
+//	"libera/#ops,#a hi"  -> ["libera/#ops", "#a"], "hi"
+//	"build/#12 failed"   -> nil, "build/#12 failed" (unless build is a network)
+//	"no prefix"          -> nil, "no prefix"
+func ParseTargets(line string, networks []string) ([]string, string) {
+	s := strings.TrimSpace(line)
+	if s == "" {
+		return nil, ""
+	}
+	first, rest, hasRest := strings.Cut(s, " ")
+	chTokens := strings.Split(first, ",")
+	if net, _ := config.SplitChannel(chTokens[0], networks); net == "" && !(strings.HasPrefix(s, "#") || strings.HasPrefix(s, "&")) {
+		return nil, s
+	}
+
+	var out []string
+	seen := map[string]struct{}{}
+	for _, ch := range chTokens {
+		ch = pipeline.ChannelName(ch)
+		if ch == "" {
+			continue
+		}
+		lc := strings.ToLower(ch)
+		if _, ok := seen[lc]; ok {
+			continue
+		}
+		seen[lc] = struct{}{}
+		out = append(out, ch)
+	}
+
+	msg := ""
+	if hasRest {
+		msg = strings.TrimSpace(rest)
+	}
+	return out, msg
+}
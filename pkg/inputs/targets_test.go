@@ -0,0 +1,36 @@
+package inputs
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestParseTargets tests the leading channel list of tcp and http lines.
+func TestParseTargets(t *testing.T) {
+	// Setup test cases
+	tests := []struct {
+		name     string
+		line     string
+		targets  []string
+		expected string
+	}{
+		{name: "Single", line: "#security hello", targets: []string{"#security"}, expected: "hello"},
+		{name: "List", line: "#a,#b,#A  hi there ", targets: []string{"#a", "#b"}, expected: "hi there"},
+		{name: "Network", line: "libera/#ops,#a hi", targets: []string{"libera/#ops", "#a"}, expected: "hi"},
+		{name: "NoPrefix", line: "no prefix", expected: "no prefix"},
+		{name: "Path", line: "api/v2 is live", expected: "api/v2 is live"},
+		{name: "NotANetwork", line: "build/#12 failed", expected: "build/#12 failed"},
+		{name: "OnlyTargets", line: "#ops", targets: []string{"#ops"}},
+		{name: "Empty", line: "   "},
+	}
+
+	// Run test cases
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			targets, msg := ParseTargets(test.line, []string{"libera"})
+			if !reflect.DeepEqual(targets, test.targets) || msg != test.expected {
+				t.Errorf("expected %#v %q, but got %#v %q", test.targets, test.expected, targets, msg)
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package tcp
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/bitcanon/ircpush/pkg/pipeline"
+)
+
+/*
+Reload test for a server replacing another on the same address.
+
+Verifies:
+1. Connections accepted by the old server keep being served after the takeover.
+2. New connections are accepted by the new server and use its settings.
+3. A new server that cannot bind leaves the old one running.
+4. The old server is released once its last connection has closed.
+*/
+
+func TestReplaceKeepsConnections(t *testing.T) {
+	out := &recordSender{}
+	pipe := pipeline.New(out, nil)
+	pipe.Logger = discard{}
+	pipe.Channels = []string{"#a"}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	old := &Server{ListenAddr: "127.0.0.1:0", Pipeline: pipe, MaxLineBytes: 16, Logger: discard{}}
+	if err := old.Start(ctx); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	addr := old.ln.Addr().String()
+
+	before, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	defer before.Close()
+	_, _ = before.Write([]byte("#a first\n"))
+	waitSent(t, out, "#a first")
+
+	// Setup test cases
+	bad := &Server{ListenAddr: "192.0.2.1:1", Pipeline: pipe, Logger: discard{}, Replaces: old}
+	if err := bad.Start(ctx); err == nil {
+		t.Fatalf("expected a bind error")
+	}
+	next := &Server{ListenAddr: "127.0.0.1:0", Pipeline: pipe, MaxLineBytes: 64, Logger: discard{}, Replaces: old}
+	if err := next.Start(ctx); err != nil {
+		t.Fatalf("Start replacement: %v", err)
+	}
+	defer next.Stop()
+	if got := next.ln.Addr().String(); got != addr {
+		t.Fatalf("expected the replacement on %s, but got %s", addr, got)
+	}
+
+	// Run test cases
+	_, _ = before.Write([]byte("#a still served\n"))
+	waitSent(t, out, "#a still served")
+
+	after, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("Dial after replace: %v", err)
+	}
+	defer after.Close()
+	_, _ = after.Write([]byte("#a longer than sixteen bytes\n"))
+	waitSent(t, out, "#a longer than sixteen bytes")
+
+	// Closing the old connection lets the old server finish
+	before.Close()
+	after.Close()
+	released := func() bool {
+		next.connMu.Lock()
+		defer next.connMu.Unlock()
+		return next.retired == nil
+	}
+	for deadline := time.Now().Add(2 * time.Second); !released(); time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("the replaced server was not released after its connections closed")
+		}
+	}
+}
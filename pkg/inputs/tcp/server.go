@@ -26,6 +26,7 @@ package tcp
 import (
 	"bufio"
 	"context"
+	"crypto/tls"
 	"errors" // added
 	"fmt"
 	"net"
@@ -34,18 +35,17 @@ import (
 	"sync"
 	"time"
 
-	"github.com/bitcanon/ircpush/pkg/highlight"
-	"github.com/bitcanon/ircpush/pkg/irc"
+	"github.com/bitcanon/ircpush/pkg/inputs"
+	"github.com/bitcanon/ircpush/pkg/metrics"
+	"github.com/bitcanon/ircpush/pkg/pipeline"
 )
 
 // Server receives messages over TCP and forwards them to IRC.
 type Server struct {
 	ListenAddr string
-	IRC        *irc.Client
 
-	// Highlighter can be swapped at runtime via SetHighlighter.
-	mu sync.RWMutex
-	HL *highlight.Highlighter
+	// Pipeline highlights and forwards received lines to IRC.
+	Pipeline *pipeline.Pipeline
 
 	// Optional logging sink; if nil, logs go to stderr.
 	Logger Logger
@@ -56,9 +56,30 @@ type Server struct {
 	// Scanner limits
 	MaxLineBytes int
 
-	ln   net.Listener
-	wg   sync.WaitGroup
-	once sync.Once
+	// TLS (optional): serve TLS when TLSCert and TLSKey are set.
+	// TLSClientCA enables client certificate verification; the certificate's
+	// CN/SANs become the sender identity for acl and routes.
+	TLSCert              string
+	TLSKey               string
+	TLSClientCA          string
+	TLSRequireClientCert bool
+
+	// Replaces is the running server this one takes over from on reload
+	// (optional). Start binds first, reusing its socket when the address is
+	// unchanged, and then stops it; its open connections are served until
+	// they close, or until Shutdown of this server closes them.
+	Replaces *Server
+
+	certs *certStore
+	sock  *inputs.Listener
+	ln    net.Listener
+	wg    sync.WaitGroup
+	once  sync.Once
+
+	connMu  sync.Mutex
+	conns   map[net.Conn]struct{}
+	closing bool
+	retired *Server // the server this one replaced
 }
 
 // Logger is a minimal logger interface.
@@ -76,20 +97,37 @@ func (s *Server) logf(format string, v ...any) {
 
 // Start begins listening and serving connections until ctx is done or an error occurs.
 // It returns once the listener is up and the accept loop has been started.
-// Use Stop() to close the listener.
+// When ctx is done the listener is closed; use Shutdown or Stop to end the
+// open connections.
 func (s *Server) Start(ctx context.Context) error {
 	if s.ListenAddr == "" {
 		return fmt.Errorf("tcp server: ListenAddr is empty")
 	}
-	if s.IRC == nil {
-		return fmt.Errorf("tcp server: IRC client is nil")
+	if s.Pipeline == nil {
+		return fmt.Errorf("tcp server: Pipeline is nil")
+	}
+	if s.TLSCert != "" || s.TLSKey != "" {
+		cs, err := newCertStore(s.TLSCert, s.TLSKey, s.TLSClientCA, s.TLSRequireClientCert)
+		if err != nil {
+			return err
+		}
+		s.certs = cs
+	}
+	var prev *inputs.Listener
+	if s.Replaces != nil {
+		prev = s.Replaces.sock
 	}
-	ln, err := net.Listen("tcp", s.ListenAddr)
+	sock, err := inputs.Listen("tcp", s.ListenAddr, prev)
 	if err != nil {
-		return fmt.Errorf("listen %s: %w", s.ListenAddr, err)
+		return err
+	}
+	s.sock = sock
+	var ln net.Listener = sock
+	if s.certs != nil {
+		ln = tls.NewListener(ln, s.certs.config())
 	}
 	s.ln = ln
-	s.logf("tcp: listening on %s", s.ListenAddr)
+	s.logf("tcp: listening on %s (tls=%v, client_cert_required=%v)", s.ListenAddr, s.certs != nil, s.certs != nil && s.TLSRequireClientCert)
 
 	s.wg.Add(1)
 	go func() {
@@ -112,9 +150,20 @@ func (s *Server) Start(ctx context.Context) error {
 				s.logf("tcp: accept error: %v", err)
 				return
 			}
+			if ra := conn.RemoteAddr().String(); !s.Pipeline.AllowSource(ra) {
+				s.logf("tcp: %s rejected: source not allowed by acl", ra)
+				metrics.LinesDropped.Inc("tcp", "acl")
+				_ = conn.Close()
+				continue
+			}
+			if !s.track(conn) {
+				_ = conn.Close()
+				continue
+			}
 			s.wg.Add(1)
 			go func(c net.Conn) {
 				defer s.wg.Done()
+				defer s.untrack(c)
 				_ = c.SetDeadline(time.Time{}) // clear deadline
 				_ = c.SetReadDeadline(time.Time{})
 				s.handleConn(ctx, c)
@@ -122,24 +171,59 @@ func (s *Server) Start(ctx context.Context) error {
 		}
 	}()
 
+	s.retire()
+
 	// Close listener when ctx is done
 	go func() {
 		<-ctx.Done()
-		_ = s.Stop()
+		_ = s.closeListener()
 	}()
 
 	return nil
 }
 
-// Stop closes the listener and waits for connection handlers to finish.
-func (s *Server) Stop() error {
-	var err error
-	s.once.Do(func() {
-		if s.ln != nil {
-			err = s.ln.Close()
-		}
-	})
-	// Give active goroutines a short grace period to finish reading current lines.
+// retire stops the server this one replaces and lets its connections run
+// out; Shutdown waits for them and closes them with its own. The old server
+// is released once its last connection has closed.
+func (s *Server) retire() {
+	old := s.Replaces
+	if old == nil {
+		return
+	}
+	s.Replaces = nil
+	s.connMu.Lock()
+	s.retired = old
+	s.connMu.Unlock()
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		_ = old.Shutdown(context.Background())
+		s.connMu.Lock()
+		s.retired = nil
+		s.connMu.Unlock()
+	}()
+}
+
+// ReloadTLS re-reads the certificate, key and client CA from disk.
+// Existing connections keep their session; new handshakes use the new files.
+// On error the previous settings stay active.
+func (s *Server) ReloadTLS() error {
+	if s.certs == nil {
+		return nil
+	}
+	if err := s.certs.load(); err != nil {
+		return err
+	}
+	s.logf("tcp: tls certificates reloaded")
+	return nil
+}
+
+// Shutdown closes the listener and waits for the open connections to be
+// closed by their clients, forwarding what they still send. When ctx is done
+// first, it closes the remaining connections and waits for their handlers to
+// return.
+func (s *Server) Shutdown(ctx context.Context) error {
+	err := s.closeListener()
 	done := make(chan struct{})
 	go func() {
 		s.wg.Wait()
@@ -147,19 +231,97 @@ func (s *Server) Stop() error {
 	}()
 	select {
 	case <-done:
-	case <-time.After(3 * time.Second):
+		return err
+	case <-ctx.Done():
 	}
+	s.closeConns()
+	<-done
+	return err
+}
+
+// Stop closes the listener and the open connections and waits for their
+// handlers to return.
+func (s *Server) Stop() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	return s.Shutdown(ctx)
+}
+
+func (s *Server) closeListener() error {
+	var err error
+	s.once.Do(func() {
+		if s.ln != nil {
+			err = s.ln.Close()
+		}
+	})
 	return err
 }
 
+// track registers an accepted connection for closeConns. It returns false
+// once the connections are being closed.
+func (s *Server) track(c net.Conn) bool {
+	s.connMu.Lock()
+	defer s.connMu.Unlock()
+	if s.closing {
+		return false
+	}
+	if s.conns == nil {
+		s.conns = map[net.Conn]struct{}{}
+	}
+	s.conns[c] = struct{}{}
+	return true
+}
+
+func (s *Server) untrack(c net.Conn) {
+	s.connMu.Lock()
+	defer s.connMu.Unlock()
+	delete(s.conns, c)
+}
+
+// closeConns closes the open connections, including those of the server
+// this one replaced.
+func (s *Server) closeConns() {
+	s.connMu.Lock()
+	s.closing = true
+	for c := range s.conns {
+		_ = c.Close()
+	}
+	old := s.retired
+	s.connMu.Unlock()
+	if old != nil {
+		old.closeConns()
+	}
+}
+
 func (s *Server) handleConn(ctx context.Context, c net.Conn) {
 	ra := c.RemoteAddr().String()
-	s.logf("tcp: connection from %s", ra)
+	source := metrics.Source(ra)
 	defer func() {
 		_ = c.Close()
 		s.logf("tcp: closed %s", ra)
 	}()
 
+	// TLS: finish the handshake up front to learn the client identity
+	var ids []string
+	if tc, ok := c.(*tls.Conn); ok {
+		_ = tc.SetDeadline(time.Now().Add(10 * time.Second))
+		if err := tc.HandshakeContext(ctx); err != nil {
+			s.logf("tcp: %s tls handshake failed: %v", ra, err)
+			return
+		}
+		_ = tc.SetDeadline(time.Time{})
+		ids = peerIdentities(tc.ConnectionState())
+		if !s.Pipeline.AllowPeer(ra, ids) {
+			s.logf("tcp: %s rejected: identity %v not allowed by acl", ra, ids)
+			return
+		}
+	}
+	if len(ids) > 0 {
+		s.logf("tcp: connection from %s (identity %s)", ra, strings.Join(ids, ", "))
+	} else {
+		s.logf("tcp: connection from %s", ra)
+	}
+
 	sc := bufio.NewScanner(c)
 	// Increase max line size if requested
 	if s.MaxLineBytes <= 0 {
@@ -169,30 +331,26 @@ func (s *Server) handleConn(ctx context.Context, c net.Conn) {
 	sc.Buffer(buf, s.MaxLineBytes)
 
 	for sc.Scan() {
-		select {
-		case <-ctx.Done():
-			return
-		default:
-		}
-
 		line := strings.TrimRight(sc.Text(), "\r\n")
 		if line == "" {
 			continue
 		}
+		metrics.LinesReceived.Inc("tcp", source)
 
 		// Parse optional leading channels (e.g. "#server msg" or "#a,#b msg")
-		targets, msg := parseTargets(line)
+		targets, msg := inputs.ParseTargets(line, s.Pipeline.Networks)
 		if len(targets) == 0 {
 			if s.LogMessages {
 				s.logf("tcp: %s -> broadcast: %q", ra, line)
 			}
-			s.broadcast(line)
+			s.Pipeline.Submit(pipeline.Message{Input: "tcp", Source: ra, Identities: ids, Text: line})
 			continue
 		}
 
 		// Send only to specified channels
 		if strings.TrimSpace(msg) == "" {
 			// If there's no message after the channels, skip
+			metrics.LinesDropped.Inc("tcp", "empty")
 			if s.LogMessages {
 				s.logf("tcp: %s -> empty message after targets %v", ra, targets)
 			}
@@ -201,84 +359,15 @@ func (s *Server) handleConn(ctx context.Context, c net.Conn) {
 		if s.LogMessages {
 			s.logf("tcp: %s -> targets %v: %q", ra, targets, msg)
 		}
-		for _, ch := range targets {
-			colored := s.applyHL(ch, msg)
-			s.IRC.SendTo([]string{ch}, colored)
-		}
+		s.Pipeline.Submit(pipeline.Message{Input: "tcp", Source: ra, Identities: ids, Targets: targets, Text: msg})
 	}
 	if err := sc.Err(); err != nil {
 		// Special-case too-long tokens to make drop explicit
 		if errors.Is(err, bufio.ErrTooLong) {
+			metrics.LinesDropped.Inc("tcp", "too_long")
 			s.logf("tcp: %s line exceeded max_line_bytes=%d, dropping", ra, s.MaxLineBytes)
 		} else {
 			s.logf("tcp: %s scanner error: %v", ra, err)
 		}
 	}
 }
-
-func (s *Server) applyHL(channel, msg string) string {
-	s.mu.RLock()
-	hl := s.HL
-	s.mu.RUnlock()
-	if hl == nil {
-		return msg
-	}
-	return hl.ApplyFor(channel, msg)
-}
-
-// SetHighlighter replaces the active highlighter safely at runtime.
-func (s *Server) SetHighlighter(h *highlight.Highlighter) {
-	s.mu.Lock()
-	s.HL = h
-	s.mu.Unlock()
-	s.logf("tcp: highlighter reloaded")
-}
-
-func (s *Server) broadcast(line string) {
-	// We don't know the configured channel list here. The IRC client has it.
-	// Broadcast and let the client expand channels.
-	colored := s.applyHL("", line)
-	s.IRC.Broadcast(colored)
-}
-
-// parseTargets parses an optional leading channel list and returns targets + message.
-// Examples:
-//
-//	"#security hello"    -> ["#security"], "hello"
-//	"#a,#b hi"           -> ["#a", "#b"], "hi"
-//	"no prefix"          -> nil, "no prefix"
-func parseTargets(line string) ([]string, string) {
-	s := strings.TrimSpace(line)
-	if s == "" {
-		return nil, ""
-	}
-	if !(strings.HasPrefix(s, "#") || strings.HasPrefix(s, "&")) {
-		return nil, s
-	}
-	first, rest, hasRest := strings.Cut(s, " ")
-	chTokens := strings.Split(first, ",")
-
-	var out []string
-	seen := map[string]struct{}{}
-	for _, ch := range chTokens {
-		ch = strings.TrimSpace(ch)
-		if ch == "" {
-			continue
-		}
-		if !strings.HasPrefix(ch, "#") && !strings.HasPrefix(ch, "&") {
-			ch = "#" + ch
-		}
-		lc := strings.ToLower(ch)
-		if _, ok := seen[lc]; ok {
-			continue
-		}
-		seen[lc] = struct{}{}
-		out = append(out, ch)
-	}
-
-	msg := ""
-	if hasRest {
-		msg = strings.TrimSpace(rest)
-	}
-	return out, msg
-}
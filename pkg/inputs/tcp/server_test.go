@@ -0,0 +1,54 @@
+package tcp
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/bitcanon/ircpush/pkg/pipeline"
+)
+
+// TestShutdown verifies that Shutdown keeps forwarding lines from open
+// connections until ctx is done, and then closes them.
+func TestShutdown(t *testing.T) {
+	out := &recordSender{}
+	pipe := pipeline.New(out, nil)
+	pipe.Logger = discard{}
+	pipe.Channels = []string{"#a"}
+
+	s := &Server{ListenAddr: "127.0.0.1:0", Pipeline: pipe, Logger: discard{}}
+	if err := s.Start(context.Background()); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	c, err := net.Dial("tcp", s.ln.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	defer c.Close()
+	_, _ = c.Write([]byte("#a before\n"))
+	waitSent(t, out, "#a before")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- s.Shutdown(ctx) }()
+
+	_, _ = c.Write([]byte("#a while draining\n"))
+	waitSent(t, out, "#a while draining")
+	select {
+	case err := <-done:
+		t.Fatalf("Shutdown returned before ctx was done: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("Shutdown did not close the idle connection")
+	}
+	_ = c.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := c.Read(make([]byte, 1)); err == nil {
+		t.Errorf("expected the connection to be closed")
+	}
+}
@@ -0,0 +1,116 @@
+/*
+MIT License
+
+Copyright (c) 2025 Mikael Schultz <mikael@conf-t.se>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package tcp
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"sync/atomic"
+)
+
+// certStore holds the listener's TLS settings. Reload swaps them atomically:
+// new handshakes use the new certificate and client CA, established
+// connections are not affected.
+type certStore struct {
+	certFile, keyFile, caFile string
+	requireClient             bool
+
+	cur atomic.Pointer[tls.Config]
+}
+
+func newCertStore(certFile, keyFile, caFile string, requireClient bool) (*certStore, error) {
+	if certFile == "" || keyFile == "" {
+		return nil, fmt.Errorf("tcp tls: tls_cert and tls_key must both be set")
+	}
+	if requireClient && caFile == "" {
+		return nil, fmt.Errorf("tcp tls: tls_require_client_cert needs tls_client_ca")
+	}
+	cs := &certStore{certFile: certFile, keyFile: keyFile, caFile: caFile, requireClient: requireClient}
+	if err := cs.load(); err != nil {
+		return nil, err
+	}
+	return cs, nil
+}
+
+// load reads the certificate, key and client CA bundle from disk.
+func (cs *certStore) load() error {
+	cert, err := tls.LoadX509KeyPair(cs.certFile, cs.keyFile)
+	if err != nil {
+		return fmt.Errorf("tcp tls: load certificate: %w", err)
+	}
+	cfg := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if cs.caFile != "" {
+		pem, err := os.ReadFile(cs.caFile)
+		if err != nil {
+			return fmt.Errorf("tcp tls: read client CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("tcp tls: no certificates found in %s", cs.caFile)
+		}
+		cfg.ClientCAs = pool
+		cfg.ClientAuth = tls.VerifyClientCertIfGiven
+		if cs.requireClient {
+			cfg.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+	}
+	cs.cur.Store(cfg)
+	return nil
+}
+
+// config returns the listener config; each handshake picks up the current settings.
+func (cs *certStore) config() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			return cs.cur.Load(), nil
+		},
+	}
+}
+
+// peerIdentities returns the CN and SANs of a verified client certificate.
+func peerIdentities(st tls.ConnectionState) []string {
+	if len(st.VerifiedChains) == 0 || len(st.PeerCertificates) == 0 {
+		return nil
+	}
+	crt := st.PeerCertificates[0]
+	var ids []string
+	if crt.Subject.CommonName != "" {
+		ids = append(ids, crt.Subject.CommonName)
+	}
+	ids = append(ids, crt.DNSNames...)
+	ids = append(ids, crt.EmailAddresses...)
+	for _, u := range crt.URIs {
+		ids = append(ids, u.String())
+	}
+	for _, ip := range crt.IPAddresses {
+		ids = append(ids, ip.String())
+	}
+	return ids
+}
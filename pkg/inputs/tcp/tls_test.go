@@ -0,0 +1,233 @@
+package tcp
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bitcanon/ircpush/pkg/acl"
+	"github.com/bitcanon/ircpush/pkg/config"
+	"github.com/bitcanon/ircpush/pkg/pipeline"
+)
+
+/*
+TLS listener test with an in-memory CA.
+
+Verifies:
+1. Clients with a certificate signed by tls_client_ca can send; the CN is the identity used by the acl.
+2. Clients without a certificate are refused when tls_require_client_cert is set.
+3. ReloadTLS swaps the server certificate for new connections without dropping existing ones.
+*/
+
+type testCA struct {
+	crt *x509.Certificate
+	key *ecdsa.PrivateKey
+	pem []byte
+}
+
+var serial int64
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	serial++
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(serial),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		NotBefore:             time.Now().Add(-time.Minute),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("CreateCertificate: %v", err)
+	}
+	crt, _ := x509.ParseCertificate(der)
+	return &testCA{crt: crt, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue returns a PEM certificate and key signed by the CA.
+func (ca *testCA) issue(t *testing.T, cn string, server bool) (certPEM, keyPEM []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	serial++
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	if server {
+		tmpl.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+		tmpl.IPAddresses = []net.IP{net.ParseIP("127.0.0.1")}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.crt, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatalf("CreateCertificate: %v", err)
+	}
+	kb, _ := x509.MarshalECPrivateKey(key)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: kb})
+}
+
+func writeFile(t *testing.T, path string, b []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, b, 0o600); err != nil {
+		t.Fatalf("write %s: %v", path, err)
+	}
+}
+
+// recordSender collects pipeline output.
+type recordSender struct {
+	mu   sync.Mutex
+	sent []string
+}
+
+func (r *recordSender) SendTo(channels []string, msg string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, ch := range channels {
+		r.sent = append(r.sent, ch+" "+msg)
+	}
+}
+
+func (r *recordSender) Broadcast(msg string) { r.SendTo([]string{"*"}, msg) }
+
+func (r *recordSender) has(line string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, l := range r.sent {
+		if l == line {
+			return true
+		}
+	}
+	return false
+}
+
+type discard struct{}
+
+func (discard) Printf(string, ...any) {}
+
+func waitSent(t *testing.T, r *recordSender, line string) {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for time.Now().Before(deadline) {
+		if r.has(line) {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("timeout waiting for %q (got %#v)", line, r.sent)
+}
+
+// TestTLSClientIdentityAndReload exercises mTLS, identity ACLs and certificate reload.
+func TestTLSClientIdentityAndReload(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	certFile, keyFile, caFile := filepath.Join(dir, "srv.crt"), filepath.Join(dir, "srv.key"), filepath.Join(dir, "ca.crt")
+	c, k := ca.issue(t, "ircpush", true)
+	writeFile(t, certFile, c)
+	writeFile(t, keyFile, k)
+	writeFile(t, caFile, ca.pem)
+
+	out := &recordSender{}
+	pipe := pipeline.New(out, nil)
+	pipe.Logger = discard{}
+	pipe.Channels = []string{"#network", "#security"}
+	list, err := acl.New([]config.ACLRule{{Identity: []string{"fw*.example.com"}, Channels: []string{"#network"}}})
+	if err != nil {
+		t.Fatalf("acl.New: %v", err)
+	}
+	pipe.SetACL(list)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s := &Server{
+		ListenAddr:           "127.0.0.1:0",
+		Pipeline:             pipe,
+		Logger:               discard{},
+		TLSCert:              certFile,
+		TLSKey:               keyFile,
+		TLSClientCA:          caFile,
+		TLSRequireClientCert: true,
+	}
+	if err := s.Start(ctx); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer s.Stop()
+	addr := s.ln.Addr().String()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.crt)
+	cc, ck := ca.issue(t, "fw1.example.com", false)
+	clientCert, err := tls.X509KeyPair(cc, ck)
+	if err != nil {
+		t.Fatalf("X509KeyPair: %v", err)
+	}
+
+	conn, err := tls.Dial("tcp", addr, &tls.Config{RootCAs: roots, Certificates: []tls.Certificate{clientCert}})
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	defer conn.Close()
+	firstSerial := conn.ConnectionState().PeerCertificates[0].SerialNumber
+	w := bufio.NewWriter(conn)
+	_, _ = w.WriteString("#security denied by acl\n#network link down\n")
+	_ = w.Flush()
+	waitSent(t, out, "#network link down")
+	if out.has("#security denied by acl") {
+		t.Errorf("acl did not restrict the identity to #network")
+	}
+
+	// No client certificate: the handshake must fail
+	if nc, err := tls.Dial("tcp", addr, &tls.Config{RootCAs: roots}); err == nil {
+		_ = nc.SetReadDeadline(time.Now().Add(time.Second))
+		if _, err := nc.Read(make([]byte, 1)); err == nil {
+			t.Errorf("connection without client certificate was accepted")
+		}
+		nc.Close()
+	}
+
+	// Reload with a new server certificate; the existing connection keeps working
+	c, k = ca.issue(t, "ircpush", true)
+	writeFile(t, certFile, c)
+	writeFile(t, keyFile, k)
+	if err := s.ReloadTLS(); err != nil {
+		t.Fatalf("ReloadTLS: %v", err)
+	}
+	_, _ = w.WriteString("#network after reload\n")
+	_ = w.Flush()
+	waitSent(t, out, "#network after reload")
+
+	conn2, err := tls.Dial("tcp", addr, &tls.Config{RootCAs: roots, Certificates: []tls.Certificate{clientCert}})
+	if err != nil {
+		t.Fatalf("Dial after reload: %v", err)
+	}
+	defer conn2.Close()
+	if conn2.ConnectionState().PeerCertificates[0].SerialNumber.Cmp(firstSerial) == 0 {
+		t.Errorf("new connection still uses the old server certificate")
+	}
+}
@@ -0,0 +1,189 @@
+/*
+MIT License
+
+Copyright (c) 2025 Mikael Schultz <mikael@conf-t.se>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package irc
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"golang.org/x/net/proxy"
+)
+
+// goirc compares the tokens of a CAP LS reply with the capabilities it wants
+// literally, so a server advertising "sasl=PLAIN,EXTERNAL" is never sent
+// CAP REQ :sasl and the bot would register without authenticating. It also
+// answers each line of a multi-line LS reply on its own, ending negotiation
+// before a later line offers sasl. capConn rewrites the replies before goirc
+// sees them. goirc only takes a custom connection from a proxy dialer, so
+// clients with SASL connect through the capScheme "proxy", which dials (and
+// does TLS) itself. Its settings are looked up by dialer id and only change
+// in applyPending, while the client is disconnected.
+const capScheme = "ircpush-cap"
+
+var (
+	dialMu  sync.Mutex
+	dialTLS = map[string]*tls.Config{} // dialer id -> TLS settings, nil for plain TCP
+	dialSeq atomic.Uint64
+)
+
+func init() {
+	proxy.RegisterDialerType(capScheme, func(u *url.URL, forward proxy.Dialer) (proxy.Dialer, error) {
+		dialMu.Lock()
+		tlsCfg, ok := dialTLS[u.Host]
+		dialMu.Unlock()
+		if !ok {
+			return nil, fmt.Errorf("irc: unknown dialer %q", u.Host)
+		}
+		return &capDialer{forward: forward, tls: tlsCfg}, nil
+	})
+}
+
+// newDialerID returns an id for setDialer, one per Client.
+func newDialerID() string {
+	return fmt.Sprintf("c%d", dialSeq.Add(1))
+}
+
+// dialURL returns the proxy URL of dialer id for goirc's Config.Proxy.
+func dialURL(id string) string {
+	return capScheme + "://" + id
+}
+
+// setDialer stores the TLS settings of dialer id (nil for plain TCP).
+func setDialer(id string, tlsCfg *tls.Config) {
+	dialMu.Lock()
+	dialTLS[id] = tlsCfg
+	dialMu.Unlock()
+}
+
+// dropDialer forgets dialer id.
+func dropDialer(id string) {
+	dialMu.Lock()
+	delete(dialTLS, id)
+	dialMu.Unlock()
+}
+
+// capDialer connects to the server and wraps the connection in a capConn.
+type capDialer struct {
+	forward proxy.Dialer
+	tls     *tls.Config
+}
+
+func (d *capDialer) Dial(network, addr string) (net.Conn, error) {
+	return d.DialContext(context.Background(), network, addr)
+}
+
+func (d *capDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	var conn net.Conn
+	var err error
+	if cd, ok := d.forward.(proxy.ContextDialer); ok {
+		conn, err = cd.DialContext(ctx, network, addr)
+	} else {
+		conn, err = d.forward.Dial(network, addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if d.tls != nil {
+		tc := tls.Client(conn, d.tls)
+		if err := tc.HandshakeContext(ctx); err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+		conn = tc
+	}
+	return &capConn{Conn: conn, r: bufio.NewReader(conn)}, nil
+}
+
+// capConn passes lines from the server through normalizeCaps.
+type capConn struct {
+	net.Conn
+	r    *bufio.Reader
+	buf  []byte   // rest of the current line
+	held []string // capabilities of a multi-line CAP LS reply so far
+}
+
+func (c *capConn) Read(p []byte) (int, error) {
+	for len(c.buf) == 0 {
+		line, err := c.r.ReadString('\n')
+		if line == "" {
+			return 0, err
+		}
+		// A partial line before an error is returned now, the error on the next Read
+		line, c.held = normalizeCaps(line, c.held)
+		if line == "" && err != nil {
+			return 0, err
+		}
+		c.buf = []byte(line)
+	}
+	n := copy(p, c.buf)
+	c.buf = c.buf[n:]
+	return n, nil
+}
+
+// normalizeCaps strips capability values ("sasl=PLAIN,EXTERNAL" -> "sasl")
+// from CAP LS and CAP NEW replies and returns any other line unchanged.
+// A multi-line LS reply is joined into its last line: a line announcing more
+// ("CAP * LS * :...") returns "" and its capabilities added to held, and the
+// last line gets all of them.
+func normalizeCaps(line string, held []string) (string, []string) {
+	rest := line
+	if strings.HasPrefix(rest, "@") { // message tags
+		_, rest, _ = strings.Cut(rest, " ")
+	}
+	if strings.HasPrefix(rest, ":") { // source
+		_, rest, _ = strings.Cut(rest, " ")
+	}
+	head, trailing, ok := strings.Cut(rest, " :")
+	if !ok {
+		return line, held
+	}
+	f := strings.Fields(head)
+	if len(f) < 3 || !strings.EqualFold(f[0], "CAP") {
+		return line, held
+	}
+	ls := strings.EqualFold(f[2], "LS")
+	if !ls && !strings.EqualFold(f[2], "NEW") {
+		return line, held
+	}
+	body := strings.TrimRight(trailing, "\r\n")
+	caps := strings.Fields(body)
+	for i, capab := range caps {
+		caps[i], _, _ = strings.Cut(capab, "=")
+	}
+	if !ls {
+		return line[:len(line)-len(trailing)] + strings.Join(caps, " ") + trailing[len(body):], held
+	}
+	if len(f) > 3 && f[3] == "*" {
+		return "", append(held, caps...)
+	}
+	caps = append(held, caps...)
+	return line[:len(line)-len(trailing)] + strings.Join(caps, " ") + trailing[len(body):], nil
+}
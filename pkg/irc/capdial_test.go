@@ -0,0 +1,100 @@
+package irc
+
+import (
+	"crypto/tls"
+	"io"
+	"testing"
+
+	"github.com/bitcanon/ircpush/pkg/config"
+)
+
+// TestNormalizeCaps verifies that capability values are stripped from CAP LS
+// and CAP NEW replies only, and that a multi-line LS reply becomes one line.
+func TestNormalizeCaps(t *testing.T) {
+	// Setup test cases
+	tests := []struct {
+		name     string
+		lines    []string
+		expected string
+	}{
+		{
+			name:     "LS",
+			lines:    []string{":irc.local CAP * LS :multi-prefix sasl=PLAIN,EXTERNAL\r\n"},
+			expected: ":irc.local CAP * LS :multi-prefix sasl\r\n",
+		},
+		{
+			name: "MultilineLS",
+			lines: []string{
+				":irc.local CAP bot LS * :multi-prefix draft/x=1,2\r\n",
+				":irc.local CAP bot LS * :away-notify\r\n",
+				":irc.local CAP bot LS :sasl=PLAIN\r\n",
+			},
+			expected: ":irc.local CAP bot LS :multi-prefix draft/x away-notify sasl\r\n",
+		},
+		{
+			name:     "TagsAndNew",
+			lines:    []string{"@time=2025-01-01T00:00:00Z :irc.local CAP bot NEW :sasl=EXTERNAL\r\n"},
+			expected: "@time=2025-01-01T00:00:00Z :irc.local CAP bot NEW :sasl\r\n",
+		},
+		{
+			name:     "ACKUnchanged",
+			lines:    []string{":irc.local CAP bot ACK :sasl\r\n"},
+			expected: ":irc.local CAP bot ACK :sasl\r\n",
+		},
+		{
+			name:     "PrivmsgUnchanged",
+			lines:    []string{":n!u@h PRIVMSG #c :CAP * LS :a=b\r\n"},
+			expected: ":n!u@h PRIVMSG #c :CAP * LS :a=b\r\n",
+		},
+	}
+
+	// Run test cases
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var got []string
+			var held []string
+			for _, line := range test.lines {
+				var out string
+				if out, held = normalizeCaps(line, held); out != "" {
+					got = append(got, out)
+				}
+			}
+			if len(got) != 1 || got[0] != test.expected {
+				t.Errorf("expected %q, but got %q", test.expected, got)
+			}
+		})
+	}
+}
+
+// TestDialerSettingsApplyWhenDisconnected verifies that Reconfigure leaves
+// the dialer of the current connection alone until applyPending.
+func TestDialerSettingsApplyWhenDisconnected(t *testing.T) {
+	cfg := config.IRCConfig{Server: "irc.example.net:6667", Nick: "bot", SASLLogin: "bot", SASLPass: "secret"}
+	c, err := New(cfg, Handlers{}, Options{Logger: io.Discard})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer c.Close()
+	tlsOf := func() (*tls.Config, bool) {
+		dialMu.Lock()
+		defer dialMu.Unlock()
+		tc, ok := dialTLS[c.dialID]
+		return tc, ok
+	}
+
+	c.applyPending()
+	if tc, ok := tlsOf(); !ok || tc != nil {
+		t.Fatalf("expected a plain dialer after applyPending, got %v %v", tc, ok)
+	}
+	cfg.Server, cfg.TLS = "irc.example.net:6697", true
+	if err := c.Reconfigure(cfg); err != nil {
+		t.Fatalf("Reconfigure: %v", err)
+	}
+	if tc, _ := tlsOf(); tc != nil {
+		t.Errorf("Reconfigure changed the dialer before reconnecting")
+	}
+	c.applyPending()
+	if tc, _ := tlsOf(); tc == nil || tc.ServerName != "irc.example.net" {
+		t.Errorf("expected the TLS settings after applyPending, got %v", tc)
+	}
+}
@@ -0,0 +1,240 @@
+/*
+MIT License
+
+Copyright (c) 2025 Mikael Schultz <mikael@conf-t.se>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package irc
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fluffle/goirc/client"
+)
+
+const (
+	defaultKickDelay   = 5 * time.Second
+	defaultJoinRetry   = time.Minute
+	defaultRejoinLimit = 30 * time.Minute
+)
+
+// joinErrors are the numerics a server answers a JOIN with when the channel
+// cannot be joined, and whether joining again later can help.
+var joinErrors = map[string]struct {
+	reason string
+	retry  bool
+}{
+	"471": {"channel is full (471)", true},
+	"473": {"channel is invite-only (473)", true},
+	"474": {"banned (474)", true},
+	"475": {"bad channel key (475), fix irc.keys", false},
+	"437": {"channel is temporarily unavailable (437)", true}, // for nicks see nickRejected
+}
+
+// chanProblem records why a configured channel is not joined on the current
+// connection and when it is tried again.
+type chanProblem struct {
+	reason  string
+	since   time.Time
+	tries   int         // consecutive kicks or failed joins, doubles the delay
+	retry   bool        // rejoined after a delay, see retryAt
+	retryAt time.Time   // zero when not retried or while rejoining
+	timer   *time.Timer // pending rejoin
+	warned  bool        // a send to the channel was reported
+}
+
+// rejoinDelay returns the delay before attempt tries (1, 2, ...) starting at
+// first and doubling up to limit (rejoin.max_delay), or first if that is longer.
+func rejoinDelay(first, limit time.Duration, tries int) time.Duration {
+	if limit <= 0 {
+		limit = defaultRejoinLimit
+	}
+	limit = max(limit, first)
+	d := first
+	for i := 1; i < tries && d < limit; i++ {
+		d *= 2
+	}
+	return min(d, limit)
+}
+
+// channelFailed records that ch is not joined and why. A positive first
+// delay schedules a rejoin, doubled with every consecutive failure.
+func (c *Client) channelFailed(ch, reason string, first time.Duration) {
+	lc := strings.ToLower(ch)
+	limit := c.config().Rejoin.MaxDelay
+	c.stateMu.Lock()
+	if !c.hasChannelLocked(lc) {
+		c.stateMu.Unlock()
+		return
+	}
+	wasReady := c.readyLocked()
+	delete(c.joined, lc)
+	p := c.problems[lc]
+	if p == nil {
+		p = &chanProblem{since: time.Now()}
+		c.problems[lc] = p
+	}
+	if p.timer != nil {
+		p.timer.Stop()
+	}
+	p.reason, p.tries, p.timer, p.retryAt, p.retry = reason, p.tries+1, nil, time.Time{}, first > 0
+	retry := ""
+	if first > 0 {
+		d := rejoinDelay(first, limit, p.tries)
+		p.retryAt = time.Now().Add(d)
+		p.timer = time.AfterFunc(d, func() { c.rejoin(ch) })
+		retry = fmt.Sprintf(", retrying in %s", d)
+	}
+	ready := !wasReady && c.readyLocked() // the last channel still joining failed
+	c.stateMu.Unlock()
+	c.queue.kick() // its messages stop counting toward the queue size
+	logf(c.opts.Logger, "irc: not in %s: %s%s", ch, reason, retry)
+	if ready && c.handlers.Ready != nil {
+		c.handlers.Ready()
+	}
+}
+
+// rejoin joins ch again if it is still configured and not joined.
+func (c *Client) rejoin(ch string) {
+	lc := strings.ToLower(ch)
+	c.stateMu.Lock()
+	ok := c.registered && c.hasChannelLocked(lc) && !c.joined[lc]
+	if p := c.problems[lc]; p != nil {
+		p.timer, p.retryAt = nil, time.Time{}
+	}
+	c.stateMu.Unlock()
+	if ok {
+		c.sendJoin(ch)
+	}
+}
+
+// clearProblemLocked forgets the problem of a channel and stops its rejoin.
+// The caller holds stateMu.
+func (c *Client) clearProblemLocked(lc string) {
+	if p := c.problems[lc]; p != nil && p.timer != nil {
+		p.timer.Stop()
+	}
+	delete(c.problems, lc)
+}
+
+// resetProblemsLocked forgets all channel problems, e.g. on a new connection.
+// The caller holds stateMu.
+func (c *Client) resetProblemsLocked() {
+	for lc := range c.problems {
+		c.clearProblemLocked(lc)
+	}
+}
+
+// warnNotJoined reports once per problem that messages for ch are waiting
+// because the client is not in the channel, or dropped when it does not
+// rejoin (see sendQueue.holdLocked).
+func (c *Client) warnNotJoined(ch string) {
+	c.stateMu.Lock()
+	p := c.problems[strings.ToLower(ch)]
+	warn := p != nil && !p.warned
+	var reason string
+	var retry bool
+	if warn {
+		p.warned, reason, retry = true, p.reason, p.retry
+	}
+	c.stateMu.Unlock()
+	switch {
+	case warn && retry:
+		logf(c.opts.Logger, "irc: messages for %s wait in the queue: not in the channel (%s)", ch, reason)
+	case warn:
+		logf(c.opts.Logger, "irc: messages for %s are dropped: not in the channel (%s)", ch, reason)
+	}
+}
+
+// Joined reports whether the client is in channel on the current connection.
+// Messages for a configured channel that is not joined wait in the queue.
+func (c *Client) Joined(channel string) bool {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+	return c.registered && c.joined[strings.ToLower(ensureChanPrefix(channel))]
+}
+
+// inviteAllowed reports whether src (nick!user@host) matches rejoin.invite_from.
+func (c *Client) inviteAllowed(src string) bool {
+	src = strings.ToLower(src)
+	for _, p := range c.config().Rejoin.InviteFrom {
+		if ok, _ := filepath.Match(strings.ToLower(strings.TrimSpace(p)), src); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// wireChannelHandlers keeps the channels joined: rejoin after KICK, retry
+// after join errors and follow INVITEs from trusted sources.
+func (c *Client) wireChannelHandlers() {
+	c.conn.HandleFunc("kick", func(conn *client.Conn, l *client.Line) {
+		if len(l.Args) < 2 || !strings.EqualFold(l.Args[1], conn.Me().Nick) {
+			return
+		}
+		reason := "kicked by " + l.Nick
+		if text := strings.TrimSpace(l.Text()); len(l.Args) > 2 && text != "" {
+			reason += ": " + text
+		}
+		delay := c.config().Rejoin.KickDelay
+		if delay == 0 {
+			delay = defaultKickDelay
+		}
+		c.channelFailed(l.Args[0], reason, delay)
+	})
+
+	for code, e := range joinErrors {
+		c.conn.HandleFunc(code, func(_ *client.Conn, l *client.Line) {
+			if len(l.Args) < 2 {
+				return
+			}
+			var delay time.Duration
+			if e.retry {
+				if delay = c.config().Rejoin.Retry; delay == 0 {
+					delay = defaultJoinRetry
+				}
+			}
+			c.channelFailed(l.Args[1], e.reason, delay)
+		})
+	}
+
+	c.conn.HandleFunc("invite", func(_ *client.Conn, l *client.Line) {
+		if len(l.Args) < 2 {
+			return
+		}
+		ch := l.Args[1]
+		lc := strings.ToLower(ch)
+		c.stateMu.Lock()
+		wanted := c.registered && c.hasChannelLocked(lc) && !c.joined[lc]
+		c.stateMu.Unlock()
+		if !wanted {
+			return
+		}
+		if !c.inviteAllowed(l.Src) {
+			logf(c.opts.Logger, "irc: ignoring invite to %s from %s (not in rejoin.invite_from)", ch, l.Src)
+			return
+		}
+		logf(c.opts.Logger, "irc: invited to %s by %s", ch, l.Nick)
+		c.sendJoin(ch)
+	})
+}
@@ -27,23 +27,33 @@ import (
 	"context"
 	"crypto/tls"
 	"crypto/x509"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/bitcanon/ircpush/pkg/config"
+	"github.com/bitcanon/ircpush/pkg/ircfmt"
+	"github.com/bitcanon/ircpush/pkg/metrics"
+	"github.com/emersion/go-sasl"
 	"github.com/fluffle/goirc/client"
 )
 
+var errServerNick = errors.New("irc: server and nick are required")
+
+const defaultQuitMessage = "shutdown"
+
 // Options configures client behaviors.
 type Options struct {
-	// DisableFlood keeps library throttling enabled unless set to false.
-	// By default, flood protection is disabled (messages go out immediately).
-	DisableFlood bool
 	// Logger is where verbose/status logs can be written (optional).
 	Logger io.Writer
+	// ChannelPrefix is put before channel names in metrics labels, e.g.
+	// "libera/" when the client is not the primary network (optional).
+	ChannelPrefix string
 }
 
 // Handlers let callers receive status events (all optional).
@@ -52,29 +62,116 @@ type Handlers struct {
 	Welcome      func(raw string)
 	NickInUse    func(args []string)
 	Joined       func(channel string)
+	Ready        func() // registered and the configured channels joined, see Client.Ready
 	Notice       func(src, text string)
 	Error        func(text string)
 	Disconnected func()
+	Lag          func(lag time.Duration) // round trip of each client PING, see ping_interval
 }
 
 // Client represents an IRC client with auto-reconnect and event handlers.
 type Client struct {
-	cfg      config.IRCConfig
+	cfg      config.IRCConfig // guarded by stateMu, see config()
 	opts     Options
+	network  string // cfg.Name, for metrics labels
 	handlers Handlers
 
 	conn     *client.Conn
 	ready    chan struct{} // closed once first "connected" fires
-	stop     chan struct{} // closed to stop reconnect goroutine
+	stop     chan struct{} // closed to stop the reconnector and the other workers
 	reconnCh chan struct{} // signal to (re)connect after disconnect
+	gone     chan struct{} // signalled when a connection ends, see Shutdown
+
+	saslMech   string      // "PLAIN", "EXTERNAL" or ""; guarded by stateMu, see mechanism()
+	saslFailed atomic.Bool // set when SASL failed on the current connection
+	saslDone   atomic.Bool // set when SASL succeeded (903) on the current connection
+	dialID     string      // see capdial.go
+	authErr    chan error  // receives a required-SASL failure during Start
+
+	queue *sendQueue // outbound PRIVMSGs, see queue.go
+
+	// Connection state, see Ready()
+	stateMu    sync.Mutex
+	channels   []string          // channels to be in: irc.channels plus Join, minus Part
+	keys       map[string]string // lower-cased channel -> key
+	registered bool
+	joined     map[string]bool         // lower-cased channel -> joined on this connection
+	problems   map[string]*chanProblem // lower-cased channel -> why it is not joined, see channels.go
+	since      time.Time               // when registered last changed
+	nickTry    int                     // candidate nick tried while registering, see nickCandidate
+	tryNick    string                  // nick last asked for while registering; goirc's Me() follows the server
+	lastSend   atomic.Int64            // unix nanoseconds of the last PRIVMSG written
+
+	// Client PING in flight, see keepalive.go
+	pingToken string    // guarded by stateMu
+	pingSent  time.Time // guarded by stateMu
+	pongCh    chan struct{}
+	lag       atomic.Int64 // nanoseconds, 0 until measured on this connection
+
+	// Connection settings from Reconfigure, applied before the next connect
+	pending     *client.Config
+	pendingMech string
 }
 
 // New creates a new IRC client with the specified config, handlers, and options.
 func New(cfg config.IRCConfig, h Handlers, o Options) (*Client, error) {
 	if cfg.Server == "" || cfg.Nick == "" {
-		return nil, fmt.Errorf("irc: server and nick are required")
+		return nil, errServerNick
+	}
+
+	dialID := newDialerID()
+	ircCfg, mech, err := goircConfig(cfg, o, dialID)
+	if err != nil {
+		return nil, err
+	}
+	qc, err := queueSettings(cfg.Queue)
+	if err != nil {
+		return nil, err
 	}
 
+	c := &Client{
+		cfg:      cfg,
+		opts:     o,
+		network:  cfg.Name,
+		handlers: h,
+		conn:     client.Client(ircCfg),
+		ready:    make(chan struct{}),
+		stop:     make(chan struct{}),
+		reconnCh: make(chan struct{}, 1),
+		gone:     make(chan struct{}, 1),
+		saslMech: mech,
+		dialID:   dialID,
+		authErr:  make(chan error, 1),
+		joined:   map[string]bool{},
+		problems: map[string]*chanProblem{},
+		pongCh:   make(chan struct{}, 1),
+		since:    time.Now(),
+	}
+	c.keys = channelKeys(cfg.Keys)
+	for _, ch := range cfg.Channels {
+		if ch = ensureChanPrefix(ch); ch != "" && !c.hasChannelLocked(strings.ToLower(ch)) {
+			c.channels = append(c.channels, ch)
+		}
+	}
+	send := func(ch, text string) {
+		c.conn.Privmsg(ch, text)
+		c.lastSend.Store(time.Now().UnixNano())
+	}
+	if c.network == "" {
+		c.network = config.DefaultNetwork
+	}
+	c.queue = newSendQueue(qc, send, c.canSend, c.heldBack,
+		func(format string, a ...any) { logf(o.Logger, format, a...) })
+	c.queue.network, c.queue.chanPrefix = c.network, o.ChannelPrefix
+	c.conn.Config().NewNick = c.nextNick
+	c.wireHandlers()
+	return c, nil
+}
+
+// goircConfig builds the goirc connection settings for cfg and returns the
+// SASL mechanism in use ("" when SASL is not configured). With SASL the
+// connection goes through the client's dialer dialID, see capdial.go.
+func goircConfig(cfg config.IRCConfig, o Options, dialID string) (*client.Config, string, error) {
 	ircCfg := client.NewConfig(cfg.Nick)
 	ircCfg.SSL = cfg.TLS
 	if cfg.ServerPass != "" {
@@ -86,8 +183,23 @@ func New(cfg config.IRCConfig, h Handlers, o Options) (*Client, error) {
 	// Set ident to nick by default
 	ircCfg.Me.Ident = cfg.Nick
 
-	// Disable goirc throttling unless explicitly kept
-	ircCfg.Flood = !o.DisableFlood
+	// The send queue does the rate limiting; goirc's own throttle stays off
+	ircCfg.Flood = true
+
+	// SASL (optional): negotiated via CAP LS / CAP REQ :sasl before registration
+	mech, err := saslMechanism(cfg)
+	if err != nil {
+		return nil, "", err
+	}
+	if mech != "" {
+		ircCfg.EnableCapabilityNegotiation = true
+		ircCfg.Capabilites = append(ircCfg.Capabilites, "sasl")
+		if mech == sasl.External {
+			ircCfg.Sasl = sasl.NewExternalClient("")
+		} else {
+			ircCfg.Sasl = sasl.NewPlainClient("", cfg.SASLLogin, cfg.SASLPass)
+		}
+	}
 
 	if cfg.TLS {
 		tlsCfg := &tls.Config{
@@ -99,6 +211,9 @@ func New(cfg config.IRCConfig, h Handlers, o Options) (*Client, error) {
 		if cfg.TLSClientCert != "" && cfg.TLSClientKey != "" {
 			if cert, err := tls.LoadX509KeyPair(cfg.TLSClientCert, cfg.TLSClientKey); err == nil {
 				tlsCfg.Certificates = []tls.Certificate{cert}
+			} else if mech == sasl.External {
+				// EXTERNAL cannot succeed without the certificate
+				return nil, "", fmt.Errorf("irc: sasl_external: load client cert: %w", err)
 			} else {
 				// fallthrough; error will surface on connect if needed
 				logf(o.Logger, "tls: load client cert failed: %v", err)
@@ -110,43 +225,71 @@ func New(cfg config.IRCConfig, h Handlers, o Options) (*Client, error) {
 		}
 		ircCfg.SSLConfig = tlsCfg
 	}
+	if mech != "" {
+		// TLS (SSLConfig, set only with irc.tls) is done by the dialer, see applyPending
+		ircCfg.Proxy = dialURL(dialID)
+		ircCfg.SSL = false
+	}
+	return ircCfg, mech, nil
+}
 
-	c := &Client{
-		cfg:      cfg,
-		opts:     o,
-		handlers: h,
-		conn:     client.Client(ircCfg),
-		ready:    make(chan struct{}),
-		stop:     make(chan struct{}),
-		reconnCh: make(chan struct{}, 1),
+// saslMechanism picks the SASL mechanism from the config and validates its prerequisites.
+// It returns "" when SASL is not configured.
+func saslMechanism(cfg config.IRCConfig) (string, error) {
+	switch {
+	case cfg.SASLExternal:
+		if !cfg.TLS || cfg.TLSClientCert == "" || cfg.TLSClientKey == "" {
+			return "", fmt.Errorf("irc: sasl_external requires tls with tls_client_cert and tls_client_key")
+		}
+		return sasl.External, nil
+	case cfg.SASLLogin != "" || cfg.SASLPass != "":
+		if cfg.SASLLogin == "" || cfg.SASLPass == "" {
+			return "", fmt.Errorf("irc: sasl_login and sasl_pass must both be set")
+		}
+		return sasl.Plain, nil
+	case cfg.SASLRequired:
+		return "", fmt.Errorf("irc: sasl_required is set but no SASL credentials are configured")
 	}
-	c.wireHandlers()
-	return c, nil
+	return "", nil
 }
 
 // wireHandlers sets up internal event handlers for the IRC client.
 func (c *Client) wireHandlers() {
+	// New connection attempt: forget SASL state from the previous one
+	c.conn.HandleFunc("register", func(_ *client.Conn, _ *client.Line) {
+		c.saslFailed.Store(false)
+		c.saslDone.Store(false)
+	})
+
 	// First connection established
 	c.conn.HandleFunc("connected", func(_ *client.Conn, _ *client.Line) {
-		logf(c.opts.Logger, "irc: connected (tls=%v)", c.cfg.TLS)
+		cfg := c.config()
+		if c.authRefused() {
+			// Don't join channels unauthenticated; saslFail sends QUIT
+			c.saslFail("registered without SASL authentication (no 903 from the server)")
+			return
+		}
+		logf(c.opts.Logger, "irc: connected (tls=%v)", cfg.TLS)
 
 		// NickServ identify (optional)
-		if s := strings.TrimSpace(c.cfg.IdentifyPass); s != "" {
+		if s := strings.TrimSpace(cfg.IdentifyPass); s != "" {
 			logf(c.opts.Logger, "irc: identifying with NickServ")
 			c.conn.Privmsg("NickServ", "IDENTIFY "+s)
 			// Don't block; we'll see a notice when accepted
 		}
 
+		metrics.IRCConnected.Set(1, c.network)
+		c.stateMu.Lock()
+		c.setRegisteredLocked(true)
+		c.joined = map[string]bool{}
+		c.resetProblemsLocked()
+		ready := c.readyLocked() // no channels configured
+		channels := append([]string(nil), c.channels...)
+		c.stateMu.Unlock()
+
 		// Join channels (with keys when available)
-		for _, ch := range c.cfg.Channels {
-			ch = ensureChanPrefix(ch)
-			if key := c.cfg.Keys[ch]; key != "" {
-				logf(c.opts.Logger, "irc: join %s (with key)", ch)
-				c.conn.Raw(fmt.Sprintf("JOIN %s %s", ch, key))
-			} else {
-				logf(c.opts.Logger, "irc: join %s", ch)
-				c.conn.Join(ch)
-			}
+		for _, ch := range channels {
+			c.sendJoin(ch)
 		}
 
 		select {
@@ -154,28 +297,45 @@ func (c *Client) wireHandlers() {
 		default:
 			close(c.ready)
 		}
+		c.queue.kick()
+		// Registered under another nick: try to get ours back
+		c.reclaimNick(true)
 		if c.handlers.Connected != nil {
 			c.handlers.Connected()
 		}
+		if ready && c.handlers.Ready != nil {
+			c.handlers.Ready()
+		}
 	})
 
 	// The c.conn.HandleFunc below are other event handlers that
 	// trigger the user-defined callbacks in c.handlers. This is
 	// where we map IRC events to our client's event system.
 
-	// Welcome numeric (001)
+	// Welcome numeric (001): registration is complete
 	c.conn.HandleFunc("001", func(_ *client.Conn, l *client.Line) {
+		if !c.authRefused() {
+			c.stateMu.Lock()
+			c.setRegisteredLocked(true)
+			c.stateMu.Unlock()
+		}
 		if c.handlers.Welcome != nil {
 			c.handlers.Welcome(strings.TrimSpace(l.Raw))
 		}
 	})
 
-	// Nick in use (433)
+	// Nick in use (433); goirc retries with nextNick
 	c.conn.HandleFunc("433", func(_ *client.Conn, l *client.Line) {
 		if c.handlers.NickInUse != nil {
 			c.handlers.NickInUse(l.Args)
 		}
 	})
+	c.wireNickHandlers()
+	c.wireChannelHandlers()
+	c.conn.HandleFunc("pong", c.pong)
+
+	// SASL negotiation results (no-ops while SASL is not configured)
+	c.wireSASLHandlers()
 
 	// Our join confirmations
 	c.conn.HandleFunc("join", func(conn *client.Conn, l *client.Line) {
@@ -184,9 +344,28 @@ func (c *Client) wireHandlers() {
 			if len(l.Args) > 0 {
 				ch = l.Args[0]
 			}
+			c.stateMu.Lock()
+			c.joined[strings.ToLower(ch)] = true
+			c.clearProblemLocked(strings.ToLower(ch))
+			ready := c.readyLocked()
+			c.stateMu.Unlock()
+			c.queue.kick()
+
 			if c.handlers.Joined != nil {
 				c.handlers.Joined(ch)
 			}
+			if ready && c.handlers.Ready != nil {
+				c.handlers.Ready()
+			}
+		}
+	})
+
+	// Our part confirmations
+	c.conn.HandleFunc("part", func(conn *client.Conn, l *client.Line) {
+		if l.Nick == conn.Me().Nick && len(l.Args) > 0 {
+			c.stateMu.Lock()
+			delete(c.joined, strings.ToLower(l.Args[0]))
+			c.stateMu.Unlock()
 		}
 	})
 
@@ -214,6 +393,21 @@ func (c *Client) wireHandlers() {
 	// Disconnected -> trigger reconnect
 	c.conn.HandleFunc("disconnected", func(_ *client.Conn, _ *client.Line) {
 		logf(c.opts.Logger, "irc: disconnected")
+		metrics.IRCConnected.Set(0, c.network)
+		c.stateMu.Lock()
+		c.setRegisteredLocked(false)
+		c.joined = map[string]bool{}
+		c.resetProblemsLocked()
+		c.pingToken = ""
+		c.stateMu.Unlock()
+		c.lag.Store(0)
+		select {
+		case c.gone <- struct{}{}:
+		default:
+		}
+		if c.closing() {
+			return // Shutdown or Close, no reconnect
+		}
 		if c.handlers.Disconnected != nil {
 			c.handlers.Disconnected()
 		}
@@ -224,28 +418,281 @@ func (c *Client) wireHandlers() {
 	})
 }
 
+// wireSASLHandlers reports the outcome of SASL negotiation. The exchange itself
+// (CAP REQ, AUTHENTICATE) is driven by goirc; we only watch the results.
+func (c *Client) wireSASLHandlers() {
+	// CAP LS may span several lines ("CAP * LS * :..."); the last has no "*".
+	var offered atomic.Bool
+	c.conn.HandleFunc("cap", func(_ *client.Conn, l *client.Line) {
+		if len(l.Args) < 3 || c.mechanism() == "" {
+			return
+		}
+		switch strings.ToUpper(l.Args[1]) {
+		case "LS":
+			for _, capab := range strings.Fields(l.Text()) {
+				if name, _, _ := strings.Cut(capab, "="); strings.EqualFold(name, "sasl") {
+					offered.Store(true)
+				}
+			}
+			if len(l.Args) > 3 && l.Args[2] == "*" {
+				return // more LS lines follow
+			}
+			if !offered.Swap(false) {
+				c.saslFail("server does not offer the sasl capability")
+			}
+		case "NAK":
+			c.saslFail("server rejected CAP REQ :" + l.Text())
+		}
+	})
+
+	// 900 RPL_LOGGEDIN
+	c.conn.HandleFunc("900", func(_ *client.Conn, l *client.Line) {
+		logf(c.opts.Logger, "irc: %s", l.Text())
+	})
+	// 903 RPL_SASLSUCCESS
+	c.conn.HandleFunc("903", func(_ *client.Conn, _ *client.Line) {
+		c.saslDone.Store(true)
+		logf(c.opts.Logger, "irc: SASL %s authentication successful", c.mechanism())
+	})
+	// 902 ERR_NICKLOCKED, 904 ERR_SASLFAIL, 905 ERR_SASLTOOLONG, 906 ERR_SASLABORTED
+	for _, num := range []string{"902", "904", "905", "906"} {
+		c.conn.HandleFunc(num, func(_ *client.Conn, l *client.Line) {
+			c.saslFail(fmt.Sprintf("%s %s", num, l.Text()))
+		})
+	}
+	// 908 RPL_SASLMECHS: the mechanism we asked for is not supported
+	c.conn.HandleFunc("908", func(_ *client.Conn, l *client.Line) {
+		mechs := ""
+		if len(l.Args) > 1 {
+			mechs = l.Args[1]
+		}
+		c.saslFail(fmt.Sprintf("mechanism %s not supported (server offers: %s)", c.mechanism(), mechs))
+	})
+}
+
+// authRefused reports whether the current connection must not be used:
+// sasl_required is set and authentication did not succeed (903) on it.
+func (c *Client) authRefused() bool {
+	return c.mechanism() != "" && c.config().SASLRequired && !c.saslDone.Load()
+}
+
+// saslFail reports a SASL failure. With sasl_required the connection is aborted,
+// otherwise registration continues unauthenticated.
+func (c *Client) saslFail(reason string) {
+	mech := c.mechanism()
+	if mech == "" {
+		return // SASL not configured
+	}
+	if c.saslFailed.Swap(true) {
+		return // already reported for this connection
+	}
+	msg := fmt.Sprintf("sasl %s failed: %s", mech, reason)
+	logf(c.opts.Logger, "irc: %s", msg)
+	if c.handlers.Error != nil {
+		c.handlers.Error(msg)
+	}
+	if !c.config().SASLRequired {
+		logf(c.opts.Logger, "irc: continuing without SASL (sasl_required=false)")
+		return
+	}
+	c.conn.Quit("SASL authentication failed")
+	select {
+	case c.authErr <- fmt.Errorf("irc: %s", msg):
+	default:
+	}
+}
+
 // Start connects and starts an auto-reconnect loop.
 // It returns after the first successful connection or ctx timeout.
 func (c *Client) Start(ctx context.Context) error {
-	// Reconnect worker
+	// Reconnect, send queue, nick reclaim and keepalive workers
 	go c.reconnector()
+	go c.queue.run()
+	go c.reclaimer()
+	go c.keepalive()
 
 	// Initial connect
-	if err := c.conn.ConnectTo(c.cfg.Server); err != nil {
+	if err := c.conn.ConnectTo(c.applyPending()); err != nil {
 		return err
 	}
 
 	select {
 	case <-c.ready:
 		return nil
+	case err := <-c.authErr:
+		// QUIT is on its way; let the server close the connection
+		c.stopWorkers()
+		select {
+		case <-c.gone:
+		case <-ctx.Done():
+		}
+		c.Close()
+		return err
 	case <-ctx.Done():
 		return ctx.Err()
 	}
 }
 
+// Run is like Start but connects in the background: it returns at once, and
+// when the first connection attempt fails the client keeps retrying with
+// backoff until Close. A failed SASL authentication with sasl_required is
+// retried the same way.
+func (c *Client) Run() {
+	go c.reconnector()
+	go c.queue.run()
+	go c.reclaimer()
+	go c.keepalive()
+	go func() {
+		if c.closing() {
+			return
+		}
+		if err := c.conn.ConnectTo(c.applyPending()); err != nil {
+			logf(c.opts.Logger, "irc: connect failed: %v", err)
+			metrics.IRCReconnects.Inc(c.network, "failure")
+			select {
+			case c.reconnCh <- struct{}{}:
+			default:
+			}
+		}
+	}()
+}
+
+// Ready reports whether the client is registered and has joined the
+// configured channels. A channel it was kicked from or could not join (see
+// Status) does not count: messages for it are held in the queue (see
+// sendQueue.holdLocked), and holding back the others until it is joined
+// again would stall them too.
+func (c *Client) Ready() bool {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+	return c.readyLocked()
+}
+
+func (c *Client) readyLocked() bool {
+	if !c.registered {
+		return false
+	}
+	for _, ch := range c.channels {
+		lc := strings.ToLower(ch)
+		if !c.joined[lc] && c.problems[lc] == nil {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *Client) hasChannelLocked(lc string) bool {
+	for _, ch := range c.channels {
+		if strings.ToLower(ch) == lc {
+			return true
+		}
+	}
+	return false
+}
+
+// canSend reports whether the send queue may write to channel: the client must
+// be registered, and configured channels must have been joined.
+func (c *Client) canSend(channel string) bool {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+	if !c.registered {
+		return false
+	}
+	key := strings.ToLower(channel)
+	return c.joined[key] || !c.hasChannelLocked(key)
+}
+
+// heldBack reports whether channel is not joined because of a kick or join
+// error on the current connection, and whether it is rejoined later.
+func (c *Client) heldBack(channel string) (held, retried bool) {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+	p := c.problems[strings.ToLower(channel)]
+	if p == nil {
+		return false, false
+	}
+	return true, p.retry
+}
+
+// QueueStats returns a snapshot of the outbound send queue.
+func (c *Client) QueueStats() QueueStats {
+	return c.queue.stats()
+}
+
+// Flush waits until all queued messages have been written to the connection or ctx is done.
+func (c *Client) Flush(ctx context.Context) error {
+	return c.queue.flush(ctx)
+}
+
+// Channels returns the channels the client keeps joined.
+func (c *Client) Channels() []string {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+	return append([]string(nil), c.channels...)
+}
+
+// Join adds channel to the channel list and joins it now if connected.
+// It is rejoined after reconnects until Part is called. An empty key keeps
+// the configured one, if any.
+func (c *Client) Join(channel, key string) {
+	ch := ensureChanPrefix(channel)
+	lc := strings.ToLower(ch)
+	c.stateMu.Lock()
+	if !c.hasChannelLocked(lc) {
+		c.channels = append(c.channels, ch)
+	}
+	if key != "" {
+		c.keys[lc] = key
+	}
+	registered := c.registered
+	c.stateMu.Unlock()
+	if registered {
+		c.sendJoin(ch)
+	}
+}
+
+// Part removes channel from the channel list and leaves it if connected.
+// It reports whether the channel was in the list.
+func (c *Client) Part(channel, reason string) bool {
+	ch := ensureChanPrefix(channel)
+	lc := strings.ToLower(ch)
+	c.stateMu.Lock()
+	found := false
+	for i, name := range c.channels {
+		if strings.ToLower(name) == lc {
+			c.channels = append(c.channels[:i:i], c.channels[i+1:]...)
+			found = true
+			break
+		}
+	}
+	c.clearProblemLocked(lc)
+	registered := c.registered
+	c.stateMu.Unlock()
+	if registered {
+		logf(c.opts.Logger, "irc: part %s", ch)
+		c.conn.Part(ch, reason)
+	}
+	c.queue.kick()
+	return found
+}
+
+// sendJoin sends JOIN for ch, with its key when one is set.
+func (c *Client) sendJoin(ch string) {
+	c.stateMu.Lock()
+	key := c.keys[strings.ToLower(ch)]
+	c.stateMu.Unlock()
+	if key != "" {
+		logf(c.opts.Logger, "irc: join %s (with key)", ch)
+		c.conn.Raw(fmt.Sprintf("JOIN %s %s", ch, key))
+	} else {
+		logf(c.opts.Logger, "irc: join %s", ch)
+		c.conn.Join(ch)
+	}
+}
+
 // Broadcast sends msg to all configured channels.
 func (c *Client) Broadcast(msg string) {
-	for _, ch := range c.cfg.Channels {
+	for _, ch := range c.Channels() {
 		c.sendPrepared([]string{ch}, msg)
 	}
 }
@@ -254,21 +701,45 @@ func (c *Client) SendTo(channels []string, msg string) {
 	c.sendPrepared(channels, msg)
 }
 
-// sendPrepared applies length policy (split/truncate) then sends each segment.
+// SendWait queues msg like SendTo (Broadcast when channels is nil), but
+// waits for room in the send queue instead of applying irc.queue.overflow,
+// for callers that must not lose messages such as the spool. It fails when
+// ctx is done or the client is closed; then nothing was queued.
+func (c *Client) SendWait(ctx context.Context, channels []string, msg string) error {
+	if channels == nil {
+		channels = c.Channels()
+	}
+	return c.queue.pushWait(ctx, c.prepare(channels, msg))
+}
+
+// sendPrepared queues msg for channels, see prepare.
 func (c *Client) sendPrepared(channels []string, msg string) {
+	for _, m := range c.prepare(channels, msg) {
+		c.queue.push(m)
+	}
+}
+
+// prepare adapts the formatting to what the network supports and applies
+// the length policy (split/truncate). It returns the segments to queue.
+func (c *Client) prepare(channels []string, msg string) []outMsg {
+	for _, ch := range channels {
+		c.warnNotJoined(ch)
+	}
+	msg = ircfmt.Degrade(msg, c.config().Formatting)
+	var out []outMsg
 	segs := c.segmentMessage(msg)
-	for _, seg := range segs {
+	for i, seg := range segs {
 		for _, ch := range channels {
-			if c.conn != nil {
-				c.conn.Privmsg(ch, seg)
-			}
+			out = append(out, outMsg{channel: ch, text: seg, last: i == len(segs)-1})
 		}
 	}
+	return out
 }
 
 // segmentMessage returns message segments according to MaxMessageLen/SplitLong.
 func (c *Client) segmentMessage(msg string) []string {
-	limit := c.cfg.MaxMessageLen
+	cfg := c.config()
+	limit := cfg.MaxMessageLen
 	// If no limit, return original message
 	if limit <= 0 {
 		return []string{msg}
@@ -282,7 +753,7 @@ func (c *Client) segmentMessage(msg string) []string {
 	}
 
 	// I SplitLong is false, truncate with "..." if possible
-	if !c.cfg.SplitLong {
+	if !cfg.SplitLong {
 		// Check if we can append "..."
 		if limit > 3 {
 			return []string{string(runes[:limit-3]) + "..."}
@@ -330,14 +801,75 @@ func (c *Client) Quit(reason string) {
 	c.conn.Quit(reason)
 }
 
-// Close stops reconnect attempts (does not forcibly close the socket).
+// Close stops the client at once: the reconnector and the other workers
+// stop, the connection is closed without QUIT and messages still queued are
+// discarded. Use Shutdown to leave cleanly.
 func (c *Client) Close() {
+	c.stopWorkers()
+	c.queue.close()
+	_ = c.conn.Close()
+	dropDialer(c.dialID)
+}
+
+// Shutdown leaves the network cleanly: it sends the queued messages, quits
+// with irc.quit_message and waits for the server to close the connection,
+// then stops the client like Close. Whatever is left when ctx is done is
+// cut short; the error then tells what was lost.
+func (c *Client) Shutdown(ctx context.Context) error {
+	var errs []error
+	if c.conn.Connected() {
+		if err := c.queue.flush(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("irc: %d queued messages not sent: %w", c.queue.stats().Depth, err))
+		}
+	} else if n := c.queue.stats().Depth; n > 0 {
+		errs = append(errs, fmt.Errorf("irc: %d queued messages not sent: not connected", n))
+	}
+	c.stopWorkers()
+
+	if c.conn.Connected() {
+		select {
+		case <-c.gone: // an earlier connection
+		default:
+		}
+		msg := c.config().QuitMessage
+		if msg == "" {
+			msg = defaultQuitMessage
+		}
+		c.conn.Quit(msg)
+		select {
+		case <-c.gone:
+		case <-ctx.Done():
+			errs = append(errs, fmt.Errorf("irc: server did not close the connection: %w", ctx.Err()))
+		}
+	}
+	c.queue.close()
+	_ = c.conn.Close()
+	dropDialer(c.dialID)
+	return errors.Join(errs...)
+}
+
+// stopWorkers stops the reconnector, the nick reclaimer, the keepalive and
+// pending rejoins.
+func (c *Client) stopWorkers() {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
 	select {
 	case <-c.stop:
 		// already closed
 	default:
 		close(c.stop)
 	}
+	c.resetProblemsLocked()
+}
+
+// closing reports whether Shutdown or Close was called.
+func (c *Client) closing() bool {
+	select {
+	case <-c.stop:
+		return true
+	default:
+		return false
+	}
 }
 
 // reconnector handles automatic reconnections with exponential backoff.
@@ -357,9 +889,16 @@ func (c *Client) reconnector() {
 				default:
 				}
 				logf(c.opts.Logger, "irc: reconnecting in %s ...", backoff)
-				time.Sleep(backoff)
-				if err := c.conn.ConnectTo(c.cfg.Server); err != nil {
+				t := time.NewTimer(backoff)
+				select {
+				case <-c.stop:
+					t.Stop()
+					return
+				case <-t.C:
+				}
+				if err := c.conn.ConnectTo(c.applyPending()); err != nil {
 					logf(c.opts.Logger, "irc: reconnect failed: %v", err)
+					metrics.IRCReconnects.Inc(c.network, "failure")
 					if backoff < max {
 						backoff *= 2
 						if backoff > max {
@@ -369,6 +908,7 @@ func (c *Client) reconnector() {
 					continue
 				}
 				logf(c.opts.Logger, "irc: reconnect initiated")
+				metrics.IRCReconnects.Inc(c.network, "success")
 				backoff = 1 * time.Second
 				break
 			}
@@ -0,0 +1,212 @@
+package irc_test
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bitcanon/ircpush/pkg/config"
+	"github.com/bitcanon/ircpush/pkg/irc"
+	"github.com/bitcanon/ircpush/pkg/spool"
+)
+
+/*
+Channel state against a fake server:
+1. #a: the bot is kicked after joining and rejoins after rejoin.kick_delay.
+2. #b: JOIN is answered with 471 (full); the channel reports the error and a retry time.
+3. #c: JOIN is answered with 473 (invite-only); an INVITE from an untrusted
+   source is ignored, one matching rejoin.invite_from makes the bot join.
+4. A channel the bot is kicked from or cannot join does not hold back
+   readiness, so the spool keeps sending to the others directly.
+*/
+
+// fakeChanServer answers JOINs as described above and records the client's lines.
+type fakeChanServer struct {
+	ln net.Listener
+
+	mu    sync.Mutex
+	got   []string
+	conn  net.Conn
+	joins map[string]int
+}
+
+func startFakeChanServer(t *testing.T) *fakeChanServer {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	s := &fakeChanServer{ln: ln, joins: map[string]int{}}
+	go s.acceptOne()
+	return s
+}
+
+func (s *fakeChanServer) addr() string { return s.ln.Addr().String() }
+func (s *fakeChanServer) close()       { _ = s.ln.Close() }
+
+func (s *fakeChanServer) acceptOne() {
+	conn, err := s.ln.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	s.mu.Lock()
+	s.conn = conn
+	s.mu.Unlock()
+	br := bufio.NewReader(conn)
+
+	var nickSeen, userSeen, welcomed bool
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		s.mu.Lock()
+		s.got = append(s.got, line)
+		s.mu.Unlock()
+
+		switch {
+		case strings.HasPrefix(line, "NICK "):
+			nickSeen = true
+		case strings.HasPrefix(line, "USER "):
+			userSeen = true
+		case strings.HasPrefix(line, "JOIN "):
+			ch := strings.TrimSpace(line[5:])
+			s.mu.Lock()
+			s.joins[ch]++
+			n := s.joins[ch]
+			s.mu.Unlock()
+			switch {
+			case ch == "#b":
+				writeLine(conn, ":irc.local 471 ircbot #b :Cannot join channel (+l)")
+			case ch == "#c" && n == 1:
+				writeLine(conn, ":irc.local 473 ircbot #c :Cannot join channel (+i)")
+				writeLine(conn, ":eve!e@evil.example INVITE ircbot :#c")
+				writeLine(conn, ":ChanServ!ChanServ@services.example INVITE ircbot :#c")
+			default:
+				writeLine(conn, ":ircbot!u@h JOIN "+ch)
+				if ch == "#a" && n == 1 {
+					writeLine(conn, ":op!o@h KICK #a ircbot :go away")
+				}
+			}
+		case strings.HasPrefix(line, "QUIT"):
+			return
+		}
+		if nickSeen && userSeen && !welcomed {
+			welcomed = true
+			writeLine(conn, ":irc.local 001 ircbot :Welcome")
+		}
+	}
+}
+
+func (s *fakeChanServer) joinCount(ch string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.joins[ch]
+}
+
+// TestChannelRecovery verifies rejoin after KICK, join errors and invites.
+func TestChannelRecovery(t *testing.T) {
+	s := startFakeChanServer(t)
+	defer s.close()
+
+	cli, err := irc.New(config.IRCConfig{
+		Server:   s.addr(),
+		Nick:     "ircbot",
+		Channels: []string{"#a", "#b", "#c"},
+		Rejoin: config.RejoinConfig{
+			KickDelay:  50 * time.Millisecond,
+			Retry:      time.Hour,
+			InviteFrom: []string{"ChanServ!*@services.*"},
+		},
+	}, irc.Handlers{}, irc.Options{Logger: io.Discard})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	defer cli.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := cli.Start(ctx); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+
+	waitFor(t, 3*time.Second, func() bool { return s.joinCount("#a") == 2 && cli.Joined("#a") }, "rejoin #a after kick", nil)
+	waitFor(t, 3*time.Second, func() bool { return s.joinCount("#c") == 2 && cli.Joined("#c") }, "join #c after invite", nil)
+	if n := s.joinCount("#c"); n != 2 {
+		t.Errorf("expected 2 joins of #c (the untrusted invite ignored), but got %d", n)
+	}
+
+	st := cli.Status()
+	if !st.Ready {
+		t.Errorf("expected ready although #b could not be joined")
+	}
+	b := st.Channels[1]
+	if b.Joined || b.Error != "channel is full (471)" {
+		t.Errorf("unexpected state of #b: %+v", b)
+	}
+	if wait := time.Until(b.RetryAt); wait < 59*time.Minute || wait > time.Hour {
+		t.Errorf("expected a retry of #b in about an hour, but got %s", wait)
+	}
+	if cli.Joined("#b") {
+		t.Errorf("expected #b not to be joined")
+	}
+}
+
+// TestKickKeepsOthersDirect verifies that after a kick from #a, traffic for
+// the other channels is still sent directly instead of being spooled.
+func TestKickKeepsOthersDirect(t *testing.T) {
+	s := startFakeChanServer(t)
+	defer s.close()
+
+	cli, err := irc.New(config.IRCConfig{
+		Server:   s.addr(),
+		Nick:     "ircbot",
+		Channels: []string{"#a", "#d"},
+		Rejoin:   config.RejoinConfig{KickDelay: time.Hour},
+	}, irc.Handlers{}, irc.Options{Logger: io.Discard})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	defer cli.Close()
+	sp, err := spool.Open(t.TempDir(), cli, spool.Options{Logger: log.New(io.Discard, "", 0)})
+	if err != nil {
+		t.Fatalf("spool: %v", err)
+	}
+	defer sp.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := cli.Start(ctx); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	waitFor(t, 3*time.Second, func() bool {
+		st := cli.Status()
+		return cli.Joined("#d") && strings.HasPrefix(st.Channels[0].Error, "kicked by op")
+	}, "kick from #a", nil)
+
+	if !cli.Ready() {
+		t.Errorf("expected ready after the kick from #a")
+	}
+	sp.SendTo([]string{"#d"}, "still direct")
+	if n := sp.Pending(); n != 0 {
+		t.Errorf("expected nothing spooled, but %d bytes are", n)
+	}
+	waitFor(t, 3*time.Second, func() bool {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		for _, l := range s.got {
+			if l == "PRIVMSG #d :still direct" {
+				return true
+			}
+		}
+		return false
+	}, "PRIVMSG #d", nil)
+}
@@ -3,10 +3,13 @@ package irc_test
 import (
 	"bufio"
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"net"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -23,6 +26,10 @@ What is being verified:
 3. Client responds to server PING with PONG.
 4. Broadcast() sends a PRIVMSG to each configured channel.
 5. SendTo() sends a targeted PRIVMSG.
+6. Status() reports registration, join state and the last send.
+7. Join() and Part() change the channel list at runtime.
+8. Shutdown() sends the queued messages, then QUIT, and waits for the server
+   to close the connection, up to its deadline.
 No external IRC daemon required; everything runs locally & fast.
 */
 
@@ -32,6 +39,8 @@ type fakeServer struct {
 	ln  net.Listener
 	mu  sync.Mutex
 	got []string // raw lines received from client (without trailing CRLF)
+
+	ignoreQuit atomic.Bool // keep the connection open on QUIT
 }
 
 // startFakeServer starts a TCP listener on 127.0.0.1 and begins accepting one client.
@@ -113,6 +122,7 @@ func (s *fakeServer) acceptOne() {
 	}
 
 	// Disable deadline; continue recording any PRIVMSG/PONG lines.
+	// Like a real server, confirm JOIN/PART and close the connection on QUIT.
 	_ = conn.SetReadDeadline(time.Time{})
 	for {
 		line, err := br.ReadString('\n')
@@ -120,6 +130,12 @@ func (s *fakeServer) acceptOne() {
 			return
 		}
 		s.record(line)
+		switch f := strings.Fields(line); {
+		case len(f) > 1 && (f[0] == "JOIN" || f[0] == "PART"):
+			writeLine(conn, fmt.Sprintf(":ircbot!u@h %s %s", f[0], f[1]))
+		case len(f) > 0 && f[0] == "QUIT" && !s.ignoreQuit.Load():
+			return
+		}
 	}
 }
 
@@ -178,7 +194,7 @@ func TestIRCHandshakeAndBroadcast(t *testing.T) {
 
 	cli, err := irc.New(cfg, irc.Handlers{
 		Error: func(text string) { t.Logf("irc error: %s", text) },
-	}, irc.Options{DisableFlood: true})
+	}, irc.Options{})
 	if err != nil {
 		t.Fatalf("irc.New: %v", err)
 	}
@@ -196,6 +212,12 @@ func TestIRCHandshakeAndBroadcast(t *testing.T) {
 	waitFor(t, 3*time.Second, func() bool { return s.seen("USER ") }, "USER", nil)
 	waitFor(t, 3*time.Second, func() bool { return s.seen("JOIN #test") }, "JOIN #test", nil)
 
+	// The echoed JOIN makes the client ready; nothing has been sent yet.
+	waitFor(t, 3*time.Second, cli.Ready, "ready", nil)
+	if st := cli.Status(); !st.Registered || len(st.Channels) != 1 || !st.Channels[0].Joined || !st.LastSend.IsZero() {
+		t.Fatalf("unexpected status after join: %+v", st)
+	}
+
 	// Broadcast should send a PRIVMSG to #test.
 	cli.Broadcast("hello world")
 	waitFor(t, 3*time.Second, func() bool { return s.seen("PRIVMSG #test :hello world") },
@@ -203,6 +225,10 @@ func TestIRCHandshakeAndBroadcast(t *testing.T) {
 		func() { t.Logf("got lines (broadcast): %#v", s.got) },
 	)
 
+	if cli.Status().LastSend.IsZero() {
+		t.Errorf("expected LastSend to be set after broadcast")
+	}
+
 	// Targeted SendTo should send another PRIVMSG.
 	time.Sleep(100 * time.Millisecond) // small delay to avoid batching issues
 	cli.SendTo([]string{"#test"}, "targeted")
@@ -210,4 +236,97 @@ func TestIRCHandshakeAndBroadcast(t *testing.T) {
 		"PRIVMSG #test :targeted",
 		func() { t.Logf("got lines (sendto): %#v", s.got) },
 	)
+
+	// Runtime join/part are sent right away and tracked in the channel list.
+	cli.Join("extra", "")
+	waitFor(t, 3*time.Second, func() bool { return s.seen("JOIN #extra") }, "JOIN #extra", nil)
+	if chans := cli.Channels(); len(chans) != 2 || chans[1] != "#extra" {
+		t.Errorf("unexpected channels after Join: %v", chans)
+	}
+	if !cli.Part("#extra", "bye") || cli.Part("#extra", "bye") {
+		t.Errorf("expected Part to succeed exactly once")
+	}
+	waitFor(t, 3*time.Second, func() bool { return s.seen("PART #extra") }, "PART #extra", nil)
+}
+
+// startReady connects a client with cfg to s and waits until it has joined.
+func startReady(t *testing.T, s *fakeServer, cfg config.IRCConfig) *irc.Client {
+	t.Helper()
+	cfg.Server, cfg.Nick = s.addr(), "ircbot"
+	cli, err := irc.New(cfg, irc.Handlers{}, irc.Options{Logger: io.Discard})
+	if err != nil {
+		t.Fatalf("irc.New: %v", err)
+	}
+	t.Cleanup(cli.Close)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := cli.Start(ctx); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	waitFor(t, 3*time.Second, cli.Ready, "ready", nil)
+	return cli
+}
+
+// TestShutdown verifies that queued messages go out before QUIT and that
+// Shutdown returns once the server has closed the connection.
+func TestShutdown(t *testing.T) {
+	s := startFakeServer(t)
+	defer s.close()
+	cli := startReady(t, s, config.IRCConfig{
+		Channels:    []string{"#test"},
+		QuitMessage: "bye",
+		Queue:       config.QueueConfig{Rate: 20, Burst: 1}, // 5 messages take 200ms
+	})
+
+	for i := range 5 {
+		cli.SendTo([]string{"#test"}, fmt.Sprintf("msg %d", i))
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if err := cli.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+
+	s.mu.Lock()
+	got := append([]string(nil), s.got...)
+	s.mu.Unlock()
+	var tail []string
+	for _, l := range got {
+		if strings.HasPrefix(l, "PRIVMSG ") || strings.HasPrefix(l, "QUIT") {
+			tail = append(tail, l)
+		}
+	}
+	expected := []string{
+		"PRIVMSG #test :msg 0", "PRIVMSG #test :msg 1", "PRIVMSG #test :msg 2",
+		"PRIVMSG #test :msg 3", "PRIVMSG #test :msg 4", "QUIT :bye",
+	}
+	if strings.Join(tail, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected %q, but got %q", expected, tail)
+	}
+	if st := cli.Status(); st.Registered {
+		t.Errorf("expected the client to be disconnected after Shutdown")
+	}
+}
+
+// TestShutdownDeadline verifies that Shutdown gives up on a server that keeps
+// the connection open after QUIT.
+func TestShutdownDeadline(t *testing.T) {
+	s := startFakeServer(t)
+	defer s.close()
+	s.ignoreQuit.Store(true)
+	cli := startReady(t, s, config.IRCConfig{Channels: []string{"#test"}})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := cli.Shutdown(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected a deadline error, but got %v", err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("expected Shutdown to return at the deadline, but it took %s", d)
+	}
+	if !s.seen("QUIT :shutdown") {
+		t.Errorf("expected QUIT with the default message")
+	}
 }
@@ -0,0 +1,97 @@
+package irc_test
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bitcanon/ircpush/pkg/config"
+	"github.com/bitcanon/ircpush/pkg/irc"
+)
+
+/*
+Client-side keepalive against a fake server:
+1. The first connection answers the client's PINGs; the lag is reported.
+2. Then the server goes silent without closing the socket (half-open);
+   the client gives up after ping_timeout and connects again.
+*/
+
+// startSilentServer accepts connections; the first one answers only the
+// first PING, every later one answers all of them.
+func startSilentServer(t *testing.T) (addr string, conns *atomic.Int32) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+	conns = &atomic.Int32{}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			n := conns.Add(1)
+			go func() {
+				defer conn.Close()
+				br := bufio.NewReader(conn)
+				nick := false
+				pings := 0
+				for {
+					line, err := br.ReadString('\n')
+					if err != nil {
+						return
+					}
+					line = strings.TrimRight(line, "\r\n")
+					switch {
+					case strings.HasPrefix(line, "NICK "):
+						nick = true
+					case strings.HasPrefix(line, "USER "):
+						if nick {
+							writeLine(conn, ":irc.local 001 ircbot :Welcome")
+						}
+					case strings.HasPrefix(line, "PING :"):
+						if pings++; n > 1 || pings == 1 {
+							writeLine(conn, ":irc.local PONG irc.local :"+strings.TrimPrefix(line, "PING :"))
+						}
+					}
+				}
+			}()
+		}
+	}()
+	return ln.Addr().String(), conns
+}
+
+// TestKeepalive measures lag and reconnects when PONGs stop.
+func TestKeepalive(t *testing.T) {
+	addr, conns := startSilentServer(t)
+
+	var lags atomic.Int32
+	cli, err := irc.New(config.IRCConfig{
+		Server:       addr,
+		Nick:         "ircbot",
+		PingInterval: 50 * time.Millisecond,
+		PingTimeout:  100 * time.Millisecond,
+	}, irc.Handlers{Lag: func(time.Duration) { lags.Add(1) }}, irc.Options{Logger: io.Discard})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	defer cli.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := cli.Start(ctx); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+
+	waitFor(t, 2*time.Second, func() bool { return lags.Load() >= 1 }, "lag from the first PONG", nil)
+	// The silent connection is closed and the reconnector (1s backoff) connects again
+	waitFor(t, 4*time.Second, func() bool { return conns.Load() == 2 && cli.Status().Registered }, "reconnect after ping timeout", nil)
+	waitFor(t, 2*time.Second, func() bool { return cli.Lag() > 0 }, "lag on the new connection", nil)
+}
@@ -0,0 +1,191 @@
+package irc_test
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bitcanon/ircpush/pkg/config"
+	"github.com/bitcanon/ircpush/pkg/irc"
+)
+
+/*
+Nick collision recovery against a fake server where the configured nick is
+held by a ghost session:
+1. 433 for the nick and 432 for the first alt nick move on to the next candidate.
+2. After registering, the client asks NickServ to release the nick.
+3. When the holder quits, the client takes the nick back and identifies.
+4. When every candidate is in use, the client quits once and asks for no
+   other nick.
+*/
+
+// fakeNickServer accepts "ircbot_" only, until the ghost holding "ircbot" quits.
+type fakeNickServer struct {
+	ln       net.Listener
+	allTaken atomic.Bool // answer 433 to every nick and keep reading after QUIT
+
+	mu  sync.Mutex
+	got []string
+}
+
+func startFakeNickServer(t *testing.T) *fakeNickServer {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	s := &fakeNickServer{ln: ln}
+	go s.acceptOne()
+	return s
+}
+
+func (s *fakeNickServer) addr() string { return s.ln.Addr().String() }
+func (s *fakeNickServer) close()       { _ = s.ln.Close() }
+
+func (s *fakeNickServer) acceptOne() {
+	conn, err := s.ln.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	br := bufio.NewReader(conn)
+	_ = conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+
+	nick, user, ghost, welcomed := "", false, true, false
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		s.record(line)
+
+		switch {
+		case strings.HasPrefix(line, "NICK "):
+			want := strings.TrimPrefix(line, "NICK ")
+			switch {
+			case want == "":
+				writeLine(conn, ":irc.local 431 * :No nickname given")
+			case s.allTaken.Load():
+				writeLine(conn, ":irc.local 433 * "+want+" :Nickname is already in use")
+			case want == "ircbot" && ghost:
+				writeLine(conn, ":irc.local 433 * ircbot :Nickname is already in use")
+			case want == "bad-alt":
+				writeLine(conn, ":irc.local 432 * bad-alt :Erroneous nickname")
+			case welcomed:
+				writeLine(conn, ":"+nick+"!u@h NICK :"+want)
+				nick = want
+			default:
+				nick = want
+			}
+		case strings.HasPrefix(line, "USER "):
+			user = true
+		case strings.HasPrefix(line, "PRIVMSG NickServ :REGAIN"):
+			// Services kill the ghost; we see it quit
+			ghost = false
+			writeLine(conn, ":ircbot!ghost@h QUIT :Killed (GHOST command used)")
+		case strings.HasPrefix(line, "QUIT") && s.allTaken.Load():
+			_ = conn.SetReadDeadline(time.Now().Add(300 * time.Millisecond))
+		case strings.HasPrefix(line, "QUIT"):
+			return
+		}
+		if nick != "" && user && !welcomed {
+			welcomed = true
+			writeLine(conn, ":irc.local 001 "+nick+" :Welcome")
+		}
+	}
+}
+
+func (s *fakeNickServer) record(line string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.got = append(s.got, line)
+}
+
+func (s *fakeNickServer) count(line string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for _, l := range s.got {
+		if l == line {
+			n++
+		}
+	}
+	return n
+}
+
+// TestNickCollisionRecovery registers under a fallback nick and reclaims the configured one.
+func TestNickCollisionRecovery(t *testing.T) {
+	s := startFakeNickServer(t)
+	defer s.close()
+
+	cli, err := irc.New(config.IRCConfig{
+		Server:       s.addr(),
+		Nick:         "ircbot",
+		AltNicks:     []string{"bad-alt"},
+		IdentifyPass: "secret",
+	}, irc.Handlers{}, irc.Options{Logger: io.Discard})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	defer cli.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := cli.Start(ctx); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+
+	waitFor(t, 5*time.Second, func() bool {
+		st := cli.Status()
+		return st.Registered && st.Nick == "ircbot"
+	}, "nick reclaimed", func() {
+		t.Logf("status: %+v", cli.Status())
+	})
+	for _, want := range []string{"NICK ircbot", "NICK bad-alt", "NICK ircbot_", "PRIVMSG NickServ :REGAIN ircbot secret"} {
+		if s.count(want) == 0 {
+			t.Errorf("expected the client to send %q", want)
+		}
+	}
+	// Identified once after registering and again with the reclaimed nick
+	waitFor(t, 2*time.Second, func() bool { return s.count("PRIVMSG NickServ :IDENTIFY secret") == 2 }, "identify after reclaim", nil)
+}
+
+// TestNickAllTaken quits once when every candidate nick is in use.
+func TestNickAllTaken(t *testing.T) {
+	s := startFakeNickServer(t)
+	defer s.close()
+	s.allTaken.Store(true)
+
+	cli, err := irc.New(config.IRCConfig{
+		Server:   s.addr(),
+		Nick:     "ircbot",
+		AltNicks: []string{"ircbot-alt"},
+	}, irc.Handlers{}, irc.Options{Logger: io.Discard})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	defer cli.Close()
+	cli.Run() // never registers
+
+	waitFor(t, 5*time.Second, func() bool { return s.count("QUIT :No usable nick") > 0 }, "QUIT", func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		t.Logf("server saw: %q", s.got)
+	})
+	time.Sleep(300 * time.Millisecond)
+	if n := s.count("QUIT :No usable nick"); n != 1 {
+		t.Errorf("expected a single QUIT, but got %d", n)
+	}
+	for _, nick := range []string{"ircbot", "ircbot-alt", "ircbot_________"} {
+		if n := s.count("NICK " + nick); n != 1 {
+			t.Errorf("expected NICK %s once, but got %d", nick, n)
+		}
+	}
+}
@@ -0,0 +1,339 @@
+package irc_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bitcanon/ircpush/pkg/config"
+	"github.com/bitcanon/ircpush/pkg/irc"
+)
+
+/*
+SASL integration test using an in-process fake IRC server.
+
+Verifies:
+1. Client requests the sasl capability after CAP LS.
+2. Client authenticates with AUTHENTICATE PLAIN and base64 credentials.
+3. On success (903) registration completes and channels are joined.
+4. On failure (904) with sasl_required, Start returns an error and no JOIN is sent.
+5. On failure (904) without sasl_required, the client continues and joins.
+6. With sasl_required, a server that registers the client without SASL
+   (no CAP support) is left before any JOIN.
+7. sasl offered on a later line of a multi-line CAP LS reply is requested.
+*/
+
+// fakeSASLServer accepts one client and answers CAP/AUTHENTICATE like an IRCv3 server.
+type fakeSASLServer struct {
+	ln   net.Listener
+	user string
+	pass string
+
+	mu  sync.Mutex
+	got []string
+
+	noCap   atomic.Bool // answer CAP with 421 and register on NICK/USER alone
+	multiLS atomic.Bool // answer CAP LS in two lines, sasl on the second
+}
+
+func startFakeSASLServer(t *testing.T, user, pass string) *fakeSASLServer {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	s := &fakeSASLServer{ln: ln, user: user, pass: pass}
+	go s.acceptOne()
+	return s
+}
+
+func (s *fakeSASLServer) addr() string { return s.ln.Addr().String() }
+func (s *fakeSASLServer) close()       { _ = s.ln.Close() }
+
+func (s *fakeSASLServer) acceptOne() {
+	conn, err := s.ln.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	br := bufio.NewReader(conn)
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	var capEnd, nickSeen, userSeen, welcomed bool
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		s.record(line)
+
+		switch {
+		case strings.HasPrefix(line, "CAP ") && s.noCap.Load():
+			writeLine(conn, ":irc.local 421 * CAP :Unknown command")
+			capEnd = true
+		case strings.HasPrefix(line, "CAP LS") && s.multiLS.Load():
+			writeLine(conn, ":irc.local CAP * LS * :multi-prefix away-notify")
+			writeLine(conn, ":irc.local CAP * LS :sasl=PLAIN,EXTERNAL")
+		case strings.HasPrefix(line, "CAP LS"):
+			writeLine(conn, ":irc.local CAP * LS :multi-prefix sasl=PLAIN,EXTERNAL")
+		case strings.HasPrefix(line, "CAP REQ"):
+			writeLine(conn, ":irc.local CAP * ACK :"+strings.TrimPrefix(line[len("CAP REQ "):], ":"))
+		case line == "AUTHENTICATE PLAIN":
+			writeLine(conn, "AUTHENTICATE +")
+		case strings.HasPrefix(line, "AUTHENTICATE "):
+			raw, _ := base64.StdEncoding.DecodeString(strings.TrimPrefix(line, "AUTHENTICATE "))
+			if string(raw) == "\x00"+s.user+"\x00"+s.pass {
+				writeLine(conn, ":irc.local 900 ircbot ircbot!u@h "+s.user+" :You are now logged in as "+s.user)
+				writeLine(conn, ":irc.local 903 ircbot :SASL authentication successful")
+			} else {
+				writeLine(conn, ":irc.local 904 ircbot :SASL authentication failed")
+			}
+		case strings.HasPrefix(line, "CAP END"):
+			capEnd = true
+		case strings.HasPrefix(line, "NICK "):
+			nickSeen = true
+		case strings.HasPrefix(line, "USER "):
+			userSeen = true
+		case strings.HasPrefix(line, "JOIN "):
+			writeLine(conn, ":ircbot!u@h JOIN "+strings.TrimSpace(line[5:]))
+		case strings.HasPrefix(line, "QUIT"):
+			return
+		}
+
+		if capEnd && nickSeen && userSeen && !welcomed {
+			welcomed = true
+			writeLine(conn, ":irc.local 001 ircbot :Welcome")
+			writeLine(conn, ":irc.local 376 ircbot :End of /MOTD")
+		}
+	}
+}
+
+func (s *fakeSASLServer) record(line string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.got = append(s.got, line)
+}
+
+func (s *fakeSASLServer) seen(prefix string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, l := range s.got {
+		if strings.HasPrefix(l, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *fakeSASLServer) lines() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.got...)
+}
+
+// TestSASLPlainSuccess authenticates with valid credentials and joins the channel.
+func TestSASLPlainSuccess(t *testing.T) {
+	s := startFakeSASLServer(t, "ircbot", "secret")
+	defer s.close()
+
+	cli, err := irc.New(config.IRCConfig{
+		Server:       s.addr(),
+		Nick:         "ircbot",
+		SASLLogin:    "ircbot",
+		SASLPass:     "secret",
+		SASLRequired: true,
+		Channels:     []string{"#sasl"},
+	}, irc.Handlers{
+		Error: func(text string) { t.Logf("irc error: %s", text) },
+	}, irc.Options{})
+	if err != nil {
+		t.Fatalf("irc.New: %v", err)
+	}
+	defer cli.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := cli.Start(ctx); err != nil {
+		t.Fatalf("Start: %v (lines: %#v)", err, s.lines())
+	}
+
+	waitFor(t, 3*time.Second, func() bool { return s.seen("CAP REQ :sasl") }, "CAP REQ :sasl", nil)
+	waitFor(t, 3*time.Second, func() bool { return s.seen("AUTHENTICATE PLAIN") }, "AUTHENTICATE PLAIN", nil)
+	waitFor(t, 3*time.Second, func() bool { return s.seen("JOIN #sasl") }, "JOIN #sasl",
+		func() { t.Logf("got lines: %#v", s.lines()) },
+	)
+}
+
+// TestSASLMultilineLS requests sasl when it is offered on the last line of
+// a multi-line CAP LS reply.
+func TestSASLMultilineLS(t *testing.T) {
+	s := startFakeSASLServer(t, "ircbot", "secret")
+	defer s.close()
+	s.multiLS.Store(true)
+
+	cli, err := irc.New(config.IRCConfig{
+		Server:       s.addr(),
+		Nick:         "ircbot",
+		SASLLogin:    "ircbot",
+		SASLPass:     "secret",
+		SASLRequired: true,
+		Channels:     []string{"#sasl"},
+	}, irc.Handlers{}, irc.Options{Logger: io.Discard})
+	if err != nil {
+		t.Fatalf("irc.New: %v", err)
+	}
+	defer cli.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := cli.Start(ctx); err != nil {
+		t.Fatalf("Start: %v (lines: %#v)", err, s.lines())
+	}
+	waitFor(t, 3*time.Second, func() bool { return s.seen("JOIN #sasl") }, "JOIN #sasl",
+		func() { t.Logf("got lines: %#v", s.lines()) },
+	)
+	if !s.seen("CAP REQ :sasl") {
+		t.Errorf("expected CAP REQ :sasl, got lines: %#v", s.lines())
+	}
+}
+
+// TestSASLPlainFailureRequired aborts the connection when authentication fails.
+func TestSASLPlainFailureRequired(t *testing.T) {
+	s := startFakeSASLServer(t, "ircbot", "secret")
+	defer s.close()
+
+	var mu sync.Mutex
+	var errs []string
+	cli, err := irc.New(config.IRCConfig{
+		Server:       s.addr(),
+		Nick:         "ircbot",
+		SASLLogin:    "ircbot",
+		SASLPass:     "wrong",
+		SASLRequired: true,
+		Channels:     []string{"#sasl"},
+	}, irc.Handlers{
+		Error: func(text string) {
+			mu.Lock()
+			errs = append(errs, text)
+			mu.Unlock()
+		},
+	}, irc.Options{})
+	if err != nil {
+		t.Fatalf("irc.New: %v", err)
+	}
+	defer cli.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err = cli.Start(ctx)
+	if err == nil || !strings.Contains(err.Error(), "904") {
+		t.Fatalf("expected a 904 error from Start, got %v", err)
+	}
+	waitFor(t, 3*time.Second, func() bool { return s.seen("QUIT") }, "QUIT", nil)
+	if s.seen("JOIN ") {
+		t.Fatalf("client joined a channel after SASL failure: %#v", s.lines())
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(errs) == 0 {
+		t.Fatalf("expected Error handler to be called")
+	}
+}
+
+// TestSASLPlainFailureOptional continues registration when SASL is not required.
+func TestSASLPlainFailureOptional(t *testing.T) {
+	s := startFakeSASLServer(t, "ircbot", "secret")
+	defer s.close()
+
+	cli, err := irc.New(config.IRCConfig{
+		Server:    s.addr(),
+		Nick:      "ircbot",
+		SASLLogin: "ircbot",
+		SASLPass:  "wrong",
+		Channels:  []string{"#sasl"},
+	}, irc.Handlers{}, irc.Options{})
+	if err != nil {
+		t.Fatalf("irc.New: %v", err)
+	}
+	defer cli.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := cli.Start(ctx); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	waitFor(t, 3*time.Second, func() bool { return s.seen("JOIN #sasl") }, "JOIN #sasl",
+		func() { t.Logf("got lines: %#v", s.lines()) },
+	)
+}
+
+// TestSASLRequiredWithoutCap leaves a server that registers the client
+// without offering SASL.
+func TestSASLRequiredWithoutCap(t *testing.T) {
+	s := startFakeSASLServer(t, "ircbot", "secret")
+	defer s.close()
+	s.noCap.Store(true)
+
+	cli, err := irc.New(config.IRCConfig{
+		Server:       s.addr(),
+		Nick:         "ircbot",
+		SASLLogin:    "ircbot",
+		SASLPass:     "secret",
+		SASLRequired: true,
+		Channels:     []string{"#sasl"},
+	}, irc.Handlers{}, irc.Options{Logger: io.Discard})
+	if err != nil {
+		t.Fatalf("irc.New: %v", err)
+	}
+	defer cli.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := cli.Start(ctx); err == nil || !strings.Contains(err.Error(), "sasl") {
+		t.Fatalf("expected a sasl error from Start, got %v", err)
+	}
+	waitFor(t, 3*time.Second, func() bool { return s.seen("QUIT") }, "QUIT", nil)
+	if s.seen("JOIN ") {
+		t.Fatalf("client joined a channel without SASL: %#v", s.lines())
+	}
+}
+
+// TestSASLConfigValidation rejects incomplete SASL settings up front.
+func TestSASLConfigValidation(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  config.IRCConfig
+	}{
+		{
+			name: "LoginWithoutPass",
+			cfg:  config.IRCConfig{Server: "x:6667", Nick: "n", SASLLogin: "n"},
+		},
+		{
+			name: "ExternalWithoutTLS",
+			cfg:  config.IRCConfig{Server: "x:6667", Nick: "n", SASLExternal: true, TLSClientCert: "c", TLSClientKey: "k"},
+		},
+		{
+			name: "ExternalWithoutCert",
+			cfg:  config.IRCConfig{Server: "x:6697", Nick: "n", TLS: true, SASLExternal: true},
+		},
+		{
+			name: "RequiredWithoutCredentials",
+			cfg:  config.IRCConfig{Server: "x:6667", Nick: "n", SASLRequired: true},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := irc.New(test.cfg, irc.Handlers{}, irc.Options{}); err == nil {
+				t.Errorf("expected an error but got nil")
+			}
+		})
+	}
+}
@@ -213,7 +213,7 @@ func TestIRCTLSHandshakeAndMessaging(t *testing.T) {
 
 	cli, err := irc.New(cfg, irc.Handlers{
 		Error: func(text string) { t.Logf("irc error: %s", text) },
-	}, irc.Options{DisableFlood: true})
+	}, irc.Options{})
 	if err != nil {
 		t.Fatalf("irc.New: %v", err)
 	}
@@ -0,0 +1,132 @@
+/*
+MIT License
+
+Copyright (c) 2025 Mikael Schultz <mikael@conf-t.se>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package irc
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/bitcanon/ircpush/pkg/metrics"
+	"github.com/fluffle/goirc/client"
+)
+
+const (
+	defaultPingInterval = time.Minute
+	defaultPingTimeout  = 30 * time.Second
+)
+
+// keepalive sends a PING with a token every ping_interval while registered
+// and measures the lag from the PONG. It runs until Close.
+func (c *Client) keepalive() {
+	for {
+		cfg := c.config()
+		interval, timeout := cfg.PingInterval, cfg.PingTimeout
+		if interval == 0 {
+			interval = defaultPingInterval
+		}
+		if timeout <= 0 {
+			timeout = defaultPingTimeout
+		}
+		wait := interval
+		if wait < 0 {
+			wait = defaultPingInterval // off; look at the settings again later
+		}
+		t := time.NewTimer(wait)
+		select {
+		case <-c.stop:
+			t.Stop()
+			return
+		case <-t.C:
+		}
+		if interval > 0 {
+			c.ping(timeout)
+		}
+	}
+}
+
+// ping sends one PING and waits for its PONG. Without one within timeout the
+// connection is considered dead (NAT timeout, half-open socket) and closed,
+// so the reconnector connects again.
+func (c *Client) ping(timeout time.Duration) {
+	c.stateMu.Lock()
+	if !c.registered {
+		c.stateMu.Unlock()
+		return
+	}
+	token := fmt.Sprintf("ircpush-%d", time.Now().UnixNano())
+	c.pingToken, c.pingSent = token, time.Now()
+	server := c.cfg.Server
+	c.stateMu.Unlock()
+	select {
+	case <-c.pongCh: // answer to an earlier PING that came in late
+	default:
+	}
+	c.conn.Raw("PING :" + token)
+
+	t := time.NewTimer(timeout)
+	defer t.Stop()
+	select {
+	case <-c.stop:
+	case <-c.pongCh:
+	case <-t.C:
+		c.stateMu.Lock()
+		lost := c.registered && c.pingToken == token
+		c.pingToken = ""
+		c.stateMu.Unlock()
+		if lost {
+			logf(c.opts.Logger, "irc: no PONG from %s in %s, reconnecting", server, timeout)
+			metrics.IRCPingTimeouts.Inc(c.network)
+			_ = c.conn.Close()
+		}
+	}
+}
+
+// pong records the lag when a PONG answers the pending PING.
+func (c *Client) pong(_ *client.Conn, l *client.Line) {
+	token := l.Text()
+	c.stateMu.Lock()
+	if token == "" || token != c.pingToken {
+		c.stateMu.Unlock()
+		return
+	}
+	lag := time.Since(c.pingSent)
+	c.pingToken = ""
+	c.stateMu.Unlock()
+
+	c.lag.Store(int64(lag))
+	metrics.IRCLag.Set(lag.Seconds(), c.network)
+	select {
+	case c.pongCh <- struct{}{}:
+	default:
+	}
+	if c.handlers.Lag != nil {
+		c.handlers.Lag(lag)
+	}
+}
+
+// Lag returns the round-trip time of the last client PING on the current
+// connection, 0 until one was answered.
+func (c *Client) Lag() time.Duration {
+	return time.Duration(c.lag.Load())
+}
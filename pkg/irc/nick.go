@@ -0,0 +1,228 @@
+/*
+MIT License
+
+Copyright (c) 2025 Mikael Schultz <mikael@conf-t.se>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package irc
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bitcanon/ircpush/pkg/config"
+	"github.com/fluffle/goirc/client"
+)
+
+const (
+	defaultReclaim = time.Minute
+	defaultRegain  = "PRIVMSG NickServ :REGAIN {nick} {pass}"
+	// maxNickSuffixes is how many nicks made by nick_suffix are tried
+	// after alt_nicks before the client gives up and reconnects later.
+	maxNickSuffixes = 9
+	// regainWait is how long to wait for services to free the nick
+	// before asking for it (GHOST needs a NICK afterwards, REGAIN not).
+	regainWait = 3 * time.Second
+)
+
+// nickCandidate returns the i-th nick to try while registering: nick, then
+// alt_nicks, then nicks made from nick by nick_suffix. ok is false when
+// they are used up.
+func nickCandidate(cfg config.IRCConfig, i int) (nick string, ok bool) {
+	if i == 0 {
+		return cfg.Nick, true
+	}
+	i--
+	if i < len(cfg.AltNicks) {
+		return strings.TrimSpace(cfg.AltNicks[i]), true
+	}
+	i -= len(cfg.AltNicks)
+	if i >= maxNickSuffixes {
+		return "", false
+	}
+	switch cfg.NickSuffix {
+	case "number":
+		return cfg.Nick + strconv.Itoa(i+1), true
+	case "random":
+		return fmt.Sprintf("%s%03d", cfg.Nick, rand.IntN(1000)), true
+	default:
+		return cfg.Nick + strings.Repeat("_", i+1), true
+	}
+}
+
+// regainCommand returns the raw line asking services to free the configured
+// nick, or "" when there is none (no identify_pass or regain_command: none).
+func regainCommand(cfg config.IRCConfig) string {
+	pass := strings.TrimSpace(cfg.IdentifyPass)
+	cmd := strings.TrimSpace(cfg.RegainCommand)
+	if pass == "" || strings.EqualFold(cmd, "none") {
+		return ""
+	}
+	if cmd == "" {
+		cmd = defaultRegain
+	}
+	return strings.NewReplacer("{nick}", cfg.Nick, "{pass}", pass).Replace(cmd)
+}
+
+// nick returns the nick in use on the current connection.
+func (c *Client) nick() string {
+	return c.conn.Me().Nick
+}
+
+// nextNick is goirc's NewNick, called when the server answers 433 (nick in
+// use). While registering it moves on to the next candidate nick; once
+// registered the 433 answers a reclaim attempt and the current nick is kept.
+func (c *Client) nextNick(taken string) string {
+	c.stateMu.Lock()
+	registered := c.registered
+	c.stateMu.Unlock()
+	if registered {
+		logf(c.opts.Logger, "irc: nick %s is still in use", taken)
+		return c.nick()
+	}
+	next, ok := c.advanceNick(taken, "is in use")
+	if !ok {
+		return "" // quitting; don't ask for the taken nick again
+	}
+	return next
+}
+
+// nickRejected handles 432 (erroneous nickname) and 437 (nick temporarily
+// unavailable) like 433 while registering.
+func (c *Client) nickRejected(conn *client.Conn, l *client.Line) {
+	if len(l.Args) < 2 || strings.HasPrefix(l.Args[1], "#") || strings.HasPrefix(l.Args[1], "&") {
+		return // 437 is also sent for channels
+	}
+	why := "is erroneous"
+	if l.Cmd == "437" {
+		why = "is unavailable"
+	}
+	c.stateMu.Lock()
+	registered := c.registered
+	c.stateMu.Unlock()
+	if registered {
+		logf(c.opts.Logger, "irc: cannot reclaim nick %s: it %s", l.Args[1], why)
+		return
+	}
+	next, ok := c.advanceNick(l.Args[1], why)
+	if !ok {
+		return
+	}
+	conn.Nick(next) // the 001 tells goirc which nick we got
+}
+
+// advanceNick picks the next candidate nick while registering. When all are
+// used up it quits once, and the reconnector starts over with nick after
+// its backoff.
+func (c *Client) advanceNick(taken, why string) (string, bool) {
+	c.stateMu.Lock()
+	c.nickTry++
+	next, ok := nickCandidate(c.cfg, c.nickTry)
+	quit := !ok && c.tryNick != "" // tryNick is cleared once we quit
+	if ok {
+		c.tryNick = next
+	} else {
+		c.tryNick = ""
+	}
+	c.stateMu.Unlock()
+	if !ok {
+		if quit {
+			logf(c.opts.Logger, "irc: nick %s %s and no alternatives are left, reconnecting later", taken, why)
+			c.conn.Quit("No usable nick")
+		}
+		return "", false
+	}
+	logf(c.opts.Logger, "irc: nick %s %s, trying %s", taken, why, next)
+	return next, true
+}
+
+// reclaimNick tries to get the configured nick back when the client uses
+// another one. With regain it first asks services to free the nick, and
+// asks for it once they had time to.
+func (c *Client) reclaimNick(regain bool) {
+	c.stateMu.Lock()
+	cfg, registered := c.cfg, c.registered
+	c.stateMu.Unlock()
+	if !registered || strings.EqualFold(c.nick(), cfg.Nick) {
+		return
+	}
+	if cmd := regainCommand(cfg); regain && cmd != "" {
+		logf(c.opts.Logger, "irc: asking services to release nick %s", cfg.Nick)
+		c.conn.Raw(cmd)
+		time.AfterFunc(regainWait, func() { c.reclaimNick(false) })
+		return
+	}
+	logf(c.opts.Logger, "irc: trying to reclaim nick %s", cfg.Nick)
+	c.conn.Nick(cfg.Nick)
+}
+
+// reclaimer retries getting the configured nick back every nick_reclaim
+// until Close.
+func (c *Client) reclaimer() {
+	for {
+		wait := c.config().NickReclaim
+		if wait <= 0 {
+			wait = defaultReclaim
+		}
+		t := time.NewTimer(wait)
+		select {
+		case <-c.stop:
+			t.Stop()
+			return
+		case <-t.C:
+		}
+		if c.config().NickReclaim >= 0 {
+			c.reclaimNick(false)
+		}
+	}
+}
+
+// wireNickHandlers sets up nick collision recovery: the next candidate on
+// 432/437 (433 goes through goirc's NewNick), and reclaiming the configured
+// nick when its holder quits or changes nick.
+func (c *Client) wireNickHandlers() {
+	c.conn.HandleFunc("432", c.nickRejected)
+	c.conn.HandleFunc("437", c.nickRejected)
+
+	c.conn.HandleFunc("quit", func(_ *client.Conn, l *client.Line) {
+		if strings.EqualFold(l.Nick, c.config().Nick) {
+			c.reclaimNick(false)
+		}
+	})
+	c.conn.HandleFunc("nick", func(conn *client.Conn, l *client.Line) {
+		if len(l.Args) == 0 {
+			return
+		}
+		cfg := c.config()
+		switch {
+		case strings.EqualFold(l.Args[0], cfg.Nick) && strings.EqualFold(conn.Me().Nick, cfg.Nick):
+			// goirc has already updated our nick
+			logf(c.opts.Logger, "irc: nick %s reclaimed", cfg.Nick)
+			if s := strings.TrimSpace(cfg.IdentifyPass); s != "" {
+				conn.Privmsg("NickServ", "IDENTIFY "+s)
+			}
+		case strings.EqualFold(l.Nick, cfg.Nick):
+			c.reclaimNick(false)
+		}
+	})
+}
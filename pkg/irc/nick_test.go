@@ -0,0 +1,74 @@
+package irc
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/bitcanon/ircpush/pkg/config"
+)
+
+// TestNickCandidate verifies the order of nicks tried while registering.
+func TestNickCandidate(t *testing.T) {
+	// Setup test cases
+	tests := []struct {
+		name     string
+		cfg      config.IRCConfig
+		expected []string
+	}{
+		{
+			name:     "Underscore",
+			cfg:      config.IRCConfig{Nick: "bot", AltNicks: []string{"bot2", " botty "}},
+			expected: []string{"bot", "bot2", "botty", "bot_", "bot__"},
+		},
+		{
+			name:     "Number",
+			cfg:      config.IRCConfig{Nick: "bot", NickSuffix: "number"},
+			expected: []string{"bot", "bot1", "bot2", "bot3"},
+		},
+	}
+
+	// Run test cases
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for i, want := range test.expected {
+				got, ok := nickCandidate(test.cfg, i)
+				if !ok || got != want {
+					t.Errorf("candidate %d: expected %q, but got %q (ok=%v)", i, want, got, ok)
+				}
+			}
+		})
+	}
+
+	// Random suffixes keep the nick, and the candidates run out
+	cfg := config.IRCConfig{Nick: "bot", NickSuffix: "random"}
+	if got, _ := nickCandidate(cfg, 1); !strings.HasPrefix(got, "bot") || len(got) != 6 {
+		t.Errorf("expected bot and three digits, but got %q", got)
+	}
+	if _, ok := nickCandidate(cfg, 1+maxNickSuffixes); ok {
+		t.Errorf("expected no candidate after %d suffixes", maxNickSuffixes)
+	}
+}
+
+// TestRegainCommand verifies the services command used to free the nick.
+func TestRegainCommand(t *testing.T) {
+	// Setup test cases
+	tests := []struct {
+		name     string
+		cfg      config.IRCConfig
+		expected string
+	}{
+		{name: "NoPassword", cfg: config.IRCConfig{Nick: "bot"}, expected: ""},
+		{name: "Default", cfg: config.IRCConfig{Nick: "bot", IdentifyPass: "pw"}, expected: "PRIVMSG NickServ :REGAIN bot pw"},
+		{name: "Ghost", cfg: config.IRCConfig{Nick: "bot", IdentifyPass: "pw", RegainCommand: "NS GHOST {nick} {pass}"}, expected: "NS GHOST bot pw"},
+		{name: "Disabled", cfg: config.IRCConfig{Nick: "bot", IdentifyPass: "pw", RegainCommand: "none"}, expected: ""},
+	}
+
+	// Run test cases
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := regainCommand(test.cfg); got != test.expected {
+				t.Errorf("expected %q, but got %q", test.expected, got)
+			}
+		})
+	}
+}
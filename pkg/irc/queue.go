@@ -0,0 +1,534 @@
+/*
+MIT License
+
+Copyright (c) 2025 Mikael Schultz <mikael@conf-t.se>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package irc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bitcanon/ircpush/pkg/config"
+	"github.com/bitcanon/ircpush/pkg/metrics"
+)
+
+// Overflow policies for a full send queue (irc.queue.overflow).
+const (
+	OverflowBlock      = "block"       // wait for room (backpressure to the input)
+	OverflowDropNewest = "drop_newest" // discard the message being queued
+	OverflowDropOldest = "drop_oldest" // discard the oldest queued message
+	OverflowCollapse   = "collapse"    // discard and later send "[N messages suppressed]"
+)
+
+const (
+	defaultQueueSize  = 1000
+	defaultQueueRate  = 2.0
+	defaultQueueBurst = 5
+	defaultChanBurst  = 3
+	dropLogInterval   = 10 * time.Second
+)
+
+var errQueueClosed = errors.New("irc: client closed")
+
+// QueueStats is a snapshot of the outbound send queue.
+type QueueStats struct {
+	Depth      int            // messages waiting to be sent, without those of held channels
+	Capacity   int            // irc.queue.size
+	Channels   map[string]int // depth per channel
+	Sent       uint64         // PRIVMSGs written to the connection
+	Dropped    uint64         // discarded by drop_newest / drop_oldest or for a channel that is not joined
+	Suppressed uint64         // discarded by collapse (reported as "N messages suppressed")
+}
+
+// tokenBucket is a classic token bucket; a nil bucket or rate <= 0 never limits.
+type tokenBucket struct {
+	rate   float64 // tokens per second
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	if rate <= 0 {
+		return nil
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return &tokenBucket{rate: rate, burst: float64(burst), tokens: float64(burst)}
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	if !b.last.IsZero() {
+		b.tokens = min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	}
+	b.last = now
+}
+
+// delay returns how long until a token is available (0 = now).
+func (b *tokenBucket) delay(now time.Time) time.Duration {
+	if b == nil {
+		return 0
+	}
+	b.refill(now)
+	if b.tokens >= 1 {
+		return 0
+	}
+	return time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+}
+
+func (b *tokenBucket) take(now time.Time) {
+	if b == nil {
+		return
+	}
+	b.refill(now)
+	b.tokens--
+}
+
+// chanQueue holds the pending messages for one channel.
+type chanQueue struct {
+	name       string
+	items      []queued
+	bucket     *tokenBucket
+	suppressed int  // collapsed messages not yet reported
+	held       bool // not joined because of a kick or join error, see holdLocked
+}
+
+// outMsg is one segment for one channel, see Client.prepare.
+type outMsg struct {
+	channel, text string
+	last          bool // last segment of a message, counted in IRCMessagesSent once written
+}
+
+type queued struct {
+	seq  uint64 // global arrival order, used by drop_oldest
+	text string
+	last bool
+	at   time.Time
+}
+
+// sendQueue is the bounded outbound queue between SendTo/Broadcast and the
+// connection. Each channel has its own FIFO and token bucket so a busy channel
+// cannot starve the others; a global bucket keeps the total under the
+// server's flood limit. A single worker goroutine writes to the connection.
+type sendQueue struct {
+	size     int
+	overflow string
+	global   *tokenBucket
+	chRate   float64
+	chBurst  int
+
+	send    func(channel, text string)
+	canSend func(channel string) bool                 // false while disconnected or not joined yet
+	held    func(channel string) (held, retried bool) // kicked or not joinable, see holdLocked
+	logf    func(format string, a ...any)
+
+	network    string // metrics labels, see Options.ChannelPrefix
+	chanPrefix string
+
+	mu          sync.Mutex
+	space       *sync.Cond // signalled whenever messages leave the queue
+	chans       map[string]*chanQueue
+	order       []*chanQueue // round-robin order
+	next        int
+	depth       int
+	seq         uint64
+	inflight    bool
+	closed      bool
+	sent        uint64
+	dropped     uint64
+	suppressed  uint64
+	lastDropLog time.Time
+
+	wake chan struct{}
+	stop chan struct{}
+}
+
+// queueSettings validates irc.queue and fills in defaults.
+func queueSettings(qc config.QueueConfig) (config.QueueConfig, error) {
+	if qc.Size <= 0 {
+		qc.Size = defaultQueueSize
+	}
+	switch qc.Overflow {
+	case "":
+		qc.Overflow = OverflowCollapse
+	case OverflowBlock, OverflowDropNewest, OverflowDropOldest, OverflowCollapse:
+	default:
+		return qc, fmt.Errorf("irc: queue.overflow must be one of block, drop_newest, drop_oldest, collapse (got %q)", qc.Overflow)
+	}
+	if qc.Rate == 0 {
+		qc.Rate = defaultQueueRate
+	}
+	if qc.Burst <= 0 {
+		qc.Burst = defaultQueueBurst
+	}
+	if qc.ChannelBurst <= 0 {
+		qc.ChannelBurst = defaultChanBurst
+	}
+	return qc, nil
+}
+
+func newSendQueue(qc config.QueueConfig, send func(channel, text string), canSend func(string) bool, held func(string) (bool, bool), logf func(string, ...any)) *sendQueue {
+	q := &sendQueue{
+		size:     qc.Size,
+		overflow: qc.Overflow,
+		global:   newTokenBucket(qc.Rate, qc.Burst),
+		chRate:   qc.ChannelRate,
+		chBurst:  qc.ChannelBurst,
+		send:     send,
+		canSend:  canSend,
+		held:     held,
+		logf:     logf,
+		chans:    map[string]*chanQueue{},
+		wake:     make(chan struct{}, 1),
+		stop:     make(chan struct{}),
+	}
+	q.space = sync.NewCond(&q.mu)
+	return q
+}
+
+// kick wakes the worker, e.g. after (re)connecting or joining a channel.
+func (q *sendQueue) kick() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (q *sendQueue) chanLocked(channel string) *chanQueue {
+	key := strings.ToLower(channel)
+	cq := q.chans[key]
+	if cq == nil {
+		cq = &chanQueue{name: channel, bucket: newTokenBucket(q.chRate, q.chBurst)}
+		q.chans[key] = cq
+		q.order = append(q.order, cq)
+	}
+	return cq
+}
+
+// push queues m, applying the overflow policy when full.
+func (q *sendQueue) push(m outMsg) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	cq := q.chanLocked(m.channel)
+	for !q.closed {
+		if !q.holdLocked(cq) {
+			q.dropNotJoinedLocked(cq, 1)
+			return
+		}
+		if cq.held {
+			break
+		}
+		// Report collapsed messages before anything newer, once there is room
+		if cq.suppressed > 0 && q.depth < q.size {
+			q.reportSuppressedLocked(cq)
+		}
+		if q.depth < q.size {
+			break
+		}
+		switch q.overflow {
+		case OverflowBlock:
+			q.space.Wait()
+			continue
+		case OverflowDropOldest:
+			q.dropOldestLocked()
+			continue
+		case OverflowDropNewest:
+			q.dropped++
+		case OverflowCollapse:
+			cq.suppressed++
+			q.suppressed++
+		}
+		metrics.LinesDropped.Inc("irc", "rate_limit")
+		q.logDropLocked()
+		return
+	}
+	if q.closed {
+		return
+	}
+	q.appendLocked(cq, m.text, m.last)
+	q.kick()
+}
+
+// pushWait queues all msgs at once, waiting until the queue has room for
+// them (or is empty, when they don't fit at all) whatever the overflow
+// policy. It fails when ctx is done or the queue is closed; then nothing
+// was queued.
+func (q *sendQueue) pushWait(ctx context.Context, msgs []outMsg) error {
+	stop := context.AfterFunc(ctx, func() {
+		q.mu.Lock()
+		q.space.Broadcast()
+		q.mu.Unlock()
+	})
+	defer stop()
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for !q.closed && ctx.Err() == nil && q.depth > 0 && q.depth+len(msgs) > q.size {
+		q.space.Wait()
+	}
+	if q.closed {
+		return errQueueClosed
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	for _, m := range msgs {
+		if cq := q.chanLocked(m.channel); q.holdLocked(cq) {
+			q.appendLocked(cq, m.text, m.last)
+		} else {
+			q.dropNotJoinedLocked(cq, 1)
+		}
+	}
+	q.kick()
+	return nil
+}
+
+func (q *sendQueue) appendLocked(cq *chanQueue, text string, last bool) {
+	q.seq++
+	cq.items = append(cq.items, queued{seq: q.seq, text: text, last: last, at: time.Now()})
+	if cq.held {
+		// Keep the newest size messages for the rejoin
+		if n := len(cq.items) - q.size; n > 0 {
+			cq.items = cq.items[n:]
+			q.dropNotJoinedLocked(cq, n)
+		}
+		return
+	}
+	q.depth++
+	metrics.QueueDepth.Set(float64(q.depth), q.network)
+}
+
+// holdLocked updates whether cq is held: not joined because the client was
+// kicked or could not join. Messages of a held channel wait for the rejoin
+// without counting toward the queue size, up to size of them, so that one
+// banned channel cannot fill the queue and stall the others. It returns
+// false when the channel is not rejoined (no retry); then its messages are
+// dropped.
+func (q *sendQueue) holdLocked(cq *chanQueue) bool {
+	held, retried := q.held(cq.name)
+	if held != cq.held {
+		if held {
+			q.depth -= len(cq.items)
+		} else {
+			q.depth += len(cq.items)
+		}
+		cq.held = held
+		metrics.QueueDepth.Set(float64(q.depth), q.network)
+		q.space.Broadcast()
+	}
+	if held && !retried {
+		if n := len(cq.items); n > 0 {
+			cq.items = nil
+			q.dropNotJoinedLocked(cq, n)
+		}
+		return false
+	}
+	return true
+}
+
+// dropNotJoinedLocked counts n messages for the held channel cq as dropped.
+func (q *sendQueue) dropNotJoinedLocked(cq *chanQueue, n int) {
+	q.dropped += uint64(n)
+	metrics.LinesDropped.Add(float64(n), "irc", "not_joined")
+	if now := time.Now(); now.Sub(q.lastDropLog) >= dropLogInterval {
+		q.lastDropLog = now
+		q.logf("irc: not in %s: %d messages dropped, %d dropped so far", cq.name, n, q.dropped)
+	}
+}
+
+func (q *sendQueue) reportSuppressedLocked(cq *chanQueue) {
+	q.appendLocked(cq, fmt.Sprintf("[%d messages suppressed]", cq.suppressed), false)
+	cq.suppressed = 0
+}
+
+// dropOldestLocked discards the message that has been queued the longest.
+func (q *sendQueue) dropOldestLocked() {
+	var oldest *chanQueue
+	for _, cq := range q.order {
+		if !cq.held && len(cq.items) > 0 && (oldest == nil || cq.items[0].seq < oldest.items[0].seq) {
+			oldest = cq
+		}
+	}
+	if oldest == nil {
+		return
+	}
+	oldest.items = oldest.items[1:]
+	q.depth--
+	q.dropped++
+	metrics.QueueDepth.Set(float64(q.depth), q.network)
+	metrics.LinesDropped.Inc("irc", "rate_limit")
+	q.logDropLocked()
+}
+
+func (q *sendQueue) logDropLocked() {
+	if now := time.Now(); now.Sub(q.lastDropLog) >= dropLogInterval {
+		q.lastDropLog = now
+		q.logf("irc: send queue full (size=%d, overflow=%s): %d dropped, %d suppressed so far",
+			q.size, q.overflow, q.dropped, q.suppressed)
+	}
+}
+
+// nextLocked picks the next channel allowed to send, round-robin. When nothing
+// can be sent it returns how long to wait (0 = until woken).
+func (q *sendQueue) nextLocked(now time.Time) (*chanQueue, time.Duration) {
+	for _, cq := range q.order {
+		q.holdLocked(cq)
+	}
+	if q.depth == 0 {
+		return nil, 0
+	}
+	if d := q.global.delay(now); d > 0 {
+		return nil, d
+	}
+	var wait time.Duration
+	for i := range q.order {
+		idx := (q.next + i) % len(q.order)
+		cq := q.order[idx]
+		if len(cq.items) == 0 || cq.held || !q.canSend(cq.name) {
+			continue
+		}
+		if d := cq.bucket.delay(now); d > 0 {
+			if wait == 0 || d < wait {
+				wait = d
+			}
+			continue
+		}
+		q.next = idx + 1
+		return cq, 0
+	}
+	return nil, wait
+}
+
+// run is the worker loop; it exits when the queue is closed.
+func (q *sendQueue) run() {
+	timer := time.NewTimer(time.Hour)
+	timer.Stop()
+	for {
+		q.mu.Lock()
+		if q.closed {
+			q.mu.Unlock()
+			return
+		}
+		now := time.Now()
+		cq, wait := q.nextLocked(now)
+		if cq != nil {
+			item := cq.items[0]
+			cq.items = cq.items[1:]
+			q.depth--
+			metrics.QueueDepth.Set(float64(q.depth), q.network)
+			q.global.take(now)
+			cq.bucket.take(now)
+			if len(cq.items) == 0 && cq.suppressed > 0 {
+				q.reportSuppressedLocked(cq)
+			}
+			q.inflight = true
+			q.space.Broadcast()
+			q.mu.Unlock()
+
+			q.send(cq.name, item.text)
+			metrics.IRCSegmentsSent.Inc(q.chanPrefix + cq.name)
+			if item.last {
+				metrics.IRCMessagesSent.Inc(q.chanPrefix + cq.name)
+			}
+			metrics.QueueWait.Observe(time.Since(item.at).Seconds())
+
+			q.mu.Lock()
+			q.inflight = false
+			q.sent++
+			q.space.Broadcast()
+			q.mu.Unlock()
+			continue
+		}
+		q.mu.Unlock()
+
+		var tc <-chan time.Time
+		if wait > 0 {
+			timer.Reset(wait)
+			tc = timer.C
+		}
+		select {
+		case <-q.wake:
+		case <-tc:
+		case <-q.stop:
+		}
+		if !timer.Stop() && wait > 0 {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+	}
+}
+
+// flush waits until the queue is empty and the last message was handed to
+// the connection, or ctx is done.
+func (q *sendQueue) flush(ctx context.Context) error {
+	tick := time.NewTicker(20 * time.Millisecond)
+	defer tick.Stop()
+	for {
+		q.mu.Lock()
+		idle := (q.depth == 0 && !q.inflight) || q.closed
+		q.mu.Unlock()
+		if idle {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-tick.C:
+		}
+	}
+}
+
+// close stops the worker and releases blocked producers. Queued messages are discarded.
+func (q *sendQueue) close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return
+	}
+	q.closed = true
+	close(q.stop)
+	q.space.Broadcast()
+}
+
+func (q *sendQueue) stats() QueueStats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	st := QueueStats{
+		Depth:      q.depth,
+		Capacity:   q.size,
+		Channels:   make(map[string]int, len(q.order)),
+		Sent:       q.sent,
+		Dropped:    q.dropped,
+		Suppressed: q.suppressed,
+	}
+	for _, cq := range q.order {
+		st.Channels[cq.name] = len(cq.items)
+	}
+	return st
+}
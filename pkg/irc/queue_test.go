@@ -0,0 +1,306 @@
+package irc
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bitcanon/ircpush/pkg/config"
+	"github.com/bitcanon/ircpush/pkg/metrics"
+)
+
+// recorder collects what the queue worker sends.
+type recorder struct {
+	mu   sync.Mutex
+	sent []string
+}
+
+func (r *recorder) send(ch, text string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sent = append(r.sent, ch+" "+text)
+}
+
+func (r *recorder) lines() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.sent...)
+}
+
+func testQueue(t *testing.T, qc config.QueueConfig, r *recorder) *sendQueue {
+	t.Helper()
+	qc, err := queueSettings(qc)
+	if err != nil {
+		t.Fatalf("queueSettings: %v", err)
+	}
+	return newSendQueue(qc, r.send, func(string) bool { return true }, func(string) (bool, bool) { return false, false }, func(string, ...any) {})
+}
+
+// TestQueueOverflow tests the overflow policies on a queue whose worker is not running yet.
+func TestQueueOverflow(t *testing.T) {
+	// Setup test cases
+	tests := []struct {
+		overflow string
+		expected []string
+	}{
+		{
+			overflow: OverflowDropNewest,
+			expected: []string{"#a 1", "#a 2", "#a 3"},
+		},
+		{
+			overflow: OverflowDropOldest,
+			expected: []string{"#a 3", "#a 4", "#a 5"},
+		},
+		{
+			overflow: OverflowCollapse,
+			expected: []string{"#a 1", "#a 2", "#a 3", "#a [2 messages suppressed]"},
+		},
+	}
+	// Run test cases
+	for _, test := range tests {
+		t.Run(test.overflow, func(t *testing.T) {
+			r := &recorder{}
+			q := testQueue(t, config.QueueConfig{Size: 3, Overflow: test.overflow, Rate: -1}, r)
+			for _, m := range []string{"1", "2", "3", "4", "5"} {
+				q.push(outMsg{channel: "#a", text: m})
+			}
+			go q.run()
+			defer q.close()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+			if err := q.flush(ctx); err != nil {
+				t.Fatalf("flush: %v", err)
+			}
+			if got := r.lines(); !reflect.DeepEqual(got, test.expected) {
+				t.Errorf("expected %#v, but got %#v", test.expected, got)
+			}
+		})
+	}
+}
+
+// TestQueueBlock verifies that the block policy waits for room instead of dropping.
+func TestQueueBlock(t *testing.T) {
+	r := &recorder{}
+	q := testQueue(t, config.QueueConfig{Size: 1, Overflow: OverflowBlock, Rate: -1}, r)
+	q.push(outMsg{channel: "#a", text: "1"})
+
+	done := make(chan struct{})
+	go func() {
+		q.push(outMsg{channel: "#a", text: "2"})
+		close(done)
+	}()
+	select {
+	case <-done:
+		t.Fatalf("push returned although the queue was full")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	go q.run()
+	defer q.close()
+	<-done
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := q.flush(ctx); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+	if got := r.lines(); !reflect.DeepEqual(got, []string{"#a 1", "#a 2"}) {
+		t.Errorf("unexpected sends: %#v", got)
+	}
+}
+
+// TestQueuePushWait verifies that pushWait waits for room under any policy
+// and queues nothing when it gives up.
+func TestQueuePushWait(t *testing.T) {
+	r := &recorder{}
+	q := testQueue(t, config.QueueConfig{Size: 2, Overflow: OverflowCollapse, Rate: -1}, r)
+	q.push(outMsg{channel: "#a", text: "1"})
+	q.push(outMsg{channel: "#a", text: "2"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := q.pushWait(ctx, []outMsg{{channel: "#a", text: "lost"}}); err == nil {
+		t.Fatalf("pushWait returned nil although the queue was full")
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- q.pushWait(context.Background(), []outMsg{{channel: "#a", text: "3"}, {channel: "#a", text: "4"}})
+	}()
+	go q.run()
+	if err := <-done; err != nil {
+		t.Fatalf("pushWait: %v", err)
+	}
+	flushCtx, flushCancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer flushCancel()
+	if err := q.flush(flushCtx); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+	if got := r.lines(); !reflect.DeepEqual(got, []string{"#a 1", "#a 2", "#a 3", "#a 4"}) {
+		t.Errorf("unexpected sends: %#v", got)
+	}
+
+	q.close()
+	if err := q.pushWait(context.Background(), []outMsg{{channel: "#a", text: "5"}}); err != errQueueClosed {
+		t.Errorf("expected errQueueClosed after close, got %v", err)
+	}
+}
+
+// TestQueueRateLimit verifies the global token bucket and round-robin between channels.
+func TestQueueRateLimit(t *testing.T) {
+	r := &recorder{}
+	q := testQueue(t, config.QueueConfig{Rate: 20, Burst: 2}, r)
+	for _, m := range []string{"1", "2", "3"} {
+		q.push(outMsg{channel: "#a", text: m})
+	}
+	q.push(outMsg{channel: "#b", text: "1"})
+
+	start := time.Now()
+	go q.run()
+	defer q.close()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := q.flush(ctx); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+	// Burst of 2, then 2 more at 20/s: at least ~100ms
+	if el := time.Since(start); el < 80*time.Millisecond {
+		t.Errorf("expected rate limiting, finished in %s", el)
+	}
+	expected := []string{"#a 1", "#b 1", "#a 2", "#a 3"}
+	if got := r.lines(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %#v, but got %#v", expected, got)
+	}
+	if st := q.stats(); st.Sent != 4 || st.Depth != 0 {
+		t.Errorf("unexpected stats: %+v", st)
+	}
+}
+
+// TestQueueHeldChannel verifies that with overflow=block, messages for a
+// channel the client was kicked from or cannot join stop counting toward the
+// queue size, so they cannot stall the other channels: they are kept up to
+// the queue size while the channel is retried, and dropped when it is not.
+func TestQueueHeldChannel(t *testing.T) {
+	var mu sync.Mutex
+	problems := map[string]bool{} // channel -> retried
+	joined := map[string]bool{"#b": true}
+	r := &recorder{}
+	qc, _ := queueSettings(config.QueueConfig{Size: 2, Overflow: OverflowBlock, Rate: -1})
+	q := newSendQueue(qc, r.send,
+		func(ch string) bool {
+			mu.Lock()
+			defer mu.Unlock()
+			return joined[ch]
+		},
+		func(ch string) (bool, bool) {
+			mu.Lock()
+			defer mu.Unlock()
+			retried, held := problems[ch]
+			return held, retried
+		},
+		func(string, ...any) {})
+	go q.run()
+	defer q.close()
+
+	// #a is still joining: its messages fill the queue and #b has to wait
+	q.push(outMsg{channel: "#a", text: "1"})
+	q.push(outMsg{channel: "#a", text: "2"})
+	done := make(chan struct{})
+	go func() {
+		q.push(outMsg{channel: "#b", text: "1"})
+		close(done)
+	}()
+	select {
+	case <-done:
+		t.Fatalf("push returned although the queue was full")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// Banned from #a, retried later: #b goes through
+	mu.Lock()
+	problems["#a"] = true
+	mu.Unlock()
+	q.kick()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("#b still blocked by the held messages of #a")
+	}
+	for _, m := range []string{"3", "4", "5"} {
+		q.push(outMsg{channel: "#a", text: m})
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := q.flush(ctx); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+	st := q.stats()
+	if st.Depth != 0 || st.Channels["#a"] != 2 || st.Dropped != 3 {
+		t.Errorf("expected the newest 2 messages of #a held, but got %+v", st)
+	}
+
+	// Not retried: the held messages and new ones are dropped
+	mu.Lock()
+	problems["#a"] = false
+	mu.Unlock()
+	q.push(outMsg{channel: "#a", text: "6"})
+	if st := q.stats(); st.Channels["#a"] != 0 || st.Dropped != 6 {
+		t.Errorf("expected the messages of #a dropped, but got %+v", st)
+	}
+
+	// Only #b was sent
+	if got := r.lines(); !reflect.DeepEqual(got, []string{"#b 1"}) {
+		t.Errorf("unexpected sends: %#v", got)
+	}
+}
+
+// TestQueueMessagesSent verifies that a message is counted as sent only once
+// its last segment has been written, not when it is queued.
+func TestQueueMessagesSent(t *testing.T) {
+	r := &recorder{}
+	q := testQueue(t, config.QueueConfig{Rate: -1}, r)
+	before := metrics.IRCMessagesSent.Value("#sent")
+	for _, m := range []outMsg{{channel: "#sent", text: "1/2"}, {channel: "#sent", text: "2/2", last: true}} {
+		q.push(m)
+	}
+	if got := metrics.IRCMessagesSent.Value("#sent") - before; got != 0 {
+		t.Fatalf("expected nothing counted before sending, got %v", got)
+	}
+
+	go q.run()
+	defer q.close()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := q.flush(ctx); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+	if got := metrics.IRCMessagesSent.Value("#sent") - before; got != 1 {
+		t.Errorf("expected 1 message counted, got %v", got)
+	}
+}
+
+// TestTokenBucket tests the token bucket arithmetic.
+func TestTokenBucket(t *testing.T) {
+	now := time.Unix(0, 0)
+	b := newTokenBucket(2, 2)
+	b.take(now)
+	b.take(now)
+	if d := b.delay(now); d != 500*time.Millisecond {
+		t.Errorf("expected 500ms delay after burst, got %s", d)
+	}
+	if d := b.delay(now.Add(time.Second)); d != 0 {
+		t.Errorf("expected refill after 1s, got %s", d)
+	}
+	if newTokenBucket(0, 5).delay(now) != 0 {
+		t.Errorf("expected a zero rate to be unlimited")
+	}
+}
+
+// TestQueueSettings rejects unknown overflow policies.
+func TestQueueSettings(t *testing.T) {
+	if _, err := queueSettings(config.QueueConfig{Overflow: "explode"}); err == nil {
+		t.Errorf("expected an error but got nil")
+	}
+}
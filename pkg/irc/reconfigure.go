@@ -0,0 +1,183 @@
+/*
+MIT License
+
+Copyright (c) 2025 Mikael Schultz <mikael@conf-t.se>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package irc
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/bitcanon/ircpush/pkg/config"
+	"github.com/bitcanon/ircpush/pkg/ircfmt"
+)
+
+// config returns the current IRC settings.
+func (c *Client) config() config.IRCConfig {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+	return c.cfg
+}
+
+// mechanism returns the SASL mechanism of the current settings ("" = none).
+func (c *Client) mechanism() string {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+	return c.saslMech
+}
+
+// Reconfigure applies changed IRC settings at runtime:
+//   - channels added to or removed from irc.channels are joined or parted,
+//     and a changed key is used to retry a channel that is not joined;
+//   - max_message_len and split_long apply to the next message;
+//   - changes to the server, nick, TLS or authentication settings make the
+//     client quit and reconnect with the new settings;
+//   - queue settings need a restart.
+//
+// On error (invalid settings) nothing is changed.
+func (c *Client) Reconfigure(cfg config.IRCConfig) error {
+	if cfg.Server == "" || cfg.Nick == "" {
+		return errServerNick
+	}
+	ircCfg, mech, err := goircConfig(cfg, c.opts, c.dialID)
+	if err != nil {
+		return err
+	}
+	ircCfg.NewNick = c.nextNick
+
+	c.stateMu.Lock()
+	old := c.cfg
+	c.cfg = cfg
+	oldKeys, newKeys := channelKeys(old.Keys), channelKeys(cfg.Keys)
+	for lc := range oldKeys {
+		delete(c.keys, lc)
+	}
+	for lc, key := range newKeys {
+		c.keys[lc] = key
+	}
+	reconnect := connectionChanged(old, cfg)
+	if reconnect {
+		c.pending, c.pendingMech = ircCfg, mech
+	}
+	registered := c.registered
+	var retry []string
+	for _, ch := range c.channels {
+		lc := strings.ToLower(ch)
+		if newKeys[lc] != oldKeys[lc] && !c.joined[lc] {
+			retry = append(retry, ch)
+		}
+	}
+	c.stateMu.Unlock()
+
+	added, removed := diffChannels(old.Channels, cfg.Channels)
+	for _, ch := range removed {
+		c.Part(ch, "channel removed from configuration")
+	}
+	for _, ch := range added {
+		c.Join(ch, "")
+	}
+	if registered && !reconnect {
+		for _, ch := range retry {
+			c.sendJoin(ch)
+		}
+	}
+	if old.MaxMessageLen != cfg.MaxMessageLen || old.SplitLong != cfg.SplitLong || old.Formatting != cfg.Formatting {
+		logf(c.opts.Logger, "irc: message policy changed (max_len=%d split_long=%v formatting=%s)", cfg.MaxMessageLen, cfg.SplitLong, formattingName(cfg.Formatting))
+	}
+	if !reflect.DeepEqual(old.Queue, cfg.Queue) {
+		logf(c.opts.Logger, "irc: queue settings changed, restart required")
+	}
+	if reconnect {
+		logf(c.opts.Logger, "irc: connection settings changed, reconnecting to %s as %s", cfg.Server, cfg.Nick)
+		if c.conn.Connected() {
+			c.conn.Quit("Reconnecting (configuration changed)")
+		}
+	}
+	return nil
+}
+
+// applyPending installs connection settings from Reconfigure and the TLS
+// settings of the SASL dialer, which is only safe while disconnected, and
+// returns the server to connect to. Each
+// connection registers with the configured nick first again.
+func (c *Client) applyPending() string {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+	if c.pending != nil {
+		*c.conn.Config() = *c.pending
+		c.saslMech = c.pendingMech
+		c.pending = nil
+	}
+	if cfg := c.conn.Config(); cfg.Proxy != "" {
+		setDialer(c.dialID, cfg.SSLConfig)
+	}
+	c.conn.Config().Me.Nick = c.cfg.Nick
+	c.nickTry, c.tryNick = 0, c.cfg.Nick
+	return c.cfg.Server
+}
+
+// channelKeys normalizes irc.keys to lower-cased channel -> key.
+func channelKeys(keys map[string]string) map[string]string {
+	out := make(map[string]string, len(keys))
+	for ch, key := range keys {
+		out[strings.ToLower(ensureChanPrefix(ch))] = key
+	}
+	return out
+}
+
+// connectionChanged reports whether settings used while connecting differ.
+func connectionChanged(a, b config.IRCConfig) bool {
+	return a.Server != b.Server || a.Nick != b.Nick || a.Realname != b.Realname || a.ServerPass != b.ServerPass ||
+		a.TLS != b.TLS || a.TLSSkipVerify != b.TLSSkipVerify || a.TLSClientCert != b.TLSClientCert || a.TLSClientKey != b.TLSClientKey ||
+		a.SASLExternal != b.SASLExternal || a.SASLLogin != b.SASLLogin || a.SASLPass != b.SASLPass || a.SASLRequired != b.SASLRequired
+}
+
+// diffChannels returns the channels only in b (added) and only in a (removed).
+func diffChannels(a, b []string) (added, removed []string) {
+	in := func(list []string, ch string) bool {
+		for _, x := range list {
+			if strings.EqualFold(ensureChanPrefix(x), ch) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, ch := range b {
+		if ch = ensureChanPrefix(ch); ch != "" && !in(a, ch) {
+			added = append(added, ch)
+		}
+	}
+	for _, ch := range a {
+		if ch = ensureChanPrefix(ch); ch != "" && !in(b, ch) {
+			removed = append(removed, ch)
+		}
+	}
+	return added, removed
+}
+
+// formattingName returns the formatting level for display.
+func formattingName(level string) string {
+	if level == "" {
+		return ircfmt.Extended
+	}
+	return level
+}
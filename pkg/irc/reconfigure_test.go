@@ -0,0 +1,101 @@
+package irc_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bitcanon/ircpush/pkg/config"
+	"github.com/bitcanon/ircpush/pkg/irc"
+)
+
+// TestReconfigure verifies channel changes are joined/parted on the live
+// connection and that a new server makes the client reconnect there.
+func TestReconfigure(t *testing.T) {
+	s1 := startFakeServer(t)
+	defer s1.close()
+	s2 := startFakeServer(t)
+	defer s2.close()
+
+	cfg := config.IRCConfig{
+		Server:   s1.addr(),
+		Nick:     "ircbot",
+		Channels: []string{"#a", "#b"},
+	}
+	cli, err := irc.New(cfg, irc.Handlers{}, irc.Options{})
+	if err != nil {
+		t.Fatalf("irc.New: %v", err)
+	}
+	defer cli.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := cli.Start(ctx); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	waitFor(t, 3*time.Second, cli.Ready, "ready", nil)
+
+	// Channels and message policy: no reconnect
+	cfg.Channels = []string{"#b", "#c"}
+	cfg.MaxMessageLen = 10
+	cfg.SplitLong = true
+	if err := cli.Reconfigure(cfg); err != nil {
+		t.Fatalf("Reconfigure: %v", err)
+	}
+	waitFor(t, 3*time.Second, func() bool { return s1.seen("JOIN #c") && s1.seen("PART #a") }, "JOIN #c and PART #a", nil)
+	if got := strings.Join(cli.Channels(), ","); got != "#b,#c" {
+		t.Errorf("expected channels #b,#c, but got %s", got)
+	}
+	waitFor(t, 3*time.Second, cli.Ready, "ready after channel change", nil)
+	cli.SendTo([]string{"#c"}, "0123456789abc")
+	waitFor(t, 3*time.Second, func() bool { return s1.seen("PRIVMSG #c :abc") }, "split message", nil)
+
+	// Invalid settings are refused and change nothing
+	bad := cfg
+	bad.SASLLogin = "only-login"
+	if err := cli.Reconfigure(bad); err == nil {
+		t.Errorf("expected an error for incomplete SASL settings")
+	}
+
+	// New server and nick: quit and reconnect
+	cfg.Server = s2.addr()
+	cfg.Nick = "ircbot2"
+	if err := cli.Reconfigure(cfg); err != nil {
+		t.Fatalf("Reconfigure: %v", err)
+	}
+	waitFor(t, 3*time.Second, func() bool { return s1.seen("QUIT") }, "QUIT on the old server", nil)
+	waitFor(t, 5*time.Second, func() bool { return s2.seen("NICK ircbot2") && s2.seen("JOIN #b") }, "NICK and JOIN on the new server", nil)
+}
+
+// TestReconfigureSASLRequired verifies that requiring SASL reconnects, so a
+// connection registered without SASL does not stay up.
+func TestReconfigureSASLRequired(t *testing.T) {
+	s := startFakeSASLServer(t, "ircbot", "secret")
+	defer s.close()
+	s.noCap.Store(true)
+
+	cfg := config.IRCConfig{
+		Server:    s.addr(),
+		Nick:      "ircbot",
+		Channels:  []string{"#sasl"},
+		SASLLogin: "ircbot",
+		SASLPass:  "secret",
+	}
+	cli, err := irc.New(cfg, irc.Handlers{}, irc.Options{})
+	if err != nil {
+		t.Fatalf("irc.New: %v", err)
+	}
+	defer cli.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := cli.Start(ctx); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	waitFor(t, 3*time.Second, cli.Ready, "ready without SASL", nil)
+
+	cfg.SASLRequired = true
+	if err := cli.Reconfigure(cfg); err != nil {
+		t.Fatalf("Reconfigure: %v", err)
+	}
+	waitFor(t, 3*time.Second, func() bool { return s.seen("QUIT") }, "QUIT after requiring SASL", func() { t.Logf("server saw: %q", s.lines()) })
+}
@@ -0,0 +1,91 @@
+/*
+MIT License
+
+Copyright (c) 2025 Mikael Schultz <mikael@conf-t.se>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package irc
+
+import (
+	"strings"
+	"time"
+)
+
+// Status is a snapshot of the connection state, used by the health endpoints.
+type Status struct {
+	Server     string
+	Nick       string         // in use while registered, else the one being tried
+	WantNick   string         // the configured nick, which the client reclaims when Nick differs
+	Registered bool           // 001 received on the current connection
+	Ready      bool           // registered and the configured channels joined, see Client.Ready
+	Channels   []ChannelState // configured channels, in config order
+	Since      time.Time      // when Registered last changed
+	LastSend   time.Time      // last PRIVMSG written to the connection; zero if none yet
+	Lag        time.Duration  // round trip of the last client PING on this connection; 0 until measured
+}
+
+// ChannelState is the join state of one configured channel.
+type ChannelState struct {
+	Name    string
+	Joined  bool
+	Error   string    // why the channel is not joined, e.g. "banned (474)"; empty when unknown
+	RetryAt time.Time // when it is joined again; zero if not scheduled
+}
+
+// Status returns a snapshot of the connection state.
+func (c *Client) Status() Status {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+	st := Status{
+		Server:     c.cfg.Server,
+		Nick:       c.cfg.Nick,
+		WantNick:   c.cfg.Nick,
+		Registered: c.registered,
+		Ready:      c.readyLocked(),
+		Since:      c.since,
+	}
+	if c.tryNick != "" {
+		st.Nick = c.tryNick
+	}
+	if c.registered {
+		st.Nick = c.nick()
+		st.Lag = c.Lag()
+	}
+	if ns := c.lastSend.Load(); ns > 0 {
+		st.LastSend = time.Unix(0, ns)
+	}
+	for _, ch := range c.channels {
+		lc := strings.ToLower(ch)
+		cs := ChannelState{Name: ch, Joined: c.registered && c.joined[lc]}
+		if p := c.problems[lc]; p != nil {
+			cs.Error, cs.RetryAt = p.reason, p.retryAt
+		}
+		st.Channels = append(st.Channels, cs)
+	}
+	return st
+}
+
+// setRegisteredLocked records a registration state change. The caller holds stateMu.
+func (c *Client) setRegisteredLocked(v bool) {
+	if c.registered != v {
+		c.since = time.Now()
+	}
+	c.registered = v
+}
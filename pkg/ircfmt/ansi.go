@@ -0,0 +1,141 @@
+/*
+MIT License
+
+Copyright (c) 2025 Mikael Schultz <mikael@conf-t.se>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package ircfmt
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ansiColors maps the 99 mIRC colors to the xterm 256-color palette
+// (0-15 to the basic colors, 16-98 to their listed equivalents).
+var ansiColors = [99]int{
+	15, 0, 4, 2, 9, 1, 5, 3, 11, 10, 6, 14, 12, 13, 8, 7,
+	52, 94, 100, 58, 22, 29, 23, 24, 17, 54, 53, 89,
+	88, 130, 142, 64, 28, 35, 30, 25, 18, 91, 90, 125,
+	124, 166, 184, 106, 34, 49, 37, 33, 19, 129, 127, 161,
+	196, 208, 226, 154, 46, 86, 51, 75, 21, 171, 201, 198,
+	203, 215, 227, 191, 83, 122, 87, 111, 63, 177, 207, 205,
+	217, 223, 229, 193, 157, 158, 159, 153, 147, 183, 219, 212,
+	16, 233, 235, 237, 239, 241, 244, 247, 250, 254, 231,
+}
+
+// ansiColor is a color while rendering: a palette number (-1 or 99 for the
+// terminal default), or an RGB value when rgb is set.
+type ansiColor struct {
+	n   int
+	rgb bool
+	v   uint32
+}
+
+var noColor = ansiColor{n: -1}
+
+func (c ansiColor) sgr(base int) string {
+	switch {
+	case c.rgb:
+		return fmt.Sprintf("%d;2;%d;%d;%d", base, c.v>>16&0xff, c.v>>8&0xff, c.v&0xff)
+	case c.n >= 0 && c.n < len(ansiColors):
+		return strconv.Itoa(base) + ";5;" + strconv.Itoa(ansiColors[c.n])
+	}
+	return ""
+}
+
+// ansiState is the formatting in effect while rendering.
+type ansiState struct {
+	bold, italic, underline, strike, reverse bool
+	fg, bg                                   ansiColor
+}
+
+func (st ansiState) sgr() string {
+	codes := []string{"0"}
+	if st.bold {
+		codes = append(codes, "1")
+	}
+	if st.italic {
+		codes = append(codes, "3")
+	}
+	if st.underline {
+		codes = append(codes, "4")
+	}
+	if st.reverse {
+		codes = append(codes, "7")
+	}
+	if st.strike {
+		codes = append(codes, "9")
+	}
+	if c := st.fg.sgr(38); c != "" {
+		codes = append(codes, c)
+	}
+	if c := st.bg.sgr(48); c != "" {
+		codes = append(codes, c)
+	}
+	return "\x1b[" + strings.Join(codes, ";") + "m"
+}
+
+// ANSI renders the IRC formatting codes in s as ANSI escape sequences for a
+// terminal: palette colors as 256-color and hex colors as 24-bit codes.
+// Monospace has no terminal equivalent and is ignored.
+func ANSI(s string) string {
+	var b strings.Builder
+	def := ansiState{fg: noColor, bg: noColor}
+	st, shown := def, def
+	scan(s, func(t token) {
+		switch t.code {
+		case 0:
+			// Emit the style once, before the text it applies to
+			if st != shown {
+				b.WriteString(st.sgr())
+				shown = st
+			}
+			b.WriteString(t.text)
+		case Bold[0]:
+			st.bold = !st.bold
+		case Italic[0]:
+			st.italic = !st.italic
+		case Underline[0]:
+			st.underline = !st.underline
+		case Strikethrough[0]:
+			st.strike = !st.strike
+		case Reverse[0]:
+			st.reverse = !st.reverse
+		case Reset[0]:
+			st = def
+		case Color[0], HexColor[0]:
+			if t.fg < 0 {
+				// A bare color code resets both colors
+				st.fg, st.bg = noColor, noColor
+				return
+			}
+			st.fg = ansiColor{n: t.fg, rgb: t.hex, v: t.fgRGB}
+			if t.bg >= 0 {
+				st.bg = ansiColor{n: t.bg, rgb: t.hex, v: t.bgRGB}
+			}
+		}
+	})
+	if shown != def {
+		b.WriteString("\x1b[0m")
+	}
+	return b.String()
+}
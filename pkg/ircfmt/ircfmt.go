@@ -0,0 +1,252 @@
+/*
+MIT License
+
+Copyright (c) 2025 Mikael Schultz <mikael@conf-t.se>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package ircfmt
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// IRC formatting control codes
+const (
+	Bold          = "\x02"
+	Color         = "\x03" // followed by fg[,bg] palette numbers
+	HexColor      = "\x04" // followed by RRGGBB[,RRGGBB]
+	Monospace     = "\x11"
+	Reverse       = "\x16"
+	Italic        = "\x1D"
+	Strikethrough = "\x1E"
+	Underline     = "\x1F"
+	Reset         = "\x0F"
+)
+
+// Formatting levels a network (server and its clients) supports.
+const (
+	Extended = "extended" // everything, including the 99-color palette and hex colors
+	Basic    = "basic"    // bold, underline, reverse and the 16 classic colors
+	None     = "none"     // no formatting at all
+)
+
+// Levels lists the valid formatting levels, for validation.
+var Levels = []string{Extended, Basic, None}
+
+// palette holds the RGB values of the mIRC colors: 0-15 are the classic
+// colors and 16-98 the extended ones (99 is the client default).
+var palette = [99]uint32{
+	0xffffff, 0x000000, 0x00007f, 0x009300, 0xff0000, 0x7f0000, 0x9c009c, 0xfc7f00,
+	0xffff00, 0x00fc00, 0x009393, 0x00ffff, 0x0000fc, 0xff00ff, 0x7f7f7f, 0xd2d2d2,
+	0x470000, 0x472100, 0x474700, 0x324700, 0x004700, 0x00472c, 0x004747, 0x002747, 0x000047, 0x2e0047, 0x470047, 0x47002a,
+	0x740000, 0x743a00, 0x747400, 0x517400, 0x007400, 0x007449, 0x007474, 0x004074, 0x000074, 0x4b0074, 0x740074, 0x740045,
+	0xb50000, 0xb56300, 0xb5b500, 0x7db500, 0x00b500, 0x00b571, 0x00b5b5, 0x0063b5, 0x0000b5, 0x7500b5, 0xb500b5, 0xb5006b,
+	0xff0000, 0xff8c00, 0xffff00, 0xb2ff00, 0x00ff00, 0x00ffa0, 0x00ffff, 0x008cff, 0x0000ff, 0xa500ff, 0xff00ff, 0xff0098,
+	0xff5959, 0xffb459, 0xffff71, 0xcfff60, 0x6fff6f, 0x65ffc9, 0x6dffff, 0x59b4ff, 0x5959ff, 0xc459ff, 0xff66ff, 0xff59bc,
+	0xff9c9c, 0xffd39c, 0xffff9c, 0xe2ff9c, 0x9cff9c, 0x9cffdb, 0x9cffff, 0x9cd3ff, 0x9c9cff, 0xdc9cff, 0xff9cff, 0xff94d3,
+	0x000000, 0x131313, 0x282828, 0x363636, 0x4d4d4d, 0x656565, 0x818181, 0x9f9f9f, 0xbcbcbc, 0xe2e2e2, 0xffffff,
+}
+
+// DefaultColor is palette number 99, the client's default color.
+const DefaultColor = 99
+
+// RGB returns the RGB value of a palette color (0-98).
+func RGB(n int) uint32 {
+	if n < 0 || n >= len(palette) {
+		return 0
+	}
+	return palette[n]
+}
+
+// Nearest returns the palette color closest to rgb among the first n
+// colors (16 for the classic colors, 99 for the full palette).
+func Nearest(rgb uint32, n int) int {
+	best, bestDist := 0, -1
+	for i := 0; i < n && i < len(palette); i++ {
+		dr := int(rgb>>16&0xff) - int(palette[i]>>16&0xff)
+		dg := int(rgb>>8&0xff) - int(palette[i]>>8&0xff)
+		db := int(rgb&0xff) - int(palette[i]&0xff)
+		if d := dr*dr + dg*dg + db*db; bestDist < 0 || d < bestDist {
+			best, bestDist = i, d
+		}
+	}
+	return best
+}
+
+// ParseHex parses "#RRGGBB" (or "RRGGBB").
+func ParseHex(s string) (uint32, bool) {
+	s = strings.TrimPrefix(s, "#")
+	if len(s) != 6 {
+		return 0, false
+	}
+	v, err := strconv.ParseUint(s, 16, 32)
+	return uint32(v), err == nil
+}
+
+// token is a formatting code or a run of text in a formatted string.
+type token struct {
+	code   byte   // control code, or 0 for text
+	text   string // text, or the code with its parameters as written
+	fg, bg int    // palette colors of a Color code, -1 if absent
+	hex    bool   // HexColor code: fgRGB/bgRGB are set instead
+	fgRGB  uint32
+	bgRGB  uint32
+}
+
+// scan splits s into text runs and formatting codes.
+func scan(s string, fn func(t token)) {
+	start := 0
+	flush := func(i int) {
+		if i > start {
+			fn(token{text: s[start:i], fg: -1, bg: -1})
+		}
+	}
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch c {
+		case Bold[0], Monospace[0], Reverse[0], Italic[0], Strikethrough[0], Underline[0], Reset[0]:
+			flush(i)
+			fn(token{code: c, text: s[i : i+1], fg: -1, bg: -1})
+			i++
+		case Color[0]:
+			flush(i)
+			t := token{code: c, fg: -1, bg: -1}
+			j := i + 1
+			if n, w := number(s[j:]); w > 0 {
+				t.fg = n
+				j += w
+				if j+1 < len(s) && s[j] == ',' {
+					if n, w := number(s[j+1:]); w > 0 {
+						t.bg = n
+						j += 1 + w
+					}
+				}
+			}
+			t.text = s[i:j]
+			fn(t)
+			i = j
+		case HexColor[0]:
+			flush(i)
+			t := token{code: c, fg: -1, bg: -1, hex: true}
+			j := i + 1
+			if v, ok := ParseHex(prefix(s[j:], 6)); ok {
+				t.fg, t.fgRGB = 0, v
+				j += 6
+				if j+6 < len(s) && s[j] == ',' {
+					if v, ok := ParseHex(s[j+1 : j+7]); ok {
+						t.bg, t.bgRGB = 0, v
+						j += 7
+					}
+				}
+			}
+			t.text = s[i:j]
+			fn(t)
+			i = j
+		default:
+			i++
+			continue
+		}
+		start = i
+	}
+	flush(len(s))
+}
+
+func prefix(s string, n int) string {
+	if len(s) < n {
+		return s
+	}
+	return s[:n]
+}
+
+// number parses the one or two digit color number at the start of s and
+// returns it with the number of bytes used (0 if there is none).
+func number(s string) (int, int) {
+	n := 0
+	for n < len(s) && n < 2 && s[n] >= '0' && s[n] <= '9' {
+		n++
+	}
+	if n == 0 {
+		return -1, 0
+	}
+	v, _ := strconv.Atoi(s[:n])
+	return v, n
+}
+
+// Degrade rewrites the formatting of s for a network that only supports
+// level: Basic maps extended and hex colors to the nearest classic color
+// and drops italics, strikethrough and monospace; None strips all codes.
+// Extended (or "") returns s unchanged.
+func Degrade(s, level string) string {
+	if level == "" || level == Extended || !strings.ContainsAny(s, "\x02\x03\x04\x0F\x11\x16\x1D\x1E\x1F") {
+		return s
+	}
+	var b strings.Builder
+	colorEnd := false // the last code written was a color without background
+	scan(s, func(t token) {
+		if t.code == 0 {
+			// Keep a following ",N" from being read as a background color
+			if colorEnd && len(t.text) > 1 && t.text[0] == ',' && t.text[1] >= '0' && t.text[1] <= '9' {
+				b.WriteString(Bold + Bold)
+			}
+			b.WriteString(t.text)
+			colorEnd = false
+			return
+		}
+		colorEnd = false
+		if level == None {
+			return
+		}
+		switch t.code {
+		case Italic[0], Strikethrough[0], Monospace[0]:
+		case Color[0], HexColor[0]:
+			fg, bg := basic(t.fg, t.fgRGB, t.hex), basic(t.bg, t.bgRGB, t.hex)
+			b.WriteString(Color)
+			if fg >= 0 {
+				fmt.Fprintf(&b, "%02d", fg)
+				if bg >= 0 {
+					fmt.Fprintf(&b, ",%02d", bg)
+				} else {
+					colorEnd = true
+				}
+			}
+		default:
+			b.WriteString(t.text)
+		}
+	})
+	return b.String()
+}
+
+// basic maps a color of a Color or HexColor code to a classic color.
+func basic(n int, rgb uint32, hex bool) int {
+	switch {
+	case n < 0:
+		return -1
+	case hex:
+		return Nearest(rgb, 16)
+	case n < 16 || n == DefaultColor:
+		return n
+	case n < len(palette):
+		return Nearest(palette[n], 16)
+	default:
+		return DefaultColor
+	}
+}
@@ -0,0 +1,62 @@
+package ircfmt
+
+import "testing"
+
+// TestANSI verifies that mIRC formatting codes are rendered as ANSI escapes.
+func TestANSI(t *testing.T) {
+	// Setup test cases
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"Plain", "no codes", "no codes"},
+		{"BoldColor", "\x02\x0304down\x0F ok", "\x1b[0;1;38;5;9mdown\x1b[0m ok"},
+		{"Background", "\x0300,01x", "\x1b[0;38;5;15;48;5;0mx\x1b[0m"},
+		{"ExtendedColor", "\x0352x", "\x1b[0;38;5;196mx\x1b[0m"},
+		{"ToggleUnderline", "\x1Fa\x1Fb", "\x1b[0;4ma\x1b[0mb"},
+		{"BareColorReset", "\x0312a\x03b", "\x1b[0;38;5;12ma\x1b[0mb"},
+		{"CommaWithoutBackground", "\x034,x", "\x1b[0;38;5;9m,x\x1b[0m"},
+		{"HexColor", "\x04FF8000,000000x", "\x1b[0;38;2;255;128;0;48;2;0;0;0mx\x1b[0m"},
+		{"ItalicStrike", "\x1D\x1Ea\x0F", "\x1b[0;3;9ma\x1b[0m"},
+	}
+
+	// Run test cases
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := ANSI(test.input); got != test.expected {
+				t.Errorf("expected %q, but got %q", test.expected, got)
+			}
+		})
+	}
+}
+
+// TestDegrade verifies that formatting is reduced to what a network supports.
+func TestDegrade(t *testing.T) {
+	// Setup test cases
+	tests := []struct {
+		name     string
+		level    string
+		input    string
+		expected string
+	}{
+		{"ExtendedUnchanged", Extended, "\x1Dit\x1D \x0452,FF0000x", "\x1Dit\x1D \x0452,FF0000x"},
+		{"BasicKeepsClassic", Basic, "\x02\x0304,01x\x0F", "\x02\x0304,01x\x0F"},
+		{"BasicMapsExtendedColor", Basic, "\x0352x\x03", "\x0304x\x03"},
+		{"BasicMapsHexColor", Basic, "\x04FFFF00,00007Fx", "\x0308,02x"},
+		{"BasicDropsModernCodes", Basic, "\x1Da\x1D \x1Eb\x1E \x11c\x11 \x16d\x16", "a b c \x16d\x16"},
+		{"BasicGuardsComma", Basic, "\x04FF0000,1", "\x0304\x02\x02,1"},
+		{"BasicKeepsDefault", Basic, "\x0399,52x", "\x0399,04x"},
+		{"NoneStripsAll", None, "\x02\x0304,01a\x0F \x04FF0000b\x1Fc", "a bc"},
+		{"Plain", Basic, "no codes", "no codes"},
+	}
+
+	// Run test cases
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := Degrade(test.input, test.level); got != test.expected {
+				t.Errorf("expected %q, but got %q", test.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,361 @@
+/*
+MIT License
+
+Copyright (c) 2025 Mikael Schultz <mikael@conf-t.se>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Registry holds metrics in registration order and writes them in the
+// Prometheus text exposition format (version 0.0.4). Only the few metric
+// types ircpush needs are implemented, so no client library is required.
+type Registry struct {
+	mu      sync.Mutex
+	metrics []metric
+}
+
+type metric interface {
+	write(w io.Writer)
+}
+
+// Default is the registry served on /metrics.
+var Default = &Registry{}
+
+func (r *Registry) register(m metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.metrics = append(r.metrics, m)
+}
+
+// Write writes all metrics in the text exposition format.
+func (r *Registry) Write(w io.Writer) {
+	r.mu.Lock()
+	ms := append([]metric(nil), r.metrics...)
+	r.mu.Unlock()
+	for _, m := range ms {
+		m.write(w)
+	}
+}
+
+// CounterVec is a counter partitioned by label values.
+type CounterVec struct {
+	name, help string
+	labels     []string
+
+	mu     sync.Mutex
+	values map[string]*series
+}
+
+type series struct {
+	labels []string
+	value  float64
+}
+
+// NewCounterVec creates and registers a counter with the given label names.
+func NewCounterVec(r *Registry, name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{name: name, help: help, labels: labels, values: map[string]*series{}}
+	r.register(c)
+	return c
+}
+
+// Inc adds 1 to the series with the given label values.
+func (c *CounterVec) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+// Add adds v (>= 0) to the series with the given label values.
+func (c *CounterVec) Add(v float64, values ...string) {
+	if len(values) != len(c.labels) {
+		panic(fmt.Sprintf("metrics: %s: expected %d label values, got %d", c.name, len(c.labels), len(values)))
+	}
+	key := strings.Join(values, "\xff")
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s := c.values[key]
+	if s == nil {
+		s = &series{labels: append([]string(nil), values...)}
+		c.values[key] = s
+	}
+	s.value += v
+}
+
+// Value returns the current value of one series (0 if it does not exist).
+func (c *CounterVec) Value(values ...string) float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if s := c.values[strings.Join(values, "\xff")]; s != nil {
+		return s.value
+	}
+	return 0
+}
+
+// Sum returns the total over all series.
+func (c *CounterVec) Sum() float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var sum float64
+	for _, s := range c.values {
+		sum += s.value
+	}
+	return sum
+}
+
+func (c *CounterVec) write(w io.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	header(w, c.name, c.help, "counter")
+	keys := make([]string, 0, len(c.values))
+	for k := range c.values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		s := c.values[k]
+		fmt.Fprintf(w, "%s%s %s\n", c.name, labelString(c.labels, s.labels), formatFloat(s.value))
+	}
+}
+
+// Gauge is a single value that can go up and down.
+type Gauge struct {
+	name, help string
+	bits       atomic.Uint64
+}
+
+// NewGauge creates and registers a gauge.
+func NewGauge(r *Registry, name, help string) *Gauge {
+	g := &Gauge{name: name, help: help}
+	r.register(g)
+	return g
+}
+
+// Set sets the gauge to v.
+func (g *Gauge) Set(v float64) { g.bits.Store(math.Float64bits(v)) }
+
+// Value returns the current value.
+func (g *Gauge) Value() float64 { return math.Float64frombits(g.bits.Load()) }
+
+func (g *Gauge) write(w io.Writer) {
+	header(w, g.name, g.help, "gauge")
+	fmt.Fprintf(w, "%s %s\n", g.name, formatFloat(g.Value()))
+}
+
+// GaugeVec is a gauge partitioned by label values.
+type GaugeVec struct {
+	name, help string
+	labels     []string
+
+	mu     sync.Mutex
+	values map[string]*series
+}
+
+// NewGaugeVec creates and registers a gauge with the given label names.
+func NewGaugeVec(r *Registry, name, help string, labels ...string) *GaugeVec {
+	g := &GaugeVec{name: name, help: help, labels: labels, values: map[string]*series{}}
+	r.register(g)
+	return g
+}
+
+// Set sets the series with the given label values to v.
+func (g *GaugeVec) Set(v float64, values ...string) {
+	if len(values) != len(g.labels) {
+		panic(fmt.Sprintf("metrics: %s: expected %d label values, got %d", g.name, len(g.labels), len(values)))
+	}
+	key := strings.Join(values, "\xff")
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	s := g.values[key]
+	if s == nil {
+		s = &series{labels: append([]string(nil), values...)}
+		g.values[key] = s
+	}
+	s.value = v
+}
+
+// Value returns the current value of one series (0 if it does not exist).
+func (g *GaugeVec) Value(values ...string) float64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if s := g.values[strings.Join(values, "\xff")]; s != nil {
+		return s.value
+	}
+	return 0
+}
+
+func (g *GaugeVec) write(w io.Writer) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	header(w, g.name, g.help, "gauge")
+	keys := make([]string, 0, len(g.values))
+	for k := range g.values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		s := g.values[k]
+		fmt.Fprintf(w, "%s%s %s\n", g.name, labelString(g.labels, s.labels), formatFloat(s.value))
+	}
+}
+
+// Histogram counts observations in cumulative buckets.
+type Histogram struct {
+	name, help string
+	buckets    []float64 // upper bounds, ascending, without +Inf
+
+	mu     sync.Mutex
+	counts []uint64 // per bucket (not cumulative), last is +Inf
+	sum    float64
+	count  uint64
+}
+
+// NewHistogram creates and registers a histogram with the given bucket upper bounds.
+func NewHistogram(r *Registry, name, help string, buckets []float64) *Histogram {
+	b := append([]float64(nil), buckets...)
+	sort.Float64s(b)
+	h := &Histogram{name: name, help: help, buckets: b, counts: make([]uint64, len(b)+1)}
+	r.register(h)
+	return h
+}
+
+// Observe records one value.
+func (h *Histogram) Observe(v float64) {
+	i := sort.SearchFloat64s(h.buckets, v) // first bucket with bound >= v
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.counts[i]++
+	h.sum += v
+	h.count++
+}
+
+// Count returns the number of observations.
+func (h *Histogram) Count() uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.count
+}
+
+func (h *Histogram) write(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	header(w, h.name, h.help, "histogram")
+	var cum uint64
+	for i, b := range h.buckets {
+		cum += h.counts[i]
+		fmt.Fprintf(w, "%s_bucket{le=\"%s\"} %d\n", h.name, formatFloat(b), cum)
+	}
+	cum += h.counts[len(h.buckets)]
+	fmt.Fprintf(w, "%s_bucket{le=\"+Inf\"} %d\n", h.name, cum)
+	fmt.Fprintf(w, "%s_sum %s\n", h.name, formatFloat(h.sum))
+	fmt.Fprintf(w, "%s_count %d\n", h.name, h.count)
+}
+
+func header(w io.Writer, name, help, typ string) {
+	help = strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(help)
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+func labelString(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	esc := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, n := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, "%s=\"%s\"", n, esc.Replace(values[i]))
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// Host returns the IP part of a remote address, for use as a source label
+// (ports change with every connection and would explode cardinality).
+func Host(addr string) string {
+	if h, _, err := net.SplitHostPort(addr); err == nil {
+		return h
+	}
+	return addr
+}
+
+var sourceLabels atomic.Bool
+
+// SetSourceLabels enables the per-address source label, see Source.
+func SetSourceLabels(on bool) { sourceLabels.Store(on) }
+
+// Source returns the source label for a remote address: its IP with
+// metrics.source_labels, otherwise "" so that the number of series stays
+// bounded however many clients connect.
+func Source(addr string) string {
+	if !sourceLabels.Load() {
+		return ""
+	}
+	return Host(addr)
+}
+
+// Metrics exported by ircpush.
+var (
+	LinesReceived = NewCounterVec(Default, "ircpush_lines_received_total",
+		"Lines received by input and source address (with metrics.source_labels).", "input", "source")
+	LinesDropped = NewCounterVec(Default, "ircpush_lines_dropped_total",
+		"Lines not sent to IRC by input and reason.", "input", "reason")
+	Redactions = NewCounterVec(Default, "ircpush_redactions_total",
+		"Matches masked by redaction rules per rule and channel.", "rule", "channel")
+	IRCMessagesSent = NewCounterVec(Default, "ircpush_irc_messages_sent_total",
+		"Messages written to the IRC connection per channel (counted once all segments are written).", "channel")
+	IRCSegmentsSent = NewCounterVec(Default, "ircpush_irc_segments_sent_total",
+		"PRIVMSG lines written to the connection per channel (after splitting).", "channel")
+	IRCReconnects = NewCounterVec(Default, "ircpush_irc_reconnects_total",
+		"Reconnect attempts by network and result.", "network", "result")
+	IRCConnected = NewGaugeVec(Default, "ircpush_irc_connected",
+		"1 while registered with the IRC server, 0 otherwise.", "network")
+	QueueDepth = NewGaugeVec(Default, "ircpush_irc_queue_depth",
+		"Messages waiting in the IRC send queue.", "network")
+	IRCLag = NewGaugeVec(Default, "ircpush_irc_lag_seconds",
+		"Round-trip time of the last client PING to the IRC server.", "network")
+	IRCPingTimeouts = NewCounterVec(Default, "ircpush_irc_ping_timeouts_total",
+		"Connections closed because the server did not answer a client PING in time.", "network")
+	QueueWait = NewHistogram(Default, "ircpush_irc_queue_wait_seconds",
+		"Time a segment waited in the IRC send queue (queueing + rate limiting) before it was written to the connection.",
+		[]float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60})
+)
@@ -0,0 +1,96 @@
+package metrics
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// TestExposition verifies the text format of counters, gauges and histograms.
+func TestExposition(t *testing.T) {
+	r := &Registry{}
+	c := NewCounterVec(r, "test_lines_total", "Lines.", "input", "source")
+	g := NewGauge(r, "test_connected", "Connected.")
+	gv := NewGaugeVec(r, "test_depth", "Depth.", "network")
+	h := NewHistogram(r, "test_latency_seconds", "Latency.", []float64{0.1, 1})
+
+	c.Inc("tcp", "10.0.0.2")
+	c.Add(2, "tcp", "10.0.0.1")
+	c.Inc("http", `a"b\c`)
+	g.Set(1)
+	gv.Set(3, "libera")
+	gv.Set(5, "default")
+	gv.Set(2, "libera")
+	h.Observe(0.05)
+	h.Observe(0.1)
+	h.Observe(3)
+
+	var buf bytes.Buffer
+	r.Write(&buf)
+	expected := `# HELP test_lines_total Lines.
+# TYPE test_lines_total counter
+test_lines_total{input="http",source="a\"b\\c"} 1
+test_lines_total{input="tcp",source="10.0.0.1"} 2
+test_lines_total{input="tcp",source="10.0.0.2"} 1
+# HELP test_connected Connected.
+# TYPE test_connected gauge
+test_connected 1
+# HELP test_depth Depth.
+# TYPE test_depth gauge
+test_depth{network="default"} 5
+test_depth{network="libera"} 2
+# HELP test_latency_seconds Latency.
+# TYPE test_latency_seconds histogram
+test_latency_seconds_bucket{le="0.1"} 2
+test_latency_seconds_bucket{le="1"} 2
+test_latency_seconds_bucket{le="+Inf"} 3
+test_latency_seconds_sum 3.15
+test_latency_seconds_count 3
+`
+	if got := buf.String(); got != expected {
+		t.Errorf("unexpected exposition:\n%s\nexpected:\n%s", got, expected)
+	}
+	if c.Value("tcp", "10.0.0.1") != 2 || c.Sum() != 4 {
+		t.Errorf("unexpected counter values")
+	}
+}
+
+// TestHost tests stripping the port from remote addresses.
+func TestHost(t *testing.T) {
+	for in, expected := range map[string]string{
+		"10.0.0.1:514":        "10.0.0.1",
+		"[2001:db8::1]:40000": "2001:db8::1",
+		"10.0.0.1":            "10.0.0.1",
+	} {
+		if got := Host(in); got != expected {
+			t.Errorf("Host(%q): expected %q, but got %q", in, expected, got)
+		}
+	}
+}
+
+// TestSource verifies that the per-address source label is opt-in.
+func TestSource(t *testing.T) {
+	defer SetSourceLabels(false)
+	if got := Source("10.0.0.1:514"); got != "" {
+		t.Errorf("expected no source label by default, got %q", got)
+	}
+	SetSourceLabels(true)
+	if got := Source("10.0.0.1:514"); got != "10.0.0.1" {
+		t.Errorf("expected the source IP, got %q", got)
+	}
+}
+
+// TestDefaultRegistry verifies that all ircpush metrics are registered.
+func TestDefaultRegistry(t *testing.T) {
+	var buf bytes.Buffer
+	Default.Write(&buf)
+	for _, name := range []string{
+		"ircpush_lines_received_total", "ircpush_lines_dropped_total", "ircpush_irc_messages_sent_total",
+		"ircpush_irc_segments_sent_total", "ircpush_irc_reconnects_total", "ircpush_irc_connected",
+		"ircpush_irc_queue_depth", "ircpush_irc_queue_wait_seconds",
+	} {
+		if !strings.Contains(buf.String(), "# TYPE "+name+" ") {
+			t.Errorf("metric %s not registered", name)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+/*
+MIT License
+
+Copyright (c) 2025 Mikael Schultz <mikael@conf-t.se>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// Server exposes the Default registry over HTTP.
+type Server struct {
+	ListenAddr string
+	Path       string // default "/metrics"
+
+	// Optional logging sink; if nil, logs go to stderr.
+	Logger Logger
+
+	mux  *http.ServeMux
+	srv  *http.Server
+	wg   sync.WaitGroup
+	once sync.Once
+}
+
+// Logger is a minimal logger interface.
+type Logger interface {
+	Printf(format string, v ...any)
+}
+
+func (s *Server) logf(format string, v ...any) {
+	if s.Logger != nil {
+		s.Logger.Printf(format, v...)
+		return
+	}
+	fmt.Fprintf(os.Stderr, format+"\n", v...)
+}
+
+// Handler serves the Default registry in the Prometheus text format.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		Default.Write(w)
+	})
+}
+
+// Handle registers an additional handler on the listener (before Start).
+func (s *Server) Handle(pattern string, h http.Handler) {
+	if s.mux == nil {
+		s.mux = http.NewServeMux()
+	}
+	s.mux.Handle(pattern, h)
+}
+
+// Start begins serving until ctx is done or Stop is called.
+func (s *Server) Start(ctx context.Context) error {
+	if s.ListenAddr == "" {
+		return fmt.Errorf("metrics server: ListenAddr is empty")
+	}
+	if s.Path == "" {
+		s.Path = "/metrics"
+	}
+	ln, err := net.Listen("tcp", s.ListenAddr)
+	if err != nil {
+		return fmt.Errorf("listen %s: %w", s.ListenAddr, err)
+	}
+	s.Handle(s.Path, Handler())
+	s.srv = &http.Server{
+		Handler:           s.mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	s.logf("metrics: listening on %s%s", s.ListenAddr, s.Path)
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		if err := s.srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.logf("metrics: serve error: %v", err)
+		}
+	}()
+	go func() {
+		<-ctx.Done()
+		_ = s.Stop()
+	}()
+	return nil
+}
+
+// Stop shuts down the listener.
+func (s *Server) Stop() error {
+	var err error
+	s.once.Do(func() {
+		if s.srv != nil {
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+			err = s.srv.Shutdown(ctx)
+		}
+	})
+	s.wg.Wait()
+	return err
+}
@@ -0,0 +1,142 @@
+/*
+MIT License
+
+Copyright (c) 2025 Mikael Schultz <mikael@conf-t.se>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package pipeline
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/bitcanon/ircpush/pkg/config"
+	"github.com/bitcanon/ircpush/pkg/metrics"
+)
+
+// Network is an IRC network messages can be sent to.
+type Network struct {
+	Name string
+	Out  Sender // the network's client, or the spool wrapping it
+}
+
+// Networks is a Sender for several IRC networks, each with its own
+// connection. Channels of the first (primary) network are addressed as
+// "#channel", those of the others as "network/#channel". A broadcast goes to
+// the channels of every network, each sending to its own.
+type Networks struct {
+	nets []Network
+
+	// Optional logging sink; if nil, logs go to stderr.
+	Logger Logger
+}
+
+// NewNetworks creates a sender for nets; the first one is the primary network.
+func NewNetworks(nets ...Network) *Networks {
+	return &Networks{nets: nets}
+}
+
+func (n *Networks) logf(format string, v ...any) {
+	if n.Logger != nil {
+		n.Logger.Printf(format, v...)
+		return
+	}
+	fmt.Fprintf(os.Stderr, format+"\n", v...)
+}
+
+// SendTo sends msg to channels, grouped by network.
+func (n *Networks) SendTo(channels []string, msg string) {
+	byNet := make([][]string, len(n.nets))
+	for _, target := range channels {
+		i, ch := n.lookup(target)
+		if i < 0 {
+			n.logf("pipeline: dropped message to %s: unknown network", target)
+			metrics.LinesDropped.Inc("irc", "unknown_network")
+			continue
+		}
+		byNet[i] = append(byNet[i], ch)
+	}
+	for i, chs := range byNet {
+		if len(chs) > 0 {
+			n.nets[i].Out.SendTo(chs, msg)
+		}
+	}
+}
+
+// Broadcast sends msg to the channels of every network.
+func (n *Networks) Broadcast(msg string) {
+	for _, net := range n.nets {
+		net.Out.Broadcast(msg)
+	}
+}
+
+// lookup returns the index of the network of target and the channel name on
+// that network, or -1 for an unknown network.
+func (n *Networks) lookup(target string) (int, string) {
+	names := make([]string, len(n.nets))
+	for i, nw := range n.nets {
+		names[i] = nw.Name
+	}
+	net, ch := config.SplitChannel(target, names)
+	if net == "" {
+		return 0, ch
+	}
+	for i, nw := range n.nets {
+		if strings.EqualFold(nw.Name, net) {
+			return i, ch
+		}
+	}
+	return -1, ch
+}
+
+// ChannelName adds the "#" prefix to a channel name that lacks one. Targets
+// of the form "network/#channel" are returned as they are.
+func ChannelName(target string) string {
+	target = strings.TrimSpace(target)
+	if target == "" || strings.HasPrefix(target, "#") || strings.HasPrefix(target, "&") {
+		return target
+	}
+	if _, ch, ok := strings.Cut(target, "/"); ok && (strings.HasPrefix(ch, "#") || strings.HasPrefix(ch, "&")) {
+		return target
+	}
+	return "#" + target
+}
+
+// Qualify returns the target of channel on network: "#channel" on the
+// primary network ("" or Primary), "network/#channel" on the others.
+func (p *Pipeline) Qualify(network, channel string) string {
+	if network == "" || strings.EqualFold(network, p.Primary) {
+		return channel
+	}
+	return network + "/" + channel
+}
+
+// Canonical returns target the way channels are named in the pipeline (for
+// mutes, dedup and channel filters): with the "#" prefix, and without the
+// network when it is on the primary network. A prefix that is not one of
+// Networks stays part of the channel name.
+func (p *Pipeline) Canonical(target string) string {
+	target = ChannelName(target)
+	if net, ch := config.SplitChannel(target, p.Networks); net != "" {
+		return p.Qualify(net, ch)
+	}
+	return target
+}
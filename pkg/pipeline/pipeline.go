@@ -0,0 +1,379 @@
+/*
+MIT License
+
+Copyright (c) 2025 Mikael Schultz <mikael@conf-t.se>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package pipeline
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bitcanon/ircpush/pkg/acl"
+	"github.com/bitcanon/ircpush/pkg/dedup"
+	"github.com/bitcanon/ircpush/pkg/highlight"
+	"github.com/bitcanon/ircpush/pkg/metrics"
+	"github.com/bitcanon/ircpush/pkg/redact"
+)
+
+// Message is a line received by an input, before highlighting.
+type Message struct {
+	Input      string   // name of the input that received it ("tcp", "syslog", ...)
+	Source     string   // remote address of the sender
+	Identities []string // verified TLS client certificate names (CN, then SANs), if any
+	Targets    []string // explicit target channels; empty means routed (or broadcast)
+	Text       string
+
+	// Syslog metadata for routing, empty for other inputs
+	Severity string // keyword, e.g. "err"
+	Facility string // keyword, e.g. "local0"
+}
+
+// Sender delivers highlighted messages to IRC.
+// It is implemented by *irc.Client and by *spool.Spool, which wraps it.
+type Sender interface {
+	SendTo(channels []string, msg string)
+	Broadcast(msg string)
+}
+
+// Pipeline suppresses repeats, applies redaction and highlighting per target
+// channel and forwards messages to IRC. It is shared by all inputs, so reloading rules
+// only has to happen in one place.
+type Pipeline struct {
+	IRC Sender
+
+	// Channels are the configured IRC channels. Broadcasts are expanded here
+	// so dedup and channel-specific highlighting apply to them, and no message
+	// is sent to any other channel; when empty, nothing is sent. Use
+	// SetChannels at runtime.
+	// Channels of other than the primary network are named "network/#channel".
+	Channels []string
+
+	// Primary is the name of the primary IRC network (optional). Targets
+	// "Primary/#channel" are the same as "#channel".
+	Primary string
+
+	// Networks are the names of all IRC networks (optional). Only these are
+	// split off "network/#channel" targets; any other prefix is part of the
+	// channel name.
+	Networks []string
+
+	// Highlighter, redactor, router, deduper and ACL can be swapped at runtime.
+	mu    sync.RWMutex
+	hl    *highlight.Highlighter
+	rd    *redact.Redactor
+	rt    *Router
+	dd    *dedup.Deduper
+	acl   *acl.List
+	mutes map[string]time.Time // lower-cased channel -> muted until
+
+	// Optional logging sink; if nil, logs go to stderr.
+	Logger Logger
+}
+
+// Logger is a minimal logger interface.
+type Logger interface {
+	Printf(format string, v ...any)
+}
+
+// New creates a pipeline sending to out with the given highlighter (may be nil).
+func New(out Sender, hl *highlight.Highlighter) *Pipeline {
+	return &Pipeline{IRC: out, hl: hl}
+}
+
+func (p *Pipeline) logf(format string, v ...any) {
+	if p.Logger != nil {
+		p.Logger.Printf(format, v...)
+		return
+	}
+	fmt.Fprintf(os.Stderr, format+"\n", v...)
+}
+
+// SetHighlighter replaces the active highlighter safely at runtime.
+func (p *Pipeline) SetHighlighter(h *highlight.Highlighter) {
+	p.mu.Lock()
+	p.hl = h
+	p.mu.Unlock()
+	p.logf("pipeline: highlighter reloaded")
+}
+
+// SetRedactor replaces the redaction rules safely at runtime (nil disables redaction).
+func (p *Pipeline) SetRedactor(r *redact.Redactor) {
+	p.mu.Lock()
+	p.rd = r
+	p.mu.Unlock()
+	p.logf("pipeline: redaction rules reloaded")
+}
+
+// SetRouter replaces the routing rules safely at runtime (nil disables routing).
+func (p *Pipeline) SetRouter(r *Router) {
+	p.mu.Lock()
+	p.rt = r
+	p.mu.Unlock()
+	p.logf("pipeline: routes reloaded")
+}
+
+// SetACL replaces the per-source access list safely at runtime (nil allows all).
+func (p *Pipeline) SetACL(l *acl.List) {
+	p.mu.Lock()
+	p.acl = l
+	p.mu.Unlock()
+	p.logf("pipeline: acl reloaded")
+}
+
+// SetChannels replaces the configured channels safely at runtime.
+func (p *Pipeline) SetChannels(channels []string) {
+	p.mu.Lock()
+	p.Channels = append([]string(nil), channels...)
+	p.mu.Unlock()
+}
+
+// ConfiguredChannels returns the configured channels.
+func (p *Pipeline) ConfiguredChannels() []string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return append([]string(nil), p.Channels...)
+}
+
+// Mute drops all messages for channel until d has passed.
+func (p *Pipeline) Mute(channel string, d time.Duration) {
+	channel = p.Canonical(channel)
+	p.mu.Lock()
+	if p.mutes == nil {
+		p.mutes = map[string]time.Time{}
+	}
+	p.mutes[strings.ToLower(channel)] = time.Now().Add(d)
+	p.mu.Unlock()
+	p.logf("pipeline: %s muted for %s", channel, d)
+}
+
+// Unmute lifts a mute early. It reports whether channel was muted.
+func (p *Pipeline) Unmute(channel string) bool {
+	channel = p.Canonical(channel)
+	lc := strings.ToLower(channel)
+	p.mu.Lock()
+	until, ok := p.mutes[lc]
+	delete(p.mutes, lc)
+	p.mu.Unlock()
+	ok = ok && time.Now().Before(until)
+	if ok {
+		p.logf("pipeline: %s unmuted", channel)
+	}
+	return ok
+}
+
+// Mutes returns the active mutes (lower-cased channel -> muted until).
+func (p *Pipeline) Mutes() map[string]time.Time {
+	now := time.Now()
+	out := map[string]time.Time{}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for ch, until := range p.mutes {
+		if now.Before(until) {
+			out[ch] = until
+		} else {
+			delete(p.mutes, ch)
+		}
+	}
+	return out
+}
+
+func (p *Pipeline) muted(channel string) bool {
+	p.mu.RLock()
+	until, ok := p.mutes[strings.ToLower(channel)]
+	p.mu.RUnlock()
+	return ok && time.Now().Before(until)
+}
+
+// AllowSource reports whether inputs should accept anything from addr.
+// Inputs call it when a connection or packet arrives.
+func (p *Pipeline) AllowSource(addr string) bool {
+	p.mu.RLock()
+	l := p.acl
+	p.mu.RUnlock()
+	return l.AllowSource(addr)
+}
+
+// AllowChannel reports whether addr may target channel (configured channels and acl).
+func (p *Pipeline) AllowChannel(addr, channel string) bool {
+	p.mu.RLock()
+	l := p.acl
+	p.mu.RUnlock()
+	reason, _ := p.deny(l, addr, nil, p.Canonical(channel))
+	return reason == ""
+}
+
+// Send redacts and highlights text and sends it to channels, bypassing acl, routes,
+// mutes and dedup. It is meant for operators; channels must be configured.
+func (p *Pipeline) Send(channels []string, text string) error {
+	for _, ch := range channels {
+		if !p.configured(p.Canonical(ch)) {
+			return fmt.Errorf("%s is not a configured channel", ch)
+		}
+	}
+	for _, ch := range channels {
+		p.deliver(p.Canonical(ch), text)
+	}
+	return nil
+}
+
+// AllowPeer reports whether addr with the given certificate identities may send at all.
+func (p *Pipeline) AllowPeer(addr string, ids []string) bool {
+	p.mu.RLock()
+	l := p.acl
+	p.mu.RUnlock()
+	return l.AllowPeer(addr, ids)
+}
+
+// SetDedup replaces the deduper (nil disables dedup). The previous one is
+// stopped, which sends its pending "(repeated N times)" summaries.
+func (p *Pipeline) SetDedup(d *dedup.Deduper) {
+	if d != nil {
+		d.Emit = p.deliver
+		d.Start()
+	}
+	p.mu.Lock()
+	old := p.dd
+	p.dd = d
+	p.mu.Unlock()
+	if old != nil {
+		old.Stop()
+	}
+}
+
+// Close stops the deduper, flushing pending summaries.
+func (p *Pipeline) Close() {
+	p.SetDedup(nil)
+}
+
+// Submit redacts and highlights m for each target channel and sends it.
+// Without explicit targets the routes pick the channels; if they don't,
+// the message is broadcast to all configured channels. It returns the
+// channels the message was sent to, leaving out those denied, muted or
+// suppressed as a repeat.
+func (p *Pipeline) Submit(m Message) []string {
+	p.mu.RLock()
+	rt, l, channels := p.rt, p.acl, p.Channels
+	p.mu.RUnlock()
+
+	if !l.AllowPeer(m.Source, m.Identities) {
+		p.logf("pipeline: rejected %s line from %s: source not allowed by acl", m.Input, m.Source)
+		metrics.LinesDropped.Inc(m.Input, "acl")
+		return nil
+	}
+	targets := m.Targets
+	if len(targets) == 0 && rt != nil {
+		targets = rt.Route(m)
+	}
+	if len(targets) == 0 {
+		targets = channels
+	}
+	if len(targets) == 0 {
+		p.logf("pipeline: rejected %s line from %s: no channels configured", m.Input, m.Source)
+		metrics.LinesDropped.Inc(m.Input, "channel_not_allowed")
+		return nil
+	}
+	var sent []string
+	for _, ch := range targets {
+		ch = p.Canonical(ch)
+		if reason, label := p.deny(l, m.Source, m.Identities, ch); reason != "" {
+			p.logf("pipeline: rejected %s line from %s to %s: %s", m.Input, m.Source, ch, reason)
+			metrics.LinesDropped.Inc(m.Input, label)
+			continue
+		}
+		if p.muted(ch) {
+			metrics.LinesDropped.Inc(m.Input, "muted")
+			continue
+		}
+		if !p.allow(ch, m.Text) {
+			metrics.LinesDropped.Inc(m.Input, "dedup")
+			continue
+		}
+		p.deliver(ch, m.Text)
+		sent = append(sent, ch)
+	}
+	return sent
+}
+
+// deny returns why source may not send to channel and the matching metrics
+// reason label, or "" if it may.
+func (p *Pipeline) deny(l *acl.List, source string, ids []string, channel string) (string, string) {
+	if !p.configured(channel) {
+		return "not a configured channel", "channel_not_allowed"
+	}
+	if !l.AllowChannel(source, ids, channel) {
+		return "channel not allowed for this source by acl", "acl"
+	}
+	return "", ""
+}
+
+// configured reports whether channel is one of the configured channels.
+func (p *Pipeline) configured(channel string) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return Listed(p.Channels, channel)
+}
+
+// Listed reports whether channel is in the allowlist channels, ignoring
+// case. An empty list allows nothing.
+func Listed(channels []string, channel string) bool {
+	for _, ch := range channels {
+		if strings.EqualFold(ch, channel) {
+			return true
+		}
+	}
+	return false
+}
+
+// deliver redacts and highlights msg for channel and sends it ("" = broadcast).
+func (p *Pipeline) deliver(channel, msg string) {
+	p.mu.RLock()
+	rd := p.rd
+	p.mu.RUnlock()
+	if rd != nil {
+		msg = rd.Apply(channel, msg)
+	}
+	if channel == "" {
+		p.IRC.Broadcast(p.applyHL("", msg))
+		return
+	}
+	p.IRC.SendTo([]string{channel}, p.applyHL(channel, msg))
+}
+
+func (p *Pipeline) allow(channel, msg string) bool {
+	p.mu.RLock()
+	dd := p.dd
+	p.mu.RUnlock()
+	return dd == nil || dd.Allow(channel, msg)
+}
+
+func (p *Pipeline) applyHL(channel, msg string) string {
+	p.mu.RLock()
+	hl := p.hl
+	p.mu.RUnlock()
+	if hl == nil {
+		return msg
+	}
+	return hl.ApplyFor(channel, msg)
+}
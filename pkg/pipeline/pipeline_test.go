@@ -0,0 +1,138 @@
+package pipeline
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/bitcanon/ircpush/pkg/acl"
+	"github.com/bitcanon/ircpush/pkg/config"
+)
+
+// fakeSender records what the pipeline sends.
+type fakeSender struct {
+	sent []string
+}
+
+func (f *fakeSender) SendTo(channels []string, msg string) {
+	for _, ch := range channels {
+		f.sent = append(f.sent, ch+" "+msg)
+	}
+}
+
+func (f *fakeSender) Broadcast(msg string) { f.sent = append(f.sent, "* "+msg) }
+
+type discard struct{}
+
+func (discard) Printf(string, ...any) {}
+
+// TestSubmitAllowlistAndACL verifies that only configured channels permitted by the acl receive messages.
+func TestSubmitAllowlistAndACL(t *testing.T) {
+	out := &fakeSender{}
+	p := New(out, nil)
+	p.Logger = discard{}
+	p.Channels = []string{"#network", "#security"}
+	l, err := acl.New([]config.ACLRule{
+		{Source: []string{"10.0.0.0/8"}, Channels: []string{"#network"}},
+		{Source: []string{"127.0.0.1"}},
+	})
+	if err != nil {
+		t.Fatalf("acl.New: %v", err)
+	}
+	p.SetACL(l)
+
+	p.Submit(Message{Input: "tcp", Source: "127.0.0.1:1", Targets: []string{"#random", "#Security"}, Text: "a"})
+	p.Submit(Message{Input: "tcp", Source: "10.0.0.1:1", Text: "b"})
+	p.Submit(Message{Input: "tcp", Source: "192.0.2.1:1", Text: "c"})
+
+	expected := []string{"#Security a", "#network b"}
+	if !reflect.DeepEqual(out.sent, expected) {
+		t.Errorf("expected %#v, but got %#v", expected, out.sent)
+	}
+	if p.AllowSource("192.0.2.1:1") || !p.AllowChannel("10.0.0.1:1", "#network") || p.AllowChannel("10.0.0.1:1", "#security") {
+		t.Errorf("unexpected AllowSource/AllowChannel results")
+	}
+}
+
+// TestSubmitNoChannels verifies that nothing is sent, not even a broadcast,
+// when no channels are configured.
+func TestSubmitNoChannels(t *testing.T) {
+	out := &fakeSender{}
+	p := New(out, nil)
+	p.Logger = discard{}
+
+	if sent := p.Submit(Message{Input: "tcp", Source: "127.0.0.1:1", Text: "a"}); sent != nil {
+		t.Errorf("expected no channels for a broadcast, got %#v", sent)
+	}
+	if sent := p.Submit(Message{Input: "tcp", Source: "127.0.0.1:1", Targets: []string{"#ops"}, Text: "b"}); sent != nil {
+		t.Errorf("expected no channels for a target, got %#v", sent)
+	}
+	if err := p.Send([]string{"#ops"}, "c"); err == nil {
+		t.Errorf("expected an error sending without configured channels")
+	}
+	if p.AllowChannel("127.0.0.1:1", "#ops") {
+		t.Errorf("expected AllowChannel to deny without configured channels")
+	}
+	if len(out.sent) != 0 {
+		t.Errorf("expected nothing sent, but got %#v", out.sent)
+	}
+}
+
+// TestMuteAndSend verifies that muted channels are skipped, and that Send
+// bypasses mutes but not the configured channel list.
+func TestMuteAndSend(t *testing.T) {
+	out := &fakeSender{}
+	p := New(out, nil)
+	p.Logger = discard{}
+	p.SetChannels([]string{"#network", "#server"})
+
+	p.Mute("#Network", time.Hour)
+	p.Submit(Message{Input: "tcp", Source: "127.0.0.1:1", Text: "a"})
+	if err := p.Send([]string{"#network"}, "b"); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if err := p.Send([]string{"#random"}, "c"); err == nil {
+		t.Errorf("expected an error sending to an unconfigured channel")
+	}
+	if m := p.Mutes(); len(m) != 1 {
+		t.Errorf("expected one active mute, but got %v", m)
+	}
+	if !p.Unmute("#network") || p.Unmute("#network") {
+		t.Errorf("expected Unmute to succeed exactly once")
+	}
+	p.Submit(Message{Input: "tcp", Source: "127.0.0.1:1", Targets: []string{"#network"}, Text: "d"})
+
+	expected := []string{"#server a", "#network b", "#network d"}
+	if !reflect.DeepEqual(out.sent, expected) {
+		t.Errorf("expected %#v, but got %#v", expected, out.sent)
+	}
+}
+
+// TestNetworks verifies "network/#channel" targets, the primary network
+// alias and that broadcasts reach each network's own channels.
+func TestNetworks(t *testing.T) {
+	internal, libera := &fakeSender{}, &fakeSender{}
+	nets := NewNetworks(Network{Name: "internal", Out: internal}, Network{Name: "libera", Out: libera})
+	nets.Logger = discard{}
+	p := New(nets, nil)
+	p.Logger = discard{}
+	p.Primary = "internal"
+	p.Networks = []string{"internal", "libera"}
+	p.SetChannels([]string{"#ops", "libera/#ops", "libera/#bots"})
+
+	p.Submit(Message{Input: "tcp", Source: "127.0.0.1:1", Targets: []string{"Internal/#ops", "libera/#bots", "oftc/#ops"}, Text: "a"})
+	p.Submit(Message{Input: "tcp", Source: "127.0.0.1:1", Text: "b"})
+	p.Mute("internal/#OPS", time.Hour)
+	p.Submit(Message{Input: "tcp", Source: "127.0.0.1:1", Targets: []string{"#ops", "libera/#ops"}, Text: "c"})
+	nets.Broadcast("d")
+
+	if expected := []string{"#ops a", "#ops b", "* d"}; !reflect.DeepEqual(internal.sent, expected) {
+		t.Errorf("internal: expected %#v, but got %#v", expected, internal.sent)
+	}
+	if expected := []string{"#bots a", "#ops b", "#bots b", "#ops c", "* d"}; !reflect.DeepEqual(libera.sent, expected) {
+		t.Errorf("libera: expected %#v, but got %#v", expected, libera.sent)
+	}
+	if !p.AllowChannel("127.0.0.1:1", "libera/#bots") || p.AllowChannel("127.0.0.1:1", "internal/#bots") {
+		t.Errorf("unexpected AllowChannel results")
+	}
+}
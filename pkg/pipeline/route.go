@@ -0,0 +1,213 @@
+/*
+MIT License
+
+Copyright (c) 2025 Mikael Schultz <mikael@conf-t.se>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package pipeline
+
+import (
+	"fmt"
+	"net"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/bitcanon/ircpush/pkg/acl"
+	"github.com/bitcanon/ircpush/pkg/config"
+)
+
+// severityNames are the syslog severity keywords in numeric order (RFC 5424).
+var severityNames = []string{"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug"}
+
+// severityAliases maps common spellings to the keywords above.
+var severityAliases = map[string]string{
+	"emergency": "emerg", "panic": "emerg", "critical": "crit",
+	"error": "err", "warn": "warning", "informational": "info",
+}
+
+// Router picks target channels for messages without explicit targets.
+// Rules are evaluated in order; a matching rule adds its channels and stops
+// evaluation unless it has continue set. If nothing matched, the default
+// channels are used (empty => broadcast).
+type Router struct {
+	rules []route
+	def   []string
+}
+
+type route struct {
+	name     string
+	re       *regexp.Regexp
+	nets     []*net.IPNet
+	ids      []string // lower-cased glob patterns
+	inputs   map[string]bool
+	severity map[string]bool
+	facility map[string]bool
+	channels []string
+	cont     bool
+}
+
+// NewRouter compiles the routes config. Any invalid rule is an error, so a
+// bad reload never half-applies.
+func NewRouter(rc config.RoutesConfig) (*Router, error) {
+	r := &Router{def: chanNames(rc.Default)}
+	for i, rule := range rc.Rules {
+		name := rule.Name
+		if name == "" {
+			name = "#" + strconv.Itoa(i+1)
+		}
+		rt, err := compileRoute(rule)
+		if err != nil {
+			return nil, fmt.Errorf("routes: rule %s: %w", name, err)
+		}
+		rt.name = name
+		r.rules = append(r.rules, rt)
+	}
+	return r, nil
+}
+
+func compileRoute(rule config.RouteRule) (route, error) {
+	rt := route{channels: chanNames(rule.Channels), cont: rule.Continue}
+	if len(rule.Channels) == 0 {
+		return rt, fmt.Errorf("channels must not be empty")
+	}
+	if rule.Match != "" {
+		re, err := regexp.Compile(rule.Match)
+		if err != nil {
+			return rt, fmt.Errorf("match: %w", err)
+		}
+		rt.re = re
+	}
+	nets, err := acl.ParseSources(rule.Source)
+	if err != nil {
+		return rt, err
+	}
+	rt.nets = nets
+	for _, id := range rule.Identity {
+		if id = strings.ToLower(strings.TrimSpace(id)); id != "" {
+			if _, err := filepath.Match(id, ""); err != nil {
+				return rt, fmt.Errorf("identity %q: %w", id, err)
+			}
+			rt.ids = append(rt.ids, id)
+		}
+	}
+	rt.inputs = lowerSet(rule.Input)
+	rt.facility = lowerSet(rule.Facility)
+	if len(rule.Severity) > 0 {
+		rt.severity = map[string]bool{}
+		for _, s := range rule.Severity {
+			name, ok := severityKeyword(s)
+			if !ok {
+				return rt, fmt.Errorf("severity %q: expected one of %s or 0-7", s, strings.Join(severityNames, ", "))
+			}
+			rt.severity[name] = true
+		}
+	}
+	return rt, nil
+}
+
+// severityKeyword normalizes a severity name, alias or number to its keyword.
+func severityKeyword(s string) (string, bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if a, ok := severityAliases[s]; ok {
+		return a, true
+	}
+	if n, err := strconv.Atoi(s); err == nil {
+		if n >= 0 && n < len(severityNames) {
+			return severityNames[n], true
+		}
+		return "", false
+	}
+	for _, name := range severityNames {
+		if s == name {
+			return s, true
+		}
+	}
+	return "", false
+}
+
+// chanNames adds the "#" prefix to channel names that lack one.
+func chanNames(in []string) []string {
+	var out []string
+	for _, ch := range in {
+		if ch = ChannelName(ch); ch != "" {
+			out = append(out, ch)
+		}
+	}
+	return out
+}
+
+func lowerSet(values []string) map[string]bool {
+	if len(values) == 0 {
+		return nil
+	}
+	set := make(map[string]bool, len(values))
+	for _, v := range values {
+		set[strings.ToLower(strings.TrimSpace(v))] = true
+	}
+	return set
+}
+
+// matches reports whether all conditions of the rule hold for m.
+func (rt route) matches(m Message) bool {
+	if rt.inputs != nil && !rt.inputs[strings.ToLower(m.Input)] {
+		return false
+	}
+	if rt.severity != nil && !rt.severity[strings.ToLower(m.Severity)] {
+		return false
+	}
+	if rt.facility != nil && !rt.facility[strings.ToLower(m.Facility)] {
+		return false
+	}
+	if rt.nets != nil && !acl.Contains(rt.nets, m.Source) {
+		return false
+	}
+	if rt.ids != nil && !acl.MatchIdentity(rt.ids, m.Identities) {
+		return false
+	}
+	return rt.re == nil || rt.re.MatchString(m.Text)
+}
+
+// Route returns the target channels for m (nil => broadcast).
+func (r *Router) Route(m Message) []string {
+	var out []string
+	seen := map[string]bool{}
+	matched := false
+	for _, rt := range r.rules {
+		if !rt.matches(m) {
+			continue
+		}
+		matched = true
+		for _, ch := range rt.channels {
+			if k := strings.ToLower(ch); !seen[k] {
+				seen[k] = true
+				out = append(out, ch)
+			}
+		}
+		if !rt.cont {
+			break
+		}
+	}
+	if !matched {
+		return r.def
+	}
+	return out
+}
@@ -0,0 +1,93 @@
+package pipeline
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/bitcanon/ircpush/pkg/config"
+)
+
+var testRoutes = config.RoutesConfig{
+	Rules: []config.RouteRule{
+		{Name: "critical", Input: []string{"syslog"}, Severity: []string{"0", "alert", "critical", "error"}, Channels: []string{"#pager"}, Continue: true},
+		{Name: "firewalls", Source: []string{"10.1.0.0/16", "192.0.2.7"}, Channels: []string{"security"}},
+		{Name: "links", Match: `(?i)line protocol .* (up|down)`, Channels: []string{"#network"}},
+		{Name: "mail", Facility: []string{"mail"}, Channels: []string{"#mail"}},
+	},
+	Default: []string{"#general"},
+}
+
+// TestRoute tests rule order, continue/stop semantics and the default route.
+func TestRoute(t *testing.T) {
+	r, err := NewRouter(testRoutes)
+	if err != nil {
+		t.Fatalf("NewRouter: %v", err)
+	}
+	// Setup test cases
+	tests := []struct {
+		name     string
+		msg      Message
+		expected []string
+	}{
+		{
+			name:     "SeverityContinuesToSource",
+			msg:      Message{Input: "syslog", Source: "10.1.2.3:514", Severity: "err", Text: "disk failure"},
+			expected: []string{"#pager", "#security"},
+		},
+		{
+			name:     "SeverityOnlyWrongInput",
+			msg:      Message{Input: "tcp", Source: "127.0.0.1:5000", Severity: "err", Text: "x"},
+			expected: []string{"#general"},
+		},
+		{
+			name:     "SourceStopsBeforeRegex",
+			msg:      Message{Input: "tcp", Source: "192.0.2.7:40000", Text: "Gi0/1 line protocol changed to down"},
+			expected: []string{"#security"},
+		},
+		{
+			name:     "Regex",
+			msg:      Message{Input: "tcp", Source: "[2001:db8::1]:40000", Text: "Gi0/1 Line protocol changed to UP"},
+			expected: []string{"#network"},
+		},
+		{
+			name:     "Facility",
+			msg:      Message{Input: "syslog", Source: "203.0.113.9:514", Severity: "info", Facility: "mail", Text: "queued"},
+			expected: []string{"#mail"},
+		},
+		{
+			name:     "Default",
+			msg:      Message{Input: "http", Source: "203.0.113.9:1234", Text: "hello"},
+			expected: []string{"#general"},
+		},
+	}
+	// Run test cases
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := r.Route(test.msg); !reflect.DeepEqual(got, test.expected) {
+				t.Errorf("expected %v, but got %v", test.expected, got)
+			}
+		})
+	}
+}
+
+// TestNewRouterErrors verifies that invalid rules are rejected.
+func TestNewRouterErrors(t *testing.T) {
+	// Setup test cases
+	tests := []struct {
+		name string
+		rule config.RouteRule
+	}{
+		{name: "NoChannels", rule: config.RouteRule{Match: "x"}},
+		{name: "BadRegex", rule: config.RouteRule{Match: "(", Channels: []string{"#a"}}},
+		{name: "BadSource", rule: config.RouteRule{Source: []string{"10.0.0.0/33"}, Channels: []string{"#a"}}},
+		{name: "BadSeverity", rule: config.RouteRule{Severity: []string{"loud"}, Channels: []string{"#a"}}},
+	}
+	// Run test cases
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := NewRouter(config.RoutesConfig{Rules: []config.RouteRule{test.rule}}); err == nil {
+				t.Errorf("expected an error but got nil")
+			}
+		})
+	}
+}
@@ -0,0 +1,206 @@
+/*
+MIT License
+
+Copyright (c) 2025 Mikael Schultz <mikael@conf-t.se>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package redact
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/bitcanon/ircpush/pkg/config"
+	"github.com/bitcanon/ircpush/pkg/metrics"
+)
+
+const defaultMask = "[redacted]"
+
+// Redactor masks secrets and personal data in messages. Each replaced match
+// is counted in metrics.Redactions by rule name and channel.
+type Redactor struct {
+	rules   []rule
+	hashKey []byte
+}
+
+type rule struct {
+	name     string
+	re       *regexp.Regexp
+	groups   []int // submatch groups to replace; empty => the whole match
+	hash     bool
+	mask     string
+	includes []string // lower-cased glob patterns
+	excludes []string
+}
+
+// New compiles the redaction rules. Invalid rules are an error.
+func New(rc config.RedactConfig) (*Redactor, error) {
+	rd := &Redactor{hashKey: []byte(rc.HashKey)}
+	for i, r := range rc.Rules {
+		re, err := r.Regexp()
+		if err != nil {
+			return nil, fmt.Errorf("redact: rule %d: %w", i, err)
+		}
+		kind := strings.ToLower(strings.TrimSpace(r.Kind))
+		b, builtin := config.RedactBuiltins[kind]
+		cr := rule{name: strings.TrimSpace(r.Name), re: re, mask: r.Mask}
+		if cr.name == "" {
+			cr.name = kind
+			if !builtin {
+				cr.name = "rule" + strconv.Itoa(i)
+			}
+		}
+		if cr.mask == "" {
+			cr.mask = b.Mask
+		}
+		if cr.mask == "" {
+			cr.mask = defaultMask
+		}
+		switch strings.ToLower(r.Replace) {
+		case "", "mask":
+		case "hash":
+			if len(rd.hashKey) == 0 {
+				return nil, fmt.Errorf("redact: rule %d: replace: hash needs redact.hash_key", i)
+			}
+			cr.hash = true
+		default:
+			return nil, fmt.Errorf("redact: rule %d: unknown replacement %q", i, r.Replace)
+		}
+		groups := r.Groups
+		if builtin {
+			groups = b.Groups
+		}
+		for _, g := range groups {
+			g = strings.TrimSpace(g)
+			n, err := strconv.Atoi(g)
+			if err != nil {
+				n = re.SubexpIndex(g)
+			}
+			if n < 1 || n > re.NumSubexp() {
+				return nil, fmt.Errorf("redact: rule %d: no group %q in pattern", i, g)
+			}
+			cr.groups = append(cr.groups, n)
+		}
+		sort.Ints(cr.groups)
+		for _, p := range r.Channels {
+			if p = strings.TrimSpace(p); p != "" {
+				cr.includes = append(cr.includes, strings.ToLower(p))
+			}
+		}
+		for _, p := range r.ExcludeChannels {
+			if p = strings.TrimSpace(p); p != "" {
+				cr.excludes = append(cr.excludes, strings.ToLower(p))
+			}
+		}
+		rd.rules = append(rd.rules, cr)
+	}
+	return rd, nil
+}
+
+// Apply returns s with the matches of the rules that apply to channel
+// replaced. If channel is empty (broadcast), only rules without channel
+// filters apply.
+func (rd *Redactor) Apply(channel, s string) string {
+	ch := strings.ToLower(strings.TrimSpace(channel))
+	for _, r := range rd.rules {
+		if !r.appliesTo(ch) {
+			continue
+		}
+		var n int
+		s, n = rd.replace(r, s)
+		if n > 0 {
+			metrics.Redactions.Add(float64(n), r.name, channel)
+		}
+	}
+	return s
+}
+
+// replace replaces the matches (or their groups) of r in s and returns the
+// result and the number of replacements.
+func (rd *Redactor) replace(r rule, s string) (string, int) {
+	var b strings.Builder
+	n, last := 0, 0
+	for _, m := range r.re.FindAllStringSubmatchIndex(s, -1) {
+		if m[0] == m[1] {
+			continue
+		}
+		spans := [][2]int{{m[0], m[1]}}
+		if len(r.groups) > 0 {
+			spans = spans[:0]
+			for _, g := range r.groups {
+				start, end := m[2*g], m[2*g+1]
+				// skip unmatched, empty and nested groups
+				if start < 0 || start == end || (len(spans) > 0 && start < spans[len(spans)-1][1]) {
+					continue
+				}
+				spans = append(spans, [2]int{start, end})
+			}
+		}
+		for _, sp := range spans {
+			b.WriteString(s[last:sp[0]])
+			b.WriteString(rd.replacement(r, s[sp[0]:sp[1]]))
+			last = sp[1]
+			n++
+		}
+	}
+	if n == 0 {
+		return s, 0
+	}
+	b.WriteString(s[last:])
+	return b.String(), n
+}
+
+// replacement returns the mask, or for replace: hash a short hash of secret
+// that is the same every time, so repeats can be correlated.
+func (rd *Redactor) replacement(r rule, secret string) string {
+	if !r.hash {
+		return r.mask
+	}
+	h := hmac.New(sha256.New, rd.hashKey)
+	h.Write([]byte(secret))
+	return "[hash:" + hex.EncodeToString(h.Sum(nil)[:4]) + "]"
+}
+
+func (r rule) appliesTo(chLower string) bool {
+	if chLower == "" {
+		return len(r.includes) == 0 && len(r.excludes) == 0
+	}
+	for _, ex := range r.excludes {
+		if ok, _ := filepath.Match(ex, chLower); ok {
+			return false
+		}
+	}
+	if len(r.includes) == 0 {
+		return true
+	}
+	for _, in := range r.includes {
+		if ok, _ := filepath.Match(in, chLower); ok {
+			return true
+		}
+	}
+	return false
+}
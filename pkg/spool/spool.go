@@ -0,0 +1,502 @@
+/*
+MIT License
+
+Copyright (c) 2025 Mikael Schultz <mikael@conf-t.se>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package spool
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultMaxBytes = 64 << 20 // 64 MiB
+	defaultMaxAge   = 24 * time.Hour
+	maxSegmentBytes = 1 << 20 // rotate segment files at 1 MiB
+	cursorFile      = "cursor"
+	segmentSuffix   = ".seg"
+	replayWait      = 5 * time.Second // per attempt to queue a replayed message
+)
+
+// Client is the IRC side of the spool (implemented by *irc.Client).
+type Client interface {
+	SendTo(channels []string, msg string)
+	Broadcast(msg string)
+	Ready() bool
+	// SendWait queues msg (for all channels when channels is nil), waiting
+	// for room in the send queue; it fails when ctx is done.
+	SendWait(ctx context.Context, channels []string, msg string) error
+}
+
+// Logger is a minimal logger interface.
+type Logger interface {
+	Printf(format string, v ...any)
+}
+
+// Options configures the spool limits.
+type Options struct {
+	MaxBytes int64         // total size of all segments; oldest segments are dropped beyond this
+	MaxAge   time.Duration // records older than this are discarded instead of replayed
+	Logger   Logger
+}
+
+// record is one spooled message, stored as a JSON line.
+type record struct {
+	Time      int64    `json:"t"`           // unix milliseconds when received
+	Channels  []string `json:"c,omitempty"` // empty with Broadcast=true
+	Broadcast bool     `json:"b,omitempty"`
+	Msg       string   `json:"m"`
+}
+
+type segment struct {
+	id   uint64
+	size int64
+}
+
+// Spool buffers messages on disk while the IRC client is not ready and
+// replays them in order once it is. Messages are appended to segment files
+// in Dir; a cursor file remembers how far replay got, so a restart neither
+// loses nor repeats messages.
+//
+// Spool implements the same SendTo/Broadcast methods as the IRC client, so it
+// can be placed between the pipeline and the client transparently.
+type Spool struct {
+	Dir  string
+	IRC  Client
+	opts Options
+
+	mu        sync.Mutex
+	segs      []segment // oldest first; the last one is appended to
+	total     int64
+	curSeg    uint64 // cursor: segment id and byte offset of the next record to replay
+	curOff    int64
+	w         *os.File
+	replaying bool
+}
+
+// Open opens (or creates) a spool in dir in front of cli.
+func Open(dir string, cli Client, opts Options) (*Spool, error) {
+	if opts.MaxBytes <= 0 {
+		opts.MaxBytes = defaultMaxBytes
+	}
+	if opts.MaxAge <= 0 {
+		opts.MaxAge = defaultMaxAge
+	}
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("spool: %w", err)
+	}
+	s := &Spool{Dir: dir, IRC: cli, opts: opts}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	if n := s.pendingBytesLocked(); n > 0 {
+		s.logf("spool: %d bytes pending from previous run in %s", n, dir)
+	}
+	return s, nil
+}
+
+func (s *Spool) logf(format string, v ...any) {
+	if s.opts.Logger != nil {
+		s.opts.Logger.Printf(format, v...)
+		return
+	}
+	fmt.Fprintf(os.Stderr, format+"\n", v...)
+}
+
+// load scans existing segments and the cursor.
+func (s *Spool) load() error {
+	entries, err := os.ReadDir(s.Dir)
+	if err != nil {
+		return fmt.Errorf("spool: %w", err)
+	}
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, segmentSuffix) {
+			continue
+		}
+		id, err := strconv.ParseUint(strings.TrimSuffix(name, segmentSuffix), 10, 64)
+		if err != nil {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			return fmt.Errorf("spool: %w", err)
+		}
+		s.segs = append(s.segs, segment{id: id, size: info.Size()})
+		s.total += info.Size()
+	}
+	sort.Slice(s.segs, func(i, j int) bool { return s.segs[i].id < s.segs[j].id })
+
+	if b, err := os.ReadFile(filepath.Join(s.Dir, cursorFile)); err == nil {
+		_, _ = fmt.Sscanf(string(b), "%d %d", &s.curSeg, &s.curOff)
+	}
+	// Cursor must point into an existing segment; otherwise start at the oldest
+	if len(s.segs) > 0 && (s.curSeg < s.segs[0].id || s.indexOf(s.curSeg) < 0) {
+		s.curSeg, s.curOff = s.segs[0].id, 0
+	}
+	return nil
+}
+
+func (s *Spool) indexOf(id uint64) int {
+	for i, sg := range s.segs {
+		if sg.id == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func (s *Spool) segPath(id uint64) string {
+	return filepath.Join(s.Dir, fmt.Sprintf("%016d%s", id, segmentSuffix))
+}
+
+// pendingBytesLocked returns the number of spooled bytes not yet replayed.
+func (s *Spool) pendingBytesLocked() int64 {
+	var n int64
+	for _, sg := range s.segs {
+		switch {
+		case sg.id == s.curSeg:
+			n += sg.size - s.curOff
+		case sg.id > s.curSeg:
+			n += sg.size
+		}
+	}
+	return n
+}
+
+// Pending reports the number of spooled bytes waiting to be replayed.
+func (s *Spool) Pending() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.pendingBytesLocked()
+}
+
+// SendTo sends directly when IRC is ready and nothing is spooled; otherwise
+// the message is appended to the spool to keep ordering.
+func (s *Spool) SendTo(channels []string, msg string) {
+	s.submit(record{Channels: channels, Msg: msg})
+}
+
+// Broadcast is like SendTo for all configured channels.
+func (s *Spool) Broadcast(msg string) {
+	s.submit(record{Broadcast: true, Msg: msg})
+}
+
+func (s *Spool) submit(r record) {
+	s.mu.Lock()
+	if s.pendingBytesLocked() == 0 && !s.replaying && s.IRC.Ready() {
+		s.mu.Unlock()
+		s.deliver(r, "")
+		return
+	}
+	r.Time = time.Now().UnixMilli()
+	err := s.appendLocked(r)
+	s.mu.Unlock()
+	if err != nil {
+		// Better to try the live connection than to lose the message silently
+		s.logf("spool: append failed: %v (sending directly)", err)
+		s.deliver(r, "")
+	}
+}
+
+func (s *Spool) deliver(r record, prefix string) {
+	if r.Broadcast {
+		s.IRC.Broadcast(prefix + r.Msg)
+		return
+	}
+	s.IRC.SendTo(r.Channels, prefix+r.Msg)
+}
+
+// appendLocked writes r to the newest segment, rotating and enforcing max_bytes.
+func (s *Spool) appendLocked(r record) error {
+	line, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	n := len(s.segs)
+	if n == 0 || s.segs[n-1].size+int64(len(line)) > s.segmentLimit() {
+		var id uint64 = 1
+		if n > 0 {
+			id = s.segs[n-1].id + 1
+		}
+		if s.w != nil {
+			_ = s.w.Close()
+			s.w = nil
+		}
+		s.segs = append(s.segs, segment{id: id})
+		if n == 0 {
+			s.curSeg, s.curOff = id, 0
+		}
+		n++
+	}
+	if s.w == nil {
+		f, err := os.OpenFile(s.segPath(s.segs[n-1].id), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o640)
+		if err != nil {
+			return err
+		}
+		s.w = f
+	}
+	if _, err := s.w.Write(line); err != nil {
+		return err
+	}
+	s.segs[n-1].size += int64(len(line))
+	s.total += int64(len(line))
+	s.enforceMaxBytesLocked()
+	return nil
+}
+
+// segmentLimit keeps segments small relative to max_bytes so drop-oldest is fine-grained.
+func (s *Spool) segmentLimit() int64 {
+	return min(int64(maxSegmentBytes), max(s.opts.MaxBytes/8, 4096))
+}
+
+// enforceMaxBytesLocked drops the oldest segments until the spool fits max_bytes.
+func (s *Spool) enforceMaxBytesLocked() {
+	for s.total > s.opts.MaxBytes && len(s.segs) > 1 {
+		old := s.segs[0]
+		s.segs = s.segs[1:]
+		s.total -= old.size
+		_ = os.Remove(s.segPath(old.id))
+		if s.curSeg <= old.id {
+			s.curSeg, s.curOff = s.segs[0].id, 0
+		}
+		s.logf("spool: max_bytes=%d exceeded, dropped oldest segment %d (%d bytes)", s.opts.MaxBytes, old.id, old.size)
+	}
+}
+
+// Replay sends spooled messages in order while IRC stays ready.
+// It returns immediately; replay runs in the background. Call it when the
+// client has registered and joined its channels (irc.Handlers.Ready).
+// Each message waits for room in the client's send queue, and the cursor
+// only moves past it once the client has accepted it.
+func (s *Spool) Replay() {
+	s.mu.Lock()
+	if s.replaying || s.pendingBytesLocked() == 0 {
+		s.mu.Unlock()
+		return
+	}
+	s.replaying = true
+	s.mu.Unlock()
+	go s.replay()
+}
+
+func (s *Spool) replay() {
+	var sent, expired int
+	defer func() {
+		if sent > 0 || expired > 0 {
+			s.logf("spool: replayed %d messages, discarded %d older than max_age=%s", sent, expired, s.opts.MaxAge)
+		}
+	}()
+
+	var (
+		f      *os.File
+		br     *bufio.Reader
+		openID uint64
+	)
+	defer func() {
+		if f != nil {
+			_ = f.Close()
+		}
+	}()
+
+	for {
+		// replaying is cleared under the same lock that observed the exit
+		// condition, so a concurrent submit never strands a spooled message.
+		s.mu.Lock()
+		if !s.IRC.Ready() {
+			s.replaying = false
+			s.mu.Unlock()
+			s.logf("spool: IRC not ready, pausing replay")
+			return
+		}
+		if s.pendingBytesLocked() == 0 {
+			s.resetLocked()
+			s.replaying = false
+			s.mu.Unlock()
+			return
+		}
+		// Move to the next segment once the current one is fully replayed
+		if i := s.indexOf(s.curSeg); i >= 0 && s.curOff >= s.segs[i].size && i < len(s.segs)-1 {
+			s.dropSegmentLocked(i)
+			s.curSeg, s.curOff = s.segs[i].id, 0
+		}
+		if f == nil || openID != s.curSeg {
+			if f != nil {
+				_ = f.Close()
+			}
+			var err error
+			f, err = os.Open(s.segPath(s.curSeg))
+			if err == nil {
+				_, err = f.Seek(s.curOff, io.SeekStart)
+			}
+			if err != nil {
+				s.logf("spool: open segment %d: %v, skipping it", s.curSeg, err)
+				f = nil
+				s.skipSegmentLocked()
+				s.mu.Unlock()
+				continue
+			}
+			openID = s.curSeg
+			br = bufio.NewReader(f)
+		}
+		line, err := br.ReadBytes('\n')
+		if err != nil {
+			// Truncated tail (e.g. crash mid-write): skip the rest of the segment
+			s.logf("spool: segment %d: %v at offset %d, skipping rest", s.curSeg, err, s.curOff)
+			_ = f.Close()
+			f = nil
+			s.skipSegmentLocked()
+			s.mu.Unlock()
+			continue
+		}
+		seg, next := s.curSeg, s.curOff+int64(len(line))
+		s.mu.Unlock()
+
+		var r record
+		if err := json.Unmarshal(line, &r); err != nil {
+			s.logf("spool: corrupt record skipped: %v", err)
+			s.advance(seg, next)
+			continue
+		}
+		late := time.Since(time.UnixMilli(r.Time))
+		if late > s.opts.MaxAge {
+			expired++
+			s.advance(seg, next)
+			continue
+		}
+		if err := s.replayOne(r, annotation(late)); err != nil {
+			// Not accepted: read it again from the cursor
+			_ = f.Close()
+			f = nil
+			if errors.Is(err, context.DeadlineExceeded) {
+				continue // queue still full, or IRC went away (checked above)
+			}
+			s.mu.Lock()
+			s.replaying = false
+			s.mu.Unlock()
+			s.logf("spool: replay paused: %v", err)
+			return
+		}
+		s.advance(seg, next)
+		sent++
+	}
+}
+
+// replayOne hands r to the client, waiting for room in its send queue.
+func (s *Spool) replayOne(r record, prefix string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), replayWait)
+	defer cancel()
+	channels := r.Channels
+	if r.Broadcast {
+		channels = nil
+	} else if channels == nil {
+		channels = []string{}
+	}
+	return s.IRC.SendWait(ctx, channels, prefix+r.Msg)
+}
+
+// advance moves the cursor of segment seg to off, unless the segment was
+// dropped (max_bytes) while its record was being replayed.
+func (s *Spool) advance(seg uint64, off int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.curSeg == seg {
+		s.curOff = off
+		s.saveCursorLocked()
+	}
+}
+
+// annotation returns the prefix for a replayed message, e.g. "[replayed, 12 min late] ".
+func annotation(late time.Duration) string {
+	if late < time.Minute {
+		return "[replayed, <1 min late] "
+	}
+	return fmt.Sprintf("[replayed, %d min late] ", int(late.Minutes()))
+}
+
+// skipSegmentLocked moves the cursor past the current segment.
+func (s *Spool) skipSegmentLocked() {
+	i := s.indexOf(s.curSeg)
+	if i < 0 || i == len(s.segs)-1 {
+		s.resetLocked()
+		return
+	}
+	s.dropSegmentLocked(i)
+	s.curSeg, s.curOff = s.segs[i].id, 0
+	s.saveCursorLocked()
+}
+
+// dropSegmentLocked deletes segment i (already replayed or unreadable).
+func (s *Spool) dropSegmentLocked(i int) {
+	sg := s.segs[i]
+	s.segs = append(s.segs[:i], s.segs[i+1:]...)
+	s.total -= sg.size
+	_ = os.Remove(s.segPath(sg.id))
+}
+
+// resetLocked removes all segments once everything has been replayed.
+func (s *Spool) resetLocked() {
+	if s.w != nil {
+		_ = s.w.Close()
+		s.w = nil
+	}
+	for _, sg := range s.segs {
+		_ = os.Remove(s.segPath(sg.id))
+	}
+	s.segs = nil
+	s.total = 0
+	s.curSeg, s.curOff = 0, 0
+	_ = os.Remove(filepath.Join(s.Dir, cursorFile))
+}
+
+func (s *Spool) saveCursorLocked() {
+	data := fmt.Sprintf("%d %d\n", s.curSeg, s.curOff)
+	_ = os.WriteFile(filepath.Join(s.Dir, cursorFile), []byte(data), 0o640)
+}
+
+// Close flushes and closes the active segment. Pending messages stay on disk.
+func (s *Spool) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.w == nil {
+		return nil
+	}
+	err := s.w.Sync()
+	if cerr := s.w.Close(); err == nil {
+		err = cerr
+	}
+	s.w = nil
+	return err
+}
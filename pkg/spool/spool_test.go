@@ -0,0 +1,225 @@
+package spool
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeClient records delivered messages and reports a settable readiness.
+type fakeClient struct {
+	ready  atomic.Bool
+	refuse atomic.Int32 // SendWait calls to time out before accepting
+
+	mu   sync.Mutex
+	sent []string
+}
+
+func (f *fakeClient) SendTo(channels []string, msg string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sent = append(f.sent, strings.Join(channels, ",")+" "+msg)
+}
+
+func (f *fakeClient) Broadcast(msg string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sent = append(f.sent, "* "+msg)
+}
+
+func (f *fakeClient) SendWait(_ context.Context, channels []string, msg string) error {
+	if f.refuse.Add(-1) >= 0 {
+		return context.DeadlineExceeded
+	}
+	if channels == nil {
+		f.Broadcast(msg)
+	} else {
+		f.SendTo(channels, msg)
+	}
+	return nil
+}
+
+func (f *fakeClient) Ready() bool { return f.ready.Load() }
+
+func (f *fakeClient) lines() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.sent...)
+}
+
+type discard struct{}
+
+func (discard) Printf(string, ...any) {}
+
+// waitDrained waits until replay has delivered everything.
+func waitDrained(t *testing.T, s *Spool) {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for time.Now().Before(deadline) {
+		s.mu.Lock()
+		done := !s.replaying && s.pendingBytesLocked() == 0
+		s.mu.Unlock()
+		if done {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("spool not drained, %d bytes pending", s.Pending())
+}
+
+// TestSpoolReplayInOrder spools while disconnected and replays in order with an annotation.
+func TestSpoolReplayInOrder(t *testing.T) {
+	cli := &fakeClient{}
+	s, err := Open(t.TempDir(), cli, Options{Logger: discard{}})
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer s.Close()
+
+	s.SendTo([]string{"#ops"}, "one")
+	s.Broadcast("two")
+	s.SendTo([]string{"#ci"}, "three")
+	if got := cli.lines(); len(got) != 0 {
+		t.Fatalf("expected nothing sent while not ready, got %#v", got)
+	}
+
+	cli.ready.Store(true)
+	s.Replay()
+	waitDrained(t, s)
+	s.SendTo([]string{"#ops"}, "live")
+
+	expected := []string{
+		"#ops [replayed, <1 min late] one",
+		"* [replayed, <1 min late] two",
+		"#ci [replayed, <1 min late] three",
+		"#ops live",
+	}
+	if got := cli.lines(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %#v, but got %#v", expected, got)
+	}
+}
+
+// TestSpoolReplayRetriesRefused keeps the cursor on a message the client did
+// not accept and delivers it exactly once when the queue has room.
+func TestSpoolReplayRetriesRefused(t *testing.T) {
+	cli := &fakeClient{}
+	s, err := Open(t.TempDir(), cli, Options{Logger: discard{}})
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer s.Close()
+
+	s.SendTo([]string{"#ops"}, "one")
+	s.SendTo([]string{"#ops"}, "two")
+	cli.refuse.Store(2)
+
+	cli.ready.Store(true)
+	s.Replay()
+	waitDrained(t, s)
+
+	expected := []string{
+		"#ops [replayed, <1 min late] one",
+		"#ops [replayed, <1 min late] two",
+	}
+	if got := cli.lines(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %#v, but got %#v", expected, got)
+	}
+}
+
+// TestSpoolSurvivesRestart verifies that pending messages and the cursor persist across Open.
+func TestSpoolSurvivesRestart(t *testing.T) {
+	dir := t.TempDir()
+	s, err := Open(dir, &fakeClient{}, Options{Logger: discard{}})
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	s.SendTo([]string{"#ops"}, "before restart")
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	cli := &fakeClient{}
+	cli.ready.Store(true)
+	s, err = Open(dir, cli, Options{Logger: discard{}})
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer s.Close()
+	if s.Pending() == 0 {
+		t.Fatalf("expected pending bytes after reopen")
+	}
+	s.Replay()
+	waitDrained(t, s)
+	if got := cli.lines(); len(got) != 1 || !strings.HasSuffix(got[0], "before restart") {
+		t.Errorf("unexpected replay after restart: %#v", got)
+	}
+}
+
+// TestSpoolMaxAge discards records older than max_age.
+func TestSpoolMaxAge(t *testing.T) {
+	cli := &fakeClient{}
+	s, err := Open(t.TempDir(), cli, Options{MaxAge: time.Millisecond, Logger: discard{}})
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer s.Close()
+	s.Broadcast("stale")
+	time.Sleep(10 * time.Millisecond)
+
+	cli.ready.Store(true)
+	s.Replay()
+	waitDrained(t, s)
+	if got := cli.lines(); len(got) != 0 {
+		t.Errorf("expected stale message to be discarded, got %#v", got)
+	}
+}
+
+// TestSpoolMaxBytesDropsOldest verifies that the oldest segments go first when full.
+func TestSpoolMaxBytesDropsOldest(t *testing.T) {
+	cli := &fakeClient{}
+	s, err := Open(t.TempDir(), cli, Options{MaxBytes: 16 << 10, Logger: discard{}})
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer s.Close()
+
+	msg := strings.Repeat("x", 200)
+	for i := 0; i < 500; i++ {
+		s.Broadcast(msg)
+	}
+	s.mu.Lock()
+	total := s.total
+	s.mu.Unlock()
+	if total > 16<<10 {
+		t.Fatalf("spool exceeds max_bytes: %d", total)
+	}
+
+	cli.ready.Store(true)
+	s.Replay()
+	waitDrained(t, s)
+	if n := len(cli.lines()); n == 0 || n >= 500 {
+		t.Errorf("expected some but not all messages replayed, got %d", n)
+	}
+}
+
+// TestAnnotation tests the replay prefix.
+func TestAnnotation(t *testing.T) {
+	// Setup test cases
+	tests := []struct {
+		late     time.Duration
+		expected string
+	}{
+		{late: 30 * time.Second, expected: "[replayed, <1 min late] "},
+		{late: 12*time.Minute + 40*time.Second, expected: "[replayed, 12 min late] "},
+	}
+	// Run test cases
+	for _, test := range tests {
+		if got := annotation(test.late); got != test.expected {
+			t.Errorf("annotation(%s): expected %q, but got %q", test.late, test.expected, got)
+		}
+	}
+}